@@ -0,0 +1,323 @@
+// Package agent runs checks away from the server: it fetches the monitors
+// assigned to its location, probes them with the server's own check code and
+// reports the results back.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"runnerx/models"
+	"runnerx/probe"
+)
+
+const (
+	flushInterval    = 2 * time.Second
+	flushBatch       = 50
+	maxPending       = 1000
+	defaultPoll      = 30 * time.Second
+	maxRetryInterval = time.Minute
+)
+
+// ErrUnauthorized is returned once the server rejects the agent's token
+var ErrUnauthorized = errors.New("agent token rejected by the server")
+
+// Config configures an agent
+type Config struct {
+	ServerURL string // e.g. https://runnerx.example.com
+	Token     string
+	Version   string
+	Hostname  string
+}
+
+// Result is a check result waiting to be reported
+type Result struct {
+	MonitorID uint `json:"monitor_id"`
+	probe.Result
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// statusError is a response the server refused
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+type worker struct {
+	updatedAt time.Time
+	cancel    context.CancelFunc
+}
+
+// Agent is one running agent
+type Agent struct {
+	cfg      Config
+	client   *http.Client
+	location string
+	poll     time.Duration
+
+	workers map[uint]*worker
+	results chan Result
+	// pending is only touched by reportResults
+	pending []Result
+}
+
+func New(cfg Config) *Agent {
+	return &Agent{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		poll:    defaultPoll,
+		workers: make(map[uint]*worker),
+		results: make(chan Result, flushBatch),
+	}
+}
+
+// Run registers with the server and checks the assigned monitors until ctx
+// is cancelled or the server rejects the token
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+	log.Printf("Agent registered at location %s", a.location)
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- a.reportResults(ctx) }()
+
+	retry := time.Second
+	for {
+		wait := a.poll
+		if err := a.sync(ctx); err != nil {
+			if errors.Is(err, ErrUnauthorized) {
+				a.stopWorkers()
+				return err
+			}
+			log.Printf("Failed to fetch monitors: %v", err)
+			wait, retry = retry, min(2*retry, maxRetryInterval)
+		} else {
+			retry = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			a.stopWorkers()
+			return nil
+		case err := <-flushed:
+			a.stopWorkers()
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// register announces the agent, retrying with backoff while the server is
+// unreachable
+func (a *Agent) register(ctx context.Context) error {
+	body := map[string]string{"version": a.cfg.Version, "hostname": a.cfg.Hostname}
+	retry := time.Second
+	for {
+		var resp struct {
+			Agent struct {
+				Location string `json:"location"`
+			} `json:"agent"`
+			PollIntervalSeconds int `json:"poll_interval_seconds"`
+		}
+		err := a.call(ctx, http.MethodPost, "/api/agent/register", body, &resp)
+		if err == nil {
+			a.location = resp.Agent.Location
+			if resp.PollIntervalSeconds > 0 {
+				a.poll = time.Duration(resp.PollIntervalSeconds) * time.Second
+			}
+			return nil
+		}
+		if errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+		log.Printf("Failed to register, retrying in %s: %v", retry, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, maxRetryInterval)
+	}
+}
+
+// sync fetches the assigned monitors and starts, restarts or stops their
+// workers to match
+func (a *Agent) sync(ctx context.Context) error {
+	var resp struct {
+		Monitors            []models.Monitor `json:"monitors"`
+		PollIntervalSeconds int              `json:"poll_interval_seconds"`
+	}
+	if err := a.call(ctx, http.MethodGet, "/api/agent/monitors", nil, &resp); err != nil {
+		return err
+	}
+	if resp.PollIntervalSeconds > 0 {
+		a.poll = time.Duration(resp.PollIntervalSeconds) * time.Second
+	}
+
+	assigned := make(map[uint]bool, len(resp.Monitors))
+	for i := range resp.Monitors {
+		monitor := resp.Monitors[i]
+		assigned[monitor.ID] = true
+		if w, ok := a.workers[monitor.ID]; ok {
+			if w.updatedAt.Equal(monitor.UpdatedAt) {
+				continue
+			}
+			w.cancel()
+		}
+		workerCtx, cancel := context.WithCancel(ctx)
+		a.workers[monitor.ID] = &worker{updatedAt: monitor.UpdatedAt, cancel: cancel}
+		go a.check(workerCtx, &monitor)
+	}
+	for id, w := range a.workers {
+		if !assigned[id] {
+			w.cancel()
+			delete(a.workers, id)
+		}
+	}
+	return nil
+}
+
+func (a *Agent) stopWorkers() {
+	for id, w := range a.workers {
+		w.cancel()
+		delete(a.workers, id)
+	}
+}
+
+// check probes a monitor on its interval. The first check is spread over the
+// interval so monitors added together are not all checked at once.
+func (a *Agent) check(ctx context.Context, monitor *models.Monitor) {
+	interval := time.Duration(monitor.IntervalSeconds) * time.Second
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	delay := time.Duration(rand.Int63n(int64(interval)))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = interval
+
+		result := Result{MonitorID: monitor.ID, Result: probe.Run(ctx, monitor), CheckedAt: time.Now()}
+		select {
+		case a.results <- result:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reportResults sends results in batches, keeping those that failed to send
+// for the next attempt
+func (a *Agent) reportResults(ctx context.Context) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// One last attempt with what has been checked so far
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			a.flush(flushCtx)
+			cancel()
+			return nil
+		case result := <-a.results:
+			a.pending = append(a.pending, result)
+			if len(a.pending) > maxPending {
+				a.pending = a.pending[len(a.pending)-maxPending:]
+			}
+			if len(a.pending) < flushBatch {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if err := a.flush(ctx); errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+	}
+}
+
+func (a *Agent) flush(ctx context.Context) error {
+	batch := a.pending
+	if len(batch) > 500 {
+		batch = batch[:500]
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := a.call(ctx, http.MethodPost, "/api/agent/results", map[string]interface{}{"results": batch}, nil)
+	var refused *statusError
+	if errors.As(err, &refused) && refused.code < 500 && refused.code != http.StatusTooManyRequests {
+		// Sending the same batch again would be refused again
+		log.Printf("Dropping %d results refused by the server: %v", len(batch), err)
+	} else if err != nil {
+		log.Printf("Failed to report %d results: %v", len(batch), err)
+		return err
+	}
+
+	a.pending = a.pending[len(batch):]
+	return nil
+}
+
+// call sends an authenticated request to the server and decodes the response into out
+func (a *Agent) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(a.cfg.ServerURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+	req.Header.Set("User-Agent", "runnerx-agent/"+a.cfg.Version)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{
+			code: resp.StatusCode,
+			msg:  fmt.Sprintf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg))),
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
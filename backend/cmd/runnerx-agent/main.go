@@ -0,0 +1,41 @@
+// Command runnerx-agent checks the monitors assigned to its location and
+// reports the results to a RunnerX server.
+//
+//	RUNNERX_SERVER=https://runnerx.example.com RUNNERX_AGENT_TOKEN=rnxa_... runnerx-agent
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"runnerx/agent"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+func main() {
+	hostname, _ := os.Hostname()
+
+	cfg := agent.Config{Version: version}
+	flag.StringVar(&cfg.ServerURL, "server", os.Getenv("RUNNERX_SERVER"), "URL of the RunnerX server (RUNNERX_SERVER)")
+	flag.StringVar(&cfg.Token, "token", os.Getenv("RUNNERX_AGENT_TOKEN"), "agent token (RUNNERX_AGENT_TOKEN)")
+	flag.StringVar(&cfg.Hostname, "hostname", hostname, "name reported to the server")
+	flag.Parse()
+
+	if cfg.ServerURL == "" || cfg.Token == "" {
+		log.Fatal("The server URL and the agent token are required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := agent.New(cfg).Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatalf("Agent stopped: %v", err)
+	}
+	log.Println("Agent stopped")
+}
@@ -1,27 +1,541 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultJWTSecret is the placeholder secret, only accepted in dev mode
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
 type Config struct {
-	Port        string
-	DatabaseURL string
-	JWTSecret   string
+	Port        string           `yaml:"port"`
+	DatabaseURL string           `yaml:"database_url"`
+	JWTSecret   string           `yaml:"jwt_secret"`
+	OIDC        OIDCConfig       `yaml:"oidc"`
+	Mail        MailConfig       `yaml:"mail"`
+	RateLimit   RateLimitConfig  `yaml:"rate_limit"`
+	PubSub      PubSubConfig     `yaml:"pubsub"`
+	Cluster     ClusterConfig    `yaml:"cluster"`
+	CORS        CORSConfig       `yaml:"cors"`
+	Monitoring  MonitoringConfig `yaml:"monitoring"`
+	Commands    CommandsConfig   `yaml:"commands"`
+	Analytics   AnalyticsConfig  `yaml:"analytics"`
+	TLS         TLSConfig        `yaml:"tls"`
+	Tracing     TracingConfig    `yaml:"tracing"`
+
+	// DevMode relaxes the checks that protect production deployments, such
+	// as refusing the placeholder JWT secret
+	DevMode bool `yaml:"dev_mode"`
+	// AppURL is the frontend address used in links sent by email
+	AppURL string `yaml:"app_url"`
+	// RequireEmailVerification refuses password logins until the account's
+	// email address has been verified
+	RequireEmailVerification bool `yaml:"require_email_verification"`
+	// AllowPrivateWebhooks lets status page webhooks reach loopback and
+	// private network addresses
+	AllowPrivateWebhooks bool `yaml:"allow_private_webhooks"`
+	// ProbeLocation names the location of the checks run by the server itself
+	ProbeLocation string `yaml:"probe_location"`
+	// ShutdownTimeout bounds how long a stopping server waits for requests,
+	// checks and commands in flight
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Rate allows Requests per Window. In the config file it is written as
+// "requests/window", e.g. "20/1m".
+type Rate struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (r *Rate) UnmarshalYAML(value *yaml.Node) error {
+	rate, err := parseRate(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", value.Line, err)
+	}
+	*r = rate
+	return nil
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Requests, r.Window)
+}
+
+// RateLimitConfig sets the request budgets of each tier. Store is "memory"
+// (per process) or "database" (shared by every replica using the database).
+type RateLimitConfig struct {
+	Store     string `yaml:"store"`
+	IP        Rate   `yaml:"ip"`        // every request, per client IP
+	Auth      Rate   `yaml:"auth"`      // login, register and recovery endpoints, per client IP
+	Register  Rate   `yaml:"register"`  // account creation, per client IP
+	User      Rate   `yaml:"user"`      // authenticated requests, per user
+	Token     Rate   `yaml:"token"`     // requests made with a personal access token, per token
+	Expensive Rate   `yaml:"expensive"` // diagnostics and connection tests, per user
+
+	// Failed logins allowed per account before it is locked. The lockout
+	// starts at LoginLockout and doubles on each repeat up to LoginMaxLockout.
+	LoginMaxFailures int           `yaml:"login_max_failures"`
+	LoginLockout     time.Duration `yaml:"login_lockout"`
+	LoginMaxLockout  time.Duration `yaml:"login_max_lockout"`
 }
 
-func Load() *Config {
+// PubSubConfig selects the bus that carries live events between replicas.
+// Broker is "memory" for a single instance or "redis".
+type PubSubConfig struct {
+	Broker   string `yaml:"broker"`
+	RedisURL string `yaml:"redis_url"`
+	Channel  string `yaml:"channel"`
+}
+
+// ClusterConfig configures how instances sharing the database split the work.
+// Each instance heartbeats every HeartbeatInterval and is considered gone
+// once it has been silent for MemberTTL, which is also how long the leader
+// lease lasts without renewal.
+type ClusterConfig struct {
+	InstanceID        string        `yaml:"instance_id"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	MemberTTL         time.Duration `yaml:"member_ttl"`
+}
+
+// MailConfig configures outgoing email; without a host, messages are written
+// to the log instead
+type MailConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// OIDCConfig configures single sign-on; it is disabled unless an issuer and
+// client ID are set
+type OIDCConfig struct {
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// FrontendURL receives the issued tokens in the URL fragment after login
+	FrontendURL string `yaml:"frontend_url"`
+	// RoleClaim names the ID token claim mapped to a RunnerX role through
+	// RoleMapping (claim value -> role); unmapped users get DefaultRole
+	RoleClaim   string            `yaml:"role_claim"`
+	RoleMapping map[string]string `yaml:"role_mapping"`
+	DefaultRole string            `yaml:"default_role"`
+}
+
+// Enabled reports whether OIDC login is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != ""
+}
+
+// CORSConfig lists the browser origins allowed to call the API
+type CORSConfig struct {
+	Origins []string `yaml:"origins"`
+}
+
+// MonitoringConfig tunes the checks run by the server
+type MonitoringConfig struct {
+	// SchedulerTick is how often monitors are looked at for a due check
+	SchedulerTick time.Duration `yaml:"scheduler_tick"`
+	// NotificationSuppression is the least time between two notifications
+	// of the same monitor
+	NotificationSuppression time.Duration `yaml:"notification_suppression"`
+	// ScreenshotTimeout bounds a downtime screenshot, page load included
+	ScreenshotTimeout time.Duration `yaml:"screenshot_timeout"`
+}
+
+// CommandsConfig tunes the diagnostic commands
+type CommandsConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// AnalyticsConfig sets how often forecasts and the system mood are computed
+type AnalyticsConfig struct {
+	Interval     time.Duration `yaml:"interval"`
+	MoodInterval time.Duration `yaml:"mood_interval"`
+}
+
+// TLSConfig serves HTTPS on the main port, with a certificate read from
+// CertFile and KeyFile or obtained through ACME for the ACME domains
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// HTTPPort, if set, serves plain HTTP redirecting to HTTPS, and ACME
+	// HTTP-01 challenges
+	HTTPPort string `yaml:"http_port"`
+	// HSTSMaxAge is how long browsers are told to use HTTPS only; zero
+	// sends no Strict-Transport-Security header
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains"`
+	ACME                  ACMEConfig    `yaml:"acme"`
+}
+
+// Enabled reports whether the server serves HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.ACME.Enabled()
+}
+
+// ACMEConfig obtains and renews certificates from an ACME certificate
+// authority such as Let's Encrypt
+type ACMEConfig struct {
+	Domains      []string `yaml:"domains"`
+	Email        string   `yaml:"email"`
+	DirectoryURL string   `yaml:"directory_url"`
+	// CacheDir keeps the account key and certificates across restarts
+	CacheDir string `yaml:"cache_dir"`
+	// CAFile is trusted when talking to the ACME server, for test servers
+	// such as Pebble whose certificate is not publicly trusted
+	CAFile string `yaml:"ca_file"`
+}
+
+// Enabled reports whether certificates come from ACME
+func (c ACMEConfig) Enabled() bool {
+	return len(c.Domains) > 0
+}
+
+// TracingConfig exports OpenTelemetry traces of API requests, checks and
+// database queries to an OTLP/HTTP collector at Endpoint
+type TracingConfig struct {
+	// Endpoint is the collector's base URL, e.g. http://localhost:4318;
+	// empty disables tracing
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+	// Headers are sent with every export, e.g. to authenticate with a
+	// hosted collector
+	Headers map[string]string `yaml:"headers"`
+	// SampleRatio is the share of traces started here that are recorded;
+	// requests that arrive within a sampled trace are always recorded
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Enabled reports whether traces are exported
+func (c TracingConfig) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// Default returns the configuration used when neither a config file nor the
+// environment say otherwise
+func Default() *Config {
 	return &Config{
-		Port:        getEnv("PORT", "8080"),
-		DatabaseURL: getEnv("DATABASE_URL", "./runnerx.db"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		Port:        "8080",
+		DatabaseURL: "./runnerx.db",
+		JWTSecret:   DefaultJWTSecret,
+		OIDC: OIDCConfig{
+			RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+			Scopes:      []string{"openid", "email", "profile"},
+			FrontendURL: "http://localhost:3000/auth/callback",
+			RoleClaim:   "groups",
+			RoleMapping: map[string]string{},
+			DefaultRole: "user",
+		},
+		Mail: MailConfig{
+			Port: "587",
+			From: "RunnerX <noreply@localhost>",
+		},
+		RateLimit: RateLimitConfig{
+			Store:            "memory",
+			IP:               Rate{1000, time.Minute},
+			Auth:             Rate{20, time.Minute},
+			Register:         Rate{5, time.Hour},
+			User:             Rate{600, time.Minute},
+			Token:            Rate{300, time.Minute},
+			Expensive:        Rate{10, time.Minute},
+			LoginMaxFailures: 5,
+			LoginLockout:     time.Minute,
+			LoginMaxLockout:  time.Hour,
+		},
+		PubSub: PubSubConfig{
+			Broker:   "memory",
+			RedisURL: "redis://localhost:6379/0",
+			Channel:  "runnerx:events",
+		},
+		Cluster: ClusterConfig{
+			InstanceID:        processInstanceID,
+			HeartbeatInterval: 5 * time.Second,
+			MemberTTL:         15 * time.Second,
+		},
+		CORS: CORSConfig{
+			Origins: []string{"http://localhost:3000", "http://localhost:3001"},
+		},
+		Monitoring: MonitoringConfig{
+			SchedulerTick:           10 * time.Second,
+			NotificationSuppression: 5 * time.Minute,
+			ScreenshotTimeout:       10 * time.Second,
+		},
+		Commands: CommandsConfig{
+			Timeout: 30 * time.Second,
+		},
+		Analytics: AnalyticsConfig{
+			Interval:     time.Hour,
+			MoodInterval: time.Minute,
+		},
+		TLS: TLSConfig{
+			HSTSMaxAge: 180 * 24 * time.Hour,
+			ACME: ACMEConfig{
+				DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+				CacheDir:     "./certs",
+			},
+		},
+		Tracing: TracingConfig{
+			ServiceName: "runnerx",
+			Headers:     map[string]string{},
+			SampleRatio: 1,
+		},
+		AppURL:          "http://localhost:3000",
+		ProbeLocation:   "local",
+		ShutdownTimeout: 30 * time.Second,
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Load builds the configuration from the defaults, then the YAML file at path
+// if one is given, then the environment, and validates the result
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		// A misspelt setting would otherwise be silently ignored
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
 	}
-	return defaultValue
+	cfg.AppURL = strings.TrimRight(cfg.AppURL, "/")
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
+// applyEnv overrides settings with the environment variables that are set
+func applyEnv(cfg *Config) error {
+	e := &envReader{}
+	e.string("PORT", &cfg.Port)
+	e.string("DATABASE_URL", &cfg.DatabaseURL)
+	e.string("JWT_SECRET", &cfg.JWTSecret)
+	e.bool("DEV_MODE", &cfg.DevMode)
+
+	e.string("OIDC_ISSUER", &cfg.OIDC.Issuer)
+	e.string("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	e.string("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	e.string("OIDC_REDIRECT_URL", &cfg.OIDC.RedirectURL)
+	if value, ok := os.LookupEnv("OIDC_SCOPES"); ok && value != "" {
+		cfg.OIDC.Scopes = strings.Fields(value)
+	}
+	e.string("OIDC_FRONTEND_URL", &cfg.OIDC.FrontendURL)
+	e.string("OIDC_ROLE_CLAIM", &cfg.OIDC.RoleClaim)
+	if value, ok := os.LookupEnv("OIDC_ROLE_MAPPING"); ok && value != "" {
+		cfg.OIDC.RoleMapping = parseMapping(value)
+	}
+	e.string("OIDC_DEFAULT_ROLE", &cfg.OIDC.DefaultRole)
+
+	e.string("SMTP_HOST", &cfg.Mail.Host)
+	e.string("SMTP_PORT", &cfg.Mail.Port)
+	e.string("SMTP_USERNAME", &cfg.Mail.Username)
+	e.string("SMTP_PASSWORD", &cfg.Mail.Password)
+	e.string("MAIL_FROM", &cfg.Mail.From)
+
+	e.string("RATE_LIMIT_STORE", &cfg.RateLimit.Store)
+	e.rate("RATE_LIMIT_IP", &cfg.RateLimit.IP)
+	e.rate("RATE_LIMIT_AUTH", &cfg.RateLimit.Auth)
+	e.rate("RATE_LIMIT_REGISTER", &cfg.RateLimit.Register)
+	e.rate("RATE_LIMIT_USER", &cfg.RateLimit.User)
+	e.rate("RATE_LIMIT_TOKEN", &cfg.RateLimit.Token)
+	e.rate("RATE_LIMIT_EXPENSIVE", &cfg.RateLimit.Expensive)
+	e.int("LOGIN_MAX_FAILURES", &cfg.RateLimit.LoginMaxFailures)
+	e.duration("LOGIN_LOCKOUT", &cfg.RateLimit.LoginLockout)
+	e.duration("LOGIN_MAX_LOCKOUT", &cfg.RateLimit.LoginMaxLockout)
+
+	e.string("PUBSUB_BROKER", &cfg.PubSub.Broker)
+	e.string("REDIS_URL", &cfg.PubSub.RedisURL)
+	e.string("PUBSUB_CHANNEL", &cfg.PubSub.Channel)
+
+	e.string("INSTANCE_ID", &cfg.Cluster.InstanceID)
+	e.duration("CLUSTER_HEARTBEAT", &cfg.Cluster.HeartbeatInterval)
+	e.duration("CLUSTER_MEMBER_TTL", &cfg.Cluster.MemberTTL)
+
+	if value, ok := os.LookupEnv("CORS_ORIGINS"); ok && value != "" {
+		cfg.CORS.Origins = splitList(value)
+	}
+	e.duration("MONITOR_SCHEDULER_TICK", &cfg.Monitoring.SchedulerTick)
+	e.duration("NOTIFICATION_SUPPRESSION", &cfg.Monitoring.NotificationSuppression)
+	e.duration("SCREENSHOT_TIMEOUT", &cfg.Monitoring.ScreenshotTimeout)
+	e.duration("COMMAND_TIMEOUT", &cfg.Commands.Timeout)
+	e.duration("ANALYTICS_INTERVAL", &cfg.Analytics.Interval)
+	e.duration("MOOD_INTERVAL", &cfg.Analytics.MoodInterval)
+
+	e.string("TLS_CERT_FILE", &cfg.TLS.CertFile)
+	e.string("TLS_KEY_FILE", &cfg.TLS.KeyFile)
+	e.string("TLS_HTTP_PORT", &cfg.TLS.HTTPPort)
+	e.duration("HSTS_MAX_AGE", &cfg.TLS.HSTSMaxAge)
+	e.bool("HSTS_INCLUDE_SUBDOMAINS", &cfg.TLS.HSTSIncludeSubdomains)
+	if value, ok := os.LookupEnv("ACME_DOMAINS"); ok && value != "" {
+		cfg.TLS.ACME.Domains = splitList(value)
+	}
+	e.string("ACME_EMAIL", &cfg.TLS.ACME.Email)
+	e.string("ACME_DIRECTORY_URL", &cfg.TLS.ACME.DirectoryURL)
+	e.string("ACME_CACHE_DIR", &cfg.TLS.ACME.CacheDir)
+	e.string("ACME_CA_FILE", &cfg.TLS.ACME.CAFile)
+
+	e.string("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.Tracing.Endpoint)
+	e.string("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	if value, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_HEADERS"); ok && value != "" {
+		cfg.Tracing.Headers = parseMapping(value)
+	}
+	e.float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	e.string("APP_URL", &cfg.AppURL)
+	e.bool("REQUIRE_EMAIL_VERIFICATION", &cfg.RequireEmailVerification)
+	e.bool("WEBHOOK_ALLOW_PRIVATE", &cfg.AllowPrivateWebhooks)
+	e.string("PROBE_LOCATION", &cfg.ProbeLocation)
+	e.duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	return errors.Join(e.errs...)
+}
+
+// processInstanceID is unique per process, so a restarted instance joins as
+// a new member, and stays the same when the configuration is reloaded
+var processInstanceID = defaultInstanceID()
+
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "runnerx"
+	}
+	return fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()%1000000)
+}
+
+// envReader reads environment variables into settings, collecting the values
+// that do not parse. Unset and empty variables leave the setting alone.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	return strings.TrimSpace(value), ok && strings.TrimSpace(value) != ""
+}
+
+func (e *envReader) fail(key, value string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("invalid %s %q: %v", key, value, err))
+}
+
+func (e *envReader) string(key string, out *string) {
+	if value, ok := e.lookup(key); ok {
+		*out = value
+	}
+}
+
+func (e *envReader) bool(key string, out *bool) {
+	if value, ok := e.lookup(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.fail(key, value, err)
+			return
+		}
+		*out = b
+	}
+}
+
+func (e *envReader) int(key string, out *int) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(key, value, err)
+			return
+		}
+		*out = n
+	}
+}
+
+func (e *envReader) float(key string, out *float64) {
+	if value, ok := e.lookup(key); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.fail(key, value, err)
+			return
+		}
+		*out = f
+	}
+}
+
+func (e *envReader) duration(key string, out *time.Duration) {
+	if value, ok := e.lookup(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(key, value, err)
+			return
+		}
+		*out = d
+	}
+}
+
+func (e *envReader) rate(key string, out *Rate) {
+	if value, ok := e.lookup(key); ok {
+		rate, err := parseRate(value)
+		if err != nil {
+			e.fail(key, value, err)
+			return
+		}
+		*out = rate
+	}
+}
+
+// parseMapping parses "key=value,key=value" pairs
+func parseMapping(raw string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if key, value = strings.TrimSpace(key), strings.TrimSpace(value); key != "" && value != "" {
+			mapping[key] = value
+		}
+	}
+	return mapping
+}
+
+// splitList parses a comma separated list
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseRate(raw string) (Rate, error) {
+	requests, window, ok := strings.Cut(raw, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("expected requests/window")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 1 {
+		return Rate{}, fmt.Errorf("invalid request count %q", requests)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid window %q", window)
+	}
+	return Rate{Requests: n, Window: d}, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import "reflect"
+
+// Reload returns the configuration to run with once next was loaded on
+// SIGHUP: the settings that can change while the server runs come from next,
+// everything else stays as c. The names of the settings that changed in next
+// but only apply after a restart are returned too.
+//
+// Reloadable are the CORS origins, the rate limit budgets and lockouts, the
+// monitoring, command and analytics timings.
+func (c *Config) Reload(next *Config) (*Config, []string) {
+	merged := *c
+	merged.CORS = next.CORS
+	merged.RateLimit = next.RateLimit
+	merged.RateLimit.Store = c.RateLimit.Store
+	merged.Monitoring = next.Monitoring
+	merged.Commands = next.Commands
+	merged.Analytics = next.Analytics
+
+	var restart []string
+	if next.RateLimit.Store != c.RateLimit.Store {
+		restart = append(restart, "rate_limit.store")
+	}
+	current, wanted := reflect.ValueOf(merged), reflect.ValueOf(*next)
+	for i := 0; i < current.NumField(); i++ {
+		if !reflect.DeepEqual(current.Field(i).Interface(), wanted.Field(i).Interface()) {
+			field := current.Type().Field(i)
+			if field.Name == "RateLimit" {
+				continue
+			}
+			restart = append(restart, field.Tag.Get("yaml"))
+		}
+	}
+	return &merged, restart
+}
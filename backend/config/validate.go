@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Validate reports every setting that is missing, out of range or unsafe
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.port("port", c.Port)
+	v.check(c.DatabaseURL != "", "database_url is required")
+	switch {
+	case c.JWTSecret == "":
+		v.fail("jwt_secret is required")
+	case c.JWTSecret == DefaultJWTSecret && !c.DevMode:
+		v.fail("jwt_secret is the placeholder value; set a secret or enable dev_mode")
+	}
+	v.url("app_url", c.AppURL)
+	v.positive("shutdown_timeout", c.ShutdownTimeout)
+
+	if c.OIDC.Enabled() {
+		v.url("oidc.redirect_url", c.OIDC.RedirectURL)
+		v.url("oidc.frontend_url", c.OIDC.FrontendURL)
+	}
+	v.check(c.OIDC.DefaultRole == "user" || c.OIDC.DefaultRole == "admin", "oidc.default_role must be user or admin")
+	for value, role := range c.OIDC.RoleMapping {
+		v.check(role == "user" || role == "admin", "oidc.role_mapping: %s maps to %q, expected user or admin", value, role)
+	}
+	if c.Mail.Host != "" {
+		v.port("mail.port", c.Mail.Port)
+	}
+
+	rl := c.RateLimit
+	v.check(rl.Store == "memory" || rl.Store == "database", "rate_limit.store must be memory or database")
+	for _, tier := range []struct {
+		name string
+		rate Rate
+	}{
+		{"ip", rl.IP}, {"auth", rl.Auth}, {"register", rl.Register},
+		{"user", rl.User}, {"token", rl.Token}, {"expensive", rl.Expensive},
+	} {
+		v.check(tier.rate.Requests > 0 && tier.rate.Window > 0, "rate_limit.%s must be requests/window with both above zero", tier.name)
+	}
+	v.check(rl.LoginMaxFailures >= 1, "rate_limit.login_max_failures must be at least 1")
+	v.positive("rate_limit.login_lockout", rl.LoginLockout)
+	v.check(rl.LoginMaxLockout >= rl.LoginLockout, "rate_limit.login_max_lockout must not be shorter than login_lockout")
+
+	v.check(c.PubSub.Broker == "memory" || c.PubSub.Broker == "redis", "pubsub.broker must be memory or redis")
+	if c.PubSub.Broker == "redis" {
+		v.check(c.PubSub.Channel != "", "pubsub.channel is required with the redis broker")
+	}
+
+	v.check(c.Cluster.InstanceID != "", "cluster.instance_id is required")
+	v.positive("cluster.heartbeat_interval", c.Cluster.HeartbeatInterval)
+	v.check(c.Cluster.MemberTTL > c.Cluster.HeartbeatInterval, "cluster.member_ttl must be longer than heartbeat_interval")
+
+	v.check(len(c.CORS.Origins) > 0, "cors.origins needs at least one origin")
+	for _, origin := range c.CORS.Origins {
+		u, err := url.Parse(origin)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/"),
+			"cors.origins: %q is not an origin such as https://runnerx.example.com", origin)
+	}
+
+	v.atLeast("monitoring.scheduler_tick", c.Monitoring.SchedulerTick, time.Second)
+	v.check(c.Monitoring.NotificationSuppression >= 0, "monitoring.notification_suppression must not be negative")
+	v.positive("monitoring.screenshot_timeout", c.Monitoring.ScreenshotTimeout)
+	v.positive("commands.timeout", c.Commands.Timeout)
+	v.atLeast("analytics.interval", c.Analytics.Interval, time.Minute)
+	v.atLeast("analytics.mood_interval", c.Analytics.MoodInterval, 10*time.Second)
+
+	c.validateTLS(v)
+
+	if c.Tracing.Enabled() {
+		v.url("tracing.endpoint", c.Tracing.Endpoint)
+		v.check(c.Tracing.ServiceName != "", "tracing.service_name is required with tracing")
+	}
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	return errors.Join(v.errs...)
+}
+
+func (c *Config) validateTLS(v *validator) {
+	t := c.TLS
+	v.check((t.CertFile == "") == (t.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	v.check(t.CertFile == "" || !t.ACME.Enabled(), "tls.cert_file and tls.acme.domains cannot both be set")
+	v.check(t.HSTSMaxAge >= 0, "tls.hsts_max_age must not be negative")
+	if t.HTTPPort != "" {
+		v.check(t.Enabled(), "tls.http_port needs a certificate or ACME")
+		v.port("tls.http_port", t.HTTPPort)
+		v.check(t.HTTPPort != c.Port, "tls.http_port must differ from port")
+	}
+	if t.ACME.Enabled() {
+		for _, domain := range t.ACME.Domains {
+			v.check(hostnamePattern.MatchString(domain), "tls.acme.domains: %q is not a hostname", domain)
+		}
+		v.url("tls.acme.directory_url", t.ACME.DirectoryURL)
+		v.check(t.ACME.CacheDir != "", "tls.acme.cache_dir is required with ACME")
+	}
+}
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.fail(format, args...)
+	}
+}
+
+func (v *validator) port(name, value string) {
+	n, err := strconv.Atoi(value)
+	v.check(err == nil && n > 0 && n < 65536, "%s must be a port number, got %q", name, value)
+}
+
+func (v *validator) url(name, value string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && u.Scheme != "" && u.Host != "", "%s must be an absolute URL, got %q", name, value)
+}
+
+func (v *validator) positive(name string, d time.Duration) {
+	v.check(d > 0, "%s must be above zero", name)
+}
+
+func (v *validator) atLeast(name string, d, least time.Duration) {
+	v.check(d >= least, "%s must be at least %s", name, least)
+}
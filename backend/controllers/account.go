@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/services"
+	ws "runnerx/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type DeleteAccountRequest struct {
+	// Password may be left out by SSO users who signed in recently
+	Password string `json:"password"`
+	SecondFactorRequest
+}
+
+// SSO users have no password of their own, so signing in again within this
+// window confirms the deletion of their account instead
+const reauthWindow = 5 * time.Minute
+
+// AccountController handles email verification, password changes and resets,
+// and account deletion and export
+type AccountController struct {
+	DB       *gorm.DB
+	Hub      *ws.Hub
+	Mailer   *services.Mailer
+	Accounts *services.AccountService
+	AppURL   string
+}
+
+func NewAccountController(db *gorm.DB, hub *ws.Hub, mailer *services.Mailer, appURL string) *AccountController {
+	return &AccountController{
+		DB:       db,
+		Hub:      hub,
+		Mailer:   mailer,
+		Accounts: services.NewAccountService(db),
+		AppURL:   appURL,
+	}
+}
+
+// sendVerificationEmail issues a verification token and mails a link to it
+func sendVerificationEmail(db *gorm.DB, mailer *services.Mailer, appURL string, user *models.User) error {
+	token, err := models.IssueUserToken(db, user, models.UserTokenEmailVerification, models.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := appURL + "/verify-email?token=" + url.QueryEscape(token)
+	mailer.SendAsync(user.Email, "Verify your RunnerX email address", fmt.Sprintf(
+		"Hi %s,\n\nConfirm your email address by opening this link within 24 hours:\n\n%s\n\nIf you did not create a RunnerX account, ignore this email.\n",
+		user.Name, link))
+	return nil
+}
+
+// VerifyEmail marks the address the token was sent to as verified
+func (ac *AccountController) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := models.ConsumeUserToken(ac.DB, req.Token, models.UserTokenEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+
+	// The link only verifies the address it was sent to
+	result := ac.DB.Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", token.UserID, token.Email).
+		Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification sends a new verification link. The response is the same
+// whether or not the address belongs to an account.
+func (ac *AccountController) ResendVerification(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := ac.DB.Where("LOWER(email) = ?", strings.ToLower(req.Email)).First(&user).Error; err == nil && !user.EmailVerified() {
+		if err := sendVerificationEmail(ac.DB, ac.Mailer, ac.AppURL, &user); err != nil {
+			log.Printf("Failed to issue verification token for user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an unverified account, a verification email is on its way"})
+}
+
+// ForgotPassword mails a password reset link. The response is the same
+// whether or not the address belongs to an account.
+func (ac *AccountController) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := ac.DB.Where("LOWER(email) = ?", strings.ToLower(req.Email)).First(&user).Error; err == nil {
+		token, err := models.IssueUserToken(ac.DB, &user, models.UserTokenPasswordReset, models.PasswordResetTTL)
+		if err != nil {
+			log.Printf("Failed to issue password reset token for user %d: %v", user.ID, err)
+		} else {
+			link := ac.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+			ac.Mailer.SendAsync(user.Email, "Reset your RunnerX password", fmt.Sprintf(
+				"Hi %s,\n\nSomeone asked to reset the password of your RunnerX account. Choose a new password within an hour:\n\n%s\n\nIf it was not you, ignore this email; your password stays the same.\n",
+				user.Name, link))
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an account, a password reset email is on its way"})
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out
+// everywhere
+func (ac *AccountController) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := models.ConsumeUserToken(ac.DB, req.Token, models.UserTokenPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+		return
+	}
+
+	var user models.User
+	if err := ac.DB.First(&user, token.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	// Receiving the reset email proves the address is the user's
+	if !user.EmailVerified() && strings.EqualFold(user.Email, token.Email) {
+		updates["email_verified_at"] = time.Now()
+	}
+	if err := ac.setPassword(&user, req.Password, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	revokeSessions(ac.DB, ac.Hub, "user_id = ?", user.ID)
+	models.RecordAudit(ac.DB, userActor(c, &user), models.AuditPasswordReset, "user", user.ID, true, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please sign in again"})
+}
+
+// ChangePassword updates the password of the current user and ends their
+// other sessions
+func (ac *AccountController) ChangePassword(c *gin.Context) {
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.CheckPassword(req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if err := ac.setPassword(user, req.NewPassword, map[string]interface{}{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	sessionID, _ := middleware.GetSessionID(c)
+	revoked, _ := revokeSessions(ac.DB, ac.Hub, "user_id = ? AND id <> ?", user.ID, sessionID)
+	recordAudit(ac.DB, c, models.AuditPasswordChange, "user", user.ID, gin.H{"sessions_revoked": revoked})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully", "sessions_revoked": revoked})
+}
+
+// DeleteAccount permanently deletes the current user and their personal data
+func (ac *AccountController) DeleteAccount(c *gin.Context) {
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case req.Password != "":
+		if !user.CheckPassword(req.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+	case user.OIDCSubject != nil:
+		if !ac.recentLogin(c) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                     "Sign in again with single sign-on to delete your account",
+				"reauthentication_required": true,
+			})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is required"})
+		return
+	}
+	if user.TOTPEnabled && !verifySecondFactor(ac.DB, user, req.SecondFactorRequest) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	teamIDs, err := ac.Accounts.SoleOwnedTeams(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	if len(teamIDs) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "You are the only owner of some teams; transfer ownership or delete them first",
+			"team_ids": teamIDs,
+		})
+		return
+	}
+
+	// End every session first so open connections are closed
+	revokeSessions(ac.DB, ac.Hub, "user_id = ?", user.ID)
+	if err := ac.Accounts.DeleteAccount(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	// The entry outlives the account and keeps its email for reviews
+	models.RecordAudit(ac.DB, userActor(c, user), models.AuditAccountDelete, "user", user.ID, true, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
+
+// ExportAccount downloads the current user's data as a JSON document
+func (ac *AccountController) ExportAccount(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	export, err := ac.Accounts.ExportAccount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+
+	filename := fmt.Sprintf("runnerx-account-%d-%s.json", userID, export.ExportedAt.Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.JSON(http.StatusOK, export)
+}
+
+// setPassword hashes and stores a new password along with any other updates
+func (ac *AccountController) setPassword(user *models.User, password string, updates map[string]interface{}) error {
+	user.Password = password
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+	updates["password"] = user.Password
+	return ac.DB.Model(user).Updates(updates).Error
+}
+
+// recentLogin reports whether the current session was started within
+// reauthWindow
+func (ac *AccountController) recentLogin(c *gin.Context) bool {
+	sessionID, ok := middleware.GetSessionID(c)
+	if !ok {
+		return false
+	}
+	var session models.Session
+	if err := ac.DB.First(&session, sessionID).Error; err != nil {
+		return false
+	}
+	return time.Since(session.CreatedAt) < reauthWindow
+}
+
+func (ac *AccountController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := middleware.GetUserID(c)
+
+	var user models.User
+	if err := ac.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/probe"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AgentPollInterval is how often agents are told to fetch their monitors
+const AgentPollInterval = 30 * time.Second
+
+// Results older than this are dropped rather than recorded out of order
+const maxResultAge = 10 * time.Minute
+
+// Results dated further ahead than this are dropped; agent clocks may drift a
+// little, but a result from the future would hold the monitor's status
+const maxResultSkew = time.Minute
+
+type AgentController struct {
+	DB             *gorm.DB
+	monitorService *services.MonitorService
+}
+
+func NewAgentController(db *gorm.DB, monitorService *services.MonitorService) *AgentController {
+	return &AgentController{DB: db, monitorService: monitorService}
+}
+
+type CreateAgentRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Location string `json:"location" binding:"required"`
+}
+
+type RegisterAgentRequest struct {
+	Version  string `json:"version" binding:"max=50"`
+	Hostname string `json:"hostname" binding:"max=255"`
+}
+
+// AgentResult is one check result reported by an agent
+type AgentResult struct {
+	MonitorID uint `json:"monitor_id" binding:"required"`
+	probe.Result
+	CheckedAt time.Time `json:"checked_at" binding:"required"`
+}
+
+type AgentResultsRequest struct {
+	Results []AgentResult `json:"results" binding:"required,max=500,dive"`
+}
+
+// knownLocations returns the locations monitors may be assigned to: the
+// server's own and those of registered agents
+func knownLocations(db *gorm.DB, local string) map[string]bool {
+	known := map[string]bool{local: true}
+	var locations []string
+	db.Model(&models.Agent{}).Distinct().Pluck("location", &locations)
+	for _, location := range locations {
+		known[location] = true
+	}
+	return known
+}
+
+// GetAgents lists all probe agents
+func (ac *AgentController) GetAgents(c *gin.Context) {
+	var agents []models.Agent
+	if err := ac.DB.Order("location, name").Find(&agents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch agents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, agents)
+}
+
+// CreateAgent registers an agent for a location. The plain token is only
+// returned in this response.
+func (ac *AgentController) CreateAgent(c *gin.Context) {
+	var req CreateAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidLocation(req.Location) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "location must be lowercase letters, digits and dashes, at most 32 characters"})
+		return
+	}
+
+	plain, hash, err := models.GenerateSecretToken(models.AgentTokenPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	agent := models.Agent{
+		Name:      req.Name,
+		Location:  req.Location,
+		Prefix:    plain[:len(models.AgentTokenPrefix)+6],
+		TokenHash: hash,
+	}
+	if err := ac.DB.Create(&agent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+		return
+	}
+	recordAudit(ac.DB, c, models.AuditAgentCreate, "agent", agent.ID, gin.H{
+		"name": agent.Name, "location": agent.Location,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token": plain,
+		"agent": agent,
+	})
+}
+
+// DeleteAgent revokes an agent's token. Monitors keep their locations; a
+// location without agents simply stops reporting.
+func (ac *AgentController) DeleteAgent(c *gin.Context) {
+	var agent models.Agent
+	if err := ac.DB.Where("id = ?", c.Param("id")).First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	if err := ac.DB.Delete(&agent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete agent"})
+		return
+	}
+	recordAudit(ac.DB, c, models.AuditAgentDelete, "agent", agent.ID, gin.H{
+		"name": agent.Name, "location": agent.Location,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Agent deleted successfully"})
+}
+
+// GetLocations lists the locations monitors can be checked from, with the
+// number of agents online at each
+func (ac *AgentController) GetLocations(c *gin.Context) {
+	local := ac.monitorService.Location()
+	type location struct {
+		Name         string `json:"name"`
+		Local        bool   `json:"local"`
+		Agents       int    `json:"agents"`
+		OnlineAgents int    `json:"online_agents"`
+	}
+	byName := map[string]*location{local: {Name: local, Local: true}}
+
+	var agents []models.Agent
+	if err := ac.DB.Find(&agents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+	for _, agent := range agents {
+		l := byName[agent.Location]
+		if l == nil {
+			l = &location{Name: agent.Location}
+			byName[agent.Location] = l
+		}
+		l.Agents++
+		if agent.Online {
+			l.OnlineAgents++
+		}
+	}
+
+	locations := make([]location, 0, len(byName))
+	for _, l := range byName {
+		locations = append(locations, *l)
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+	c.JSON(http.StatusOK, locations)
+}
+
+// GetMonitorLocations returns the latest result of a monitor at each of its
+// locations
+func (ac *AgentController) GetMonitorLocations(c *gin.Context) {
+	var monitor models.Monitor
+	if err := ac.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", c.Param("id")).First(&monitor).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
+		return
+	}
+
+	var statuses []models.MonitorLocationStatus
+	if len(monitor.Locations) > 0 {
+		if err := ac.DB.Where("monitor_id = ? AND location IN ?", monitor.ID, []string(monitor.Locations)).
+			Order("location").Find(&statuses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location statuses"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locations":            monitor.ProbeLocations(ac.monitorService.Location()),
+		"min_failed_locations": monitor.MinFailedLocations,
+		"statuses":             statuses,
+	})
+}
+
+// RegisterAgent is called by an agent when it starts
+func (ac *AgentController) RegisterAgent(c *gin.Context) {
+	agent, _ := middleware.GetAgent(c)
+
+	var req RegisterAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	agent.Version, agent.Hostname = req.Version, req.Hostname
+	if err := ac.DB.Model(agent).UpdateColumns(map[string]interface{}{
+		"version":  req.Version,
+		"hostname": req.Hostname,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register agent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"agent":                 agent,
+		"poll_interval_seconds": int(AgentPollInterval.Seconds()),
+	})
+}
+
+// assignedMonitors returns the enabled monitors checked from location
+func (ac *AgentController) assignedMonitors(location string, ids []uint) ([]models.Monitor, error) {
+	query := ac.DB.Where("enabled = ? AND locations LIKE ?", true, `%"`+location+`"%`)
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+	var candidates []models.Monitor
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	monitors := candidates[:0]
+	for _, monitor := range candidates {
+		if probe.Supports(monitor.Type) && len(monitor.Locations) > 0 && monitor.ChecksAt(location, "") {
+			monitors = append(monitors, monitor)
+		}
+	}
+	return monitors, nil
+}
+
+// GetAgentMonitors returns what the agent is to check: only the settings the
+// checks need
+func (ac *AgentController) GetAgentMonitors(c *gin.Context) {
+	agent, _ := middleware.GetAgent(c)
+
+	monitors, err := ac.assignedMonitors(agent.Location, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitors"})
+		return
+	}
+
+	items := make([]gin.H, 0, len(monitors))
+	for _, m := range monitors {
+		items = append(items, gin.H{
+			"id":               m.ID,
+			"type":             m.Type,
+			"endpoint":         m.Endpoint,
+			"method":           m.Method,
+			"interval_seconds": m.IntervalSeconds,
+			"timeout":          m.Timeout,
+			"headers_json":     m.HeadersJSON,
+			"updated_at":       m.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"location":              agent.Location,
+		"monitors":              items,
+		"poll_interval_seconds": int(AgentPollInterval.Seconds()),
+	})
+}
+
+// PostAgentResults records a batch of results. Results for monitors no
+// longer assigned to the agent's location, too old to matter or dated in the
+// future, are dropped.
+func (ac *AgentController) PostAgentResults(c *gin.Context) {
+	agent, _ := middleware.GetAgent(c)
+
+	var req AgentResultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := make([]uint, 0, len(req.Results))
+	for _, result := range req.Results {
+		ids = append(ids, result.MonitorID)
+	}
+	monitors, err := ac.assignedMonitors(agent.Location, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitors"})
+		return
+	}
+	assigned := make(map[uint]bool, len(monitors))
+	for _, m := range monitors {
+		assigned[m.ID] = true
+	}
+
+	// Oldest first, so the last result of a monitor sets its status
+	sort.SliceStable(req.Results, func(i, j int) bool { return req.Results[i].CheckedAt.Before(req.Results[j].CheckedAt) })
+	accepted := 0
+	now := time.Now()
+	for _, result := range req.Results {
+		if !assigned[result.MonitorID] || now.Sub(result.CheckedAt) > maxResultAge || result.CheckedAt.Sub(now) > maxResultSkew {
+			continue
+		}
+		if result.Status != "up" && result.Status != "down" {
+			continue
+		}
+		ac.monitorService.RecordResult(c.Request.Context(), result.MonitorID, agent.Location, result.Result)
+		accepted++
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"accepted": accepted,
+		"dropped":  len(req.Results) - accepted,
+	})
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"runnerx/config"
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/probe"
+	"runnerx/services"
+	ws "runnerx/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// agentTestEnv runs several agents against one server, the way they run side
+// by side on a single machine during development
+type agentTestEnv struct {
+	db      *gorm.DB
+	router  *gin.Engine
+	tokens  map[string]string
+	monitor models.Monitor
+}
+
+func newAgentTestEnv(t *testing.T, locations []string, minFailed int) *agentTestEnv {
+	t.Helper()
+	db := newTestDB(t)
+	hub, err := ws.NewHub(ws.NewMemoryBroker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitorService := services.NewMonitorService(db, hub, nil, nil, config.MonitoringConfig{}, "local", nil)
+
+	router := gin.New()
+	agentController := NewAgentController(db, monitorService)
+	agent := router.Group("/api/agent", middleware.AgentAuth(db))
+	agent.POST("/results", agentController.PostAgentResults)
+
+	env := &agentTestEnv{db: db, router: router, tokens: map[string]string{}}
+	for _, location := range locations {
+		plain, hash, err := models.GenerateSecretToken(models.AgentTokenPrefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.Agent{Name: location, Location: location, Prefix: plain[:10], TokenHash: hash}).Error; err != nil {
+			t.Fatal(err)
+		}
+		env.tokens[location] = plain
+	}
+
+	user := models.User{Name: "Owner", Email: "owner@example.com", Password: "password"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	env.monitor = models.Monitor{
+		UserID:             user.ID,
+		Name:               "api",
+		Type:               "tcp",
+		Endpoint:           "example.com:443",
+		IntervalSeconds:    60,
+		Timeout:            5,
+		Enabled:            true,
+		Locations:          locations,
+		MinFailedLocations: minFailed,
+	}
+	if err := db.Create(&env.monitor).Error; err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// report posts one result from the agent at location and returns how many
+// results the server accepted
+func (env *agentTestEnv) report(t *testing.T, location, status string, checkedAt time.Time) int {
+	t.Helper()
+	body, _ := json.Marshal(AgentResultsRequest{Results: []AgentResult{{
+		MonitorID: env.monitor.ID,
+		Result:    probe.Result{Status: status, LatencyMs: 10},
+		CheckedAt: checkedAt,
+	}}})
+	req := httptest.NewRequest(http.MethodPost, "/api/agent/results", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+env.tokens[location])
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("%s: got %d: %s", location, rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Accepted int `json:"accepted"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Accepted
+}
+
+func (env *agentTestEnv) status(t *testing.T) string {
+	t.Helper()
+	var monitor models.Monitor
+	if err := env.db.First(&monitor, env.monitor.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return monitor.Status
+}
+
+func TestAgentsDecideStatusTogether(t *testing.T) {
+	env := newAgentTestEnv(t, []string{"eu-west", "us-east", "ap-south"}, 2)
+	now := time.Now()
+
+	steps := []struct {
+		location string
+		status   string
+		want     string
+	}{
+		// One location cannot make up the quorum of two
+		{"eu-west", "up", "pending"},
+		{"us-east", "up", "up"},
+		{"ap-south", "up", "up"},
+		{"eu-west", "down", "up"},
+		{"us-east", "down", "down"},
+		{"eu-west", "up", "up"},
+	}
+	for i, step := range steps {
+		if env.report(t, step.location, step.status, now) != 1 {
+			t.Fatalf("step %d: result from %s was dropped", i, step.location)
+		}
+		if got := env.status(t); got != step.want {
+			t.Fatalf("step %d: %s reported %s, monitor is %s, want %s", i, step.location, step.status, got, step.want)
+		}
+	}
+}
+
+func TestAgentsKeepStatusWithoutQuorum(t *testing.T) {
+	env := newAgentTestEnv(t, []string{"eu-west", "us-east", "ap-south"}, 2)
+	now := time.Now()
+	env.report(t, "eu-west", "down", now)
+	env.report(t, "us-east", "down", now)
+	if got := env.status(t); got != "down" {
+		t.Fatalf("monitor is %s, want down", got)
+	}
+
+	// The other locations go quiet, so a single location cannot decide
+	env.db.Model(&models.MonitorLocationStatus{}).Where("location <> ?", "eu-west").
+		Update("checked_at", now.Add(-time.Hour))
+	env.report(t, "eu-west", "up", now)
+	if got := env.status(t); got != "down" {
+		t.Fatalf("monitor is %s with one fresh location, want the previous down", got)
+	}
+
+	env.report(t, "ap-south", "up", now)
+	if got := env.status(t); got != "up" {
+		t.Fatalf("monitor is %s, want up", got)
+	}
+}
+
+func TestAgentResultsRejectFutureTimestamps(t *testing.T) {
+	env := newAgentTestEnv(t, []string{"eu-west"}, 1)
+	now := time.Now()
+
+	if env.report(t, "eu-west", "down", now.Add(time.Hour)) != 0 {
+		t.Fatal("result dated an hour ahead was accepted")
+	}
+	if env.report(t, "eu-west", "down", now.Add(-time.Hour)) != 0 {
+		t.Fatal("result an hour old was accepted")
+	}
+	// A little clock drift is fine
+	if env.report(t, "eu-west", "down", now.Add(10*time.Second)) != 1 {
+		t.Fatal("result with small clock skew was dropped")
+	}
+	if got := env.status(t); got != "down" {
+		t.Fatalf("monitor is %s, want down", got)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APITokenController struct {
+	DB *gorm.DB
+}
+
+func NewAPITokenController(db *gorm.DB) *APITokenController {
+	return &APITokenController{DB: db}
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=3650"`
+}
+
+// GetAPITokens lists the current user's personal access tokens
+func (tc *APITokenController) GetAPITokens(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var tokens []models.APIToken
+	if err := tc.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAPIToken issues a new personal access token. The plain token is only
+// returned in this response.
+func (tc *APITokenController) CreateAPIToken(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes := models.StringArray{}
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope, "valid_scopes": models.ValidScopes})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	plain, hash, err := models.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	apiToken := models.APIToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    plain[:len(models.APITokenPrefix)+6],
+		TokenHash: hash,
+		Scopes:    scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	if err := tc.DB.Create(&apiToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return
+	}
+	recordAudit(tc.DB, c, models.AuditAPITokenCreate, "api_token", apiToken.ID, gin.H{"name": apiToken.Name, "scopes": apiToken.Scopes})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     plain,
+		"api_token": apiToken,
+	})
+}
+
+// RevokeAPIToken revokes a token immediately; revoked tokens are kept for reference
+func (tc *APITokenController) RevokeAPIToken(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	id := c.Param("id")
+
+	var apiToken models.APIToken
+	if err := tc.DB.Where("id = ? AND user_id = ?", id, userID).First(&apiToken).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+		return
+	}
+
+	if apiToken.RevokedAt == nil {
+		now := time.Now()
+		apiToken.RevokedAt = &now
+		if err := tc.DB.Save(&apiToken).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+			return
+		}
+		recordAudit(tc.DB, c, models.AuditAPITokenRevoke, "api_token", apiToken.ID, gin.H{"name": apiToken.Name})
+	}
+
+	c.JSON(http.StatusOK, apiToken)
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Paging limits of the audit endpoints
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	maxAuditExport    = 100000
+)
+
+// auditActor describes the authenticated caller of a request
+func auditActor(db *gorm.DB, c *gin.Context) models.AuditActor {
+	actor := models.AuditActor{
+		TeamID:    middleware.GetTeamIDPtr(c),
+		Email:     c.GetString("email"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if userID, ok := middleware.GetUserID(c); ok {
+		actor.UserID = &userID
+		// API token requests carry no email claim
+		if actor.Email == "" {
+			db.Model(&models.User{}).Where("id = ?", userID).Pluck("email", &actor.Email)
+		}
+	}
+	return actor
+}
+
+// userActor describes a request made on behalf of a user that is not yet
+// authenticated, such as a login
+func userActor(c *gin.Context, user *models.User) models.AuditActor {
+	return models.AuditActor{
+		UserID:    &user.ID,
+		Email:     user.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// recordAudit appends a successful action by the caller to the audit trail
+func recordAudit(db *gorm.DB, c *gin.Context, action, resourceType string, resourceID interface{}, details interface{}) {
+	models.RecordAudit(db, auditActor(db, c), action, resourceType, resourceID, true, details)
+}
+
+type AuditController struct {
+	DB *gorm.DB
+}
+
+func NewAuditController(db *gorm.DB) *AuditController {
+	return &AuditController{DB: db}
+}
+
+// GetAuditLogs lists audit entries, newest first. Inside a team it shows the
+// team's entries; otherwise the caller's own. Instance admins can pass
+// scope=all to see every entry.
+func (ac *AuditController) GetAuditLogs(c *gin.Context) {
+	query, ok := ac.filteredQuery(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditLimit)))
+	if limit < 1 || limit > maxAuditLimit {
+		limit = defaultAuditLimit
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	if err := query.Model(&models.AuditLog{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// ExportAuditLogs downloads the filtered entries, oldest first, as JSON or
+// CSV (format=csv)
+func (ac *AuditController) ExportAuditLogs(c *gin.Context) {
+	query, ok := ac.filteredQuery(c)
+	if !ok {
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id ASC").Limit(maxAuditExport).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit log"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	filename := "runnerx-audit-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	switch format {
+	case "json":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.JSON(http.StatusOK, entries)
+	case "csv":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "created_at", "user_id", "team_id", "actor_email", "action", "resource_type", "resource_id", "success", "ip", "user_agent", "details"})
+		for _, e := range entries {
+			w.Write(csvRow(
+				strconv.FormatUint(uint64(e.ID), 10),
+				e.CreatedAt.UTC().Format(time.RFC3339),
+				optionalID(e.UserID),
+				optionalID(e.TeamID),
+				e.ActorEmail,
+				e.Action,
+				e.ResourceType,
+				e.ResourceID,
+				strconv.FormatBool(e.Success),
+				e.IP,
+				e.UserAgent,
+				e.Details,
+			))
+		}
+		w.Flush()
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
+// filteredQuery scopes the audit log to what the caller may see and applies
+// the action, user_id, resource_type, resource_id, success, from and to filters
+func (ac *AuditController) filteredQuery(c *gin.Context) (*gorm.DB, bool) {
+	query := ac.DB.Model(&models.AuditLog{})
+	userID, _ := middleware.GetUserID(c)
+
+	if c.Query("scope") == "all" {
+		var user models.User
+		if err := ac.DB.First(&user, userID).Error; err != nil || !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Administrator access required"})
+			return nil, false
+		}
+	} else if teamID, ok := middleware.GetTeamID(c); ok {
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("user_id = ? AND team_id IS NULL", userID)
+	}
+
+	if action := c.Query("action"); action != "" {
+		// "monitor." matches every monitor action
+		if strings.HasSuffix(action, ".") {
+			query = query.Where("action LIKE ?", action+"%")
+		} else {
+			query = query.Where("action = ?", action)
+		}
+	}
+	if v := c.Query("user_id"); v != "" {
+		query = query.Where("user_id = ?", v)
+	}
+	if v := c.Query("resource_type"); v != "" {
+		query = query.Where("resource_type = ?", v)
+	}
+	if v := c.Query("resource_id"); v != "" {
+		query = query.Where("resource_id = ?", v)
+	}
+	if v := c.Query("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "success must be true or false"})
+			return nil, false
+		}
+		query = query.Where("success = ?", success)
+	}
+	for param, op := range map[string]string{"from": ">=", "to": "<"} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be an RFC 3339 timestamp", param)})
+			return nil, false
+		}
+		query = query.Where("created_at "+op+" ?", t.Local())
+	}
+
+	// The query is used for both counting and fetching
+	return query.Session(&gorm.Session{}), true
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// csvRow escapes cells that spreadsheets would run as formulas. Emails, user
+// agents and details come from clients, so a cell starting with =, +, - or @
+// gets a leading quote.
+func csvRow(cells ...string) []string {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	return cells
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import "testing"
+
+func TestCSVRowEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1+1", "'+1+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"a@example.com", "a@example.com"},
+		{"auth.login", "auth.login"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := csvRow(tt.cell)[0]; got != tt.want {
+			t.Errorf("csvRow(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"runnerx/middleware"
 	"runnerx/models"
+	"runnerx/services"
+	ws "runnerx/websocket"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -14,6 +20,18 @@ import (
 type AuthController struct {
 	DB        *gorm.DB
 	JWTSecret string
+	Hub       *ws.Hub
+
+	// Single sign-on; nil or disabled when OIDC is not configured
+	OIDC            *services.OIDCService
+	OIDCFrontendURL string
+
+	// Email verification of new accounts
+	Mailer                   *services.Mailer
+	AppURL                   string
+	RequireEmailVerification bool
+
+	guard *secondFactorGuard
 }
 
 type LoginRequest struct {
@@ -27,25 +45,32 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	jwt.RegisteredClaims
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
-func NewAuthController(db *gorm.DB, jwtSecret string) *AuthController {
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// NewAuthController counts failed second-factor attempts in store, normally
+// the one the rate limiter uses
+func NewAuthController(db *gorm.DB, jwtSecret string, hub *ws.Hub, store middleware.RateLimitStore) *AuthController {
 	return &AuthController{
 		DB:        db,
 		JWTSecret: jwtSecret,
+		Hub:       hub,
+		guard:     newSecondFactorGuard(store),
 	}
 }
 
-func (ac *AuthController) generateToken(user *models.User) (string, error) {
-	claims := Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+func (ac *AuthController) generateToken(user *models.User, sessionID uint) (string, error) {
+	claims := middleware.Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 7)), // 7 days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(models.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -54,6 +79,77 @@ func (ac *AuthController) generateToken(user *models.User) (string, error) {
 	return token.SignedString([]byte(ac.JWTSecret))
 }
 
+// startSession creates a login session for the request's device and returns
+// the token pair for it
+func (ac *AuthController) startSession(c *gin.Context, user *models.User) (gin.H, error) {
+	refreshToken, refreshHash, err := models.GenerateSecretToken(models.RefreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: refreshHash,
+		UserAgent:        c.Request.UserAgent(),
+		IP:               c.ClientIP(),
+		ExpiresAt:        now.Add(models.RefreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := ac.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return ac.tokenResponse(user, &session, refreshToken)
+}
+
+func (ac *AuthController) tokenResponse(user *models.User, session *models.Session, refreshToken string) (gin.H, error) {
+	token, err := ac.generateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(models.AccessTokenTTL.Seconds()),
+		"user": gin.H{
+			"id":             user.ID,
+			"name":           user.Name,
+			"email":          user.Email,
+			"role":           user.Role,
+			"email_verified": user.EmailVerified(),
+		},
+	}, nil
+}
+
+// revokeSessions revokes the matched sessions and closes their WebSocket connections
+func revokeSessions(db *gorm.DB, hub *ws.Hub, query interface{}, args ...interface{}) (int, error) {
+	ids, err := models.RevokeSessions(db, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		hub.DisconnectSession(id)
+	}
+	return len(ids), nil
+}
+
+// auditLogin records a login attempt; user is nil when the email is unknown
+func (ac *AuthController) auditLogin(c *gin.Context, user *models.User, email string, success bool, details gin.H) {
+	actor := models.AuditActor{Email: email, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	var resourceID interface{}
+	if user != nil {
+		actor = userActor(c, user)
+		resourceID = user.ID
+	}
+	action := models.AuditLoginFailed
+	if success {
+		action = models.AuditLogin
+	}
+	models.RecordAudit(ac.DB, actor, action, "user", resourceID, success, details)
+}
+
 func (ac *AuthController) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -64,32 +160,96 @@ func (ac *AuthController) Login(c *gin.Context) {
 	// Find user by email
 	var user models.User
 	if err := ac.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		ac.auditLogin(c, nil, req.Email, false, gin.H{"method": "password", "reason": "unknown_email"})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if !user.CheckPassword(req.Password) {
+		ac.auditLogin(c, &user, user.Email, false, gin.H{"method": "password", "reason": "invalid_password"})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate token
-	token, err := ac.generateToken(&user)
+	if ac.RequireEmailVerification && !user.EmailVerified() {
+		ac.auditLogin(c, &user, user.Email, false, gin.H{"method": "password", "reason": "email_not_verified"})
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                 "Verify your email address before signing in",
+			"verification_required": true,
+		})
+		return
+	}
+
+	// With 2FA enabled the tokens are only issued by VerifyLogin
+	if user.TOTPEnabled {
+		mfaToken, err := generateMFAToken(ac.JWTSecret, &user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(mfaTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	// Start a session and issue its tokens
+	response, err := ac.startSession(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	ac.auditLogin(c, &user, user.Email, true, gin.H{"method": "password"})
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
-		},
-	})
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyLogin completes a 2FA login with a TOTP or recovery code and issues the session tokens
+func (ac *AuthController) VerifyLogin(c *gin.Context) {
+	var req VerifyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := parseMFAToken(ac.JWTSecret, req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+	if wait := ac.guard.locked(userID); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed verification attempts, try again later"})
+		return
+	}
+
+	var user models.User
+	if err := ac.DB.First(&user, userID).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+	if !verifySecondFactor(ac.DB, &user, req.SecondFactorRequest) {
+		ac.auditLogin(c, &user, user.Email, false, gin.H{"method": "password+2fa", "reason": "invalid_code"})
+		ac.guard.fail(userID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+	ac.guard.succeed(userID)
+
+	response, err := ac.startSession(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	if req.RecoveryCode != "" {
+		response["recovery_codes_remaining"] = models.RemainingRecoveryCodes(ac.DB, user.ID)
+	}
+	ac.auditLogin(c, &user, user.Email, true, gin.H{"method": "password+2fa", "recovery_code": req.RecoveryCode != ""})
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (ac *AuthController) Register(c *gin.Context) {
@@ -119,21 +279,118 @@ func (ac *AuthController) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := ac.generateToken(&user)
+	if err := sendVerificationEmail(ac.DB, ac.Mailer, ac.AppURL, &user); err != nil {
+		log.Printf("Failed to issue verification token for user %d: %v", user.ID, err)
+	}
+	if ac.RequireEmailVerification {
+		c.JSON(http.StatusCreated, gin.H{
+			"message":               "Account created, check your email to verify your address",
+			"verification_required": true,
+		})
+		return
+	}
+
+	// Start a session and issue its tokens
+	response, err := ac.startSession(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
-		},
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
+// Refresh exchanges a refresh token for a new token pair. Refresh tokens are
+// single-use: presenting one that was already rotated revokes the session.
+func (ac *AuthController) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := models.HashToken(req.RefreshToken)
+	var session models.Session
+	if err := ac.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		// A rotated token being replayed means it leaked; end the session
+		if err := ac.DB.Where("previous_token_hash = ?", hash).First(&session).Error; err == nil {
+			revokeSessions(ac.DB, ac.Hub, "id = ?", session.ID)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if !session.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+		return
+	}
+
+	var user models.User
+	if err := ac.DB.First(&user, session.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	refreshToken, refreshHash, err := models.GenerateSecretToken(models.RefreshTokenPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Rotate only if nobody else rotated this token concurrently
+	result := ac.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  refreshHash,
+			"previous_token_hash": hash,
+			"last_used_at":        time.Now(),
+			"ip":                  c.ClientIP(),
+			"user_agent":          c.Request.UserAgent(),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	response, err := ac.tokenResponse(&user, &session, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout ends the session identified by the refresh token in the body, or by
+// the access token in the Authorization header
+func (ac *AuthController) Logout(c *gin.Context) {
+	var req LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if req.RefreshToken != "" {
+		var session models.Session
+		if err := ac.DB.Where("refresh_token_hash = ?", models.HashToken(req.RefreshToken)).First(&session).Error; err == nil {
+			revokeSessions(ac.DB, ac.Hub, "id = ?", session.ID)
+			ac.auditLogout(c, session.UserID, session.ID)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+		return
+	}
+
+	claims, err := middleware.ParseAccessToken(ac.DB, ac.JWTSecret, strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token or valid access token required"})
+		return
+	}
+	revokeSessions(ac.DB, ac.Hub, "id = ?", claims.SessionID)
+	ac.auditLogout(c, claims.UserID, claims.SessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func (ac *AuthController) auditLogout(c *gin.Context, userID, sessionID uint) {
+	var user models.User
+	if err := ac.DB.First(&user, userID).Error; err != nil {
+		return
+	}
+	models.RecordAudit(ac.DB, userActor(c, &user), models.AuditLogout, "session", sessionID, true, nil)
+}
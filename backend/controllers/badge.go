@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxBadgeLabel = 40
+
+type BadgeController struct {
+	DB      *gorm.DB
+	service *services.BadgeService
+}
+
+func NewBadgeController(db *gorm.DB, badgeService *services.BadgeService) *BadgeController {
+	return &BadgeController{DB: db, service: badgeService}
+}
+
+type CreateBadgeTokenRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	MonitorID *uint  `json:"monitor_id"`
+	GroupID   *uint  `json:"group_id"`
+}
+
+// GetBadgeTokens lists the workspace's badge tokens
+func (bc *BadgeController) GetBadgeTokens(c *gin.Context) {
+	var tokens []models.BadgeToken
+	if err := bc.DB.Scopes(middleware.OwnedBy(c)).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badge tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateBadgeToken issues a token for the badges of one monitor or group. The
+// plain token, and so the badge URLs, are only returned in this response.
+func (bc *BadgeController) CreateBadgeToken(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req CreateBadgeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.MonitorID == nil) == (req.GroupID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of monitor_id and group_id is required"})
+		return
+	}
+	if req.MonitorID != nil && !taken(bc.DB.Model(&models.Monitor{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", *req.MonitorID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
+		return
+	}
+	if req.GroupID != nil && !taken(bc.DB.Model(&models.MonitorGroup{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", *req.GroupID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor group not found"})
+		return
+	}
+
+	plain, hash, err := models.GenerateSecretToken(models.BadgeTokenPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	token := models.BadgeToken{
+		UserID:    userID,
+		TeamID:    middleware.GetTeamIDPtr(c),
+		Name:      req.Name,
+		MonitorID: req.MonitorID,
+		GroupID:   req.GroupID,
+		Prefix:    plain[:len(models.BadgeTokenPrefix)+6],
+		TokenHash: hash,
+	}
+	if err := bc.DB.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create badge token"})
+		return
+	}
+	recordAudit(bc.DB, c, models.AuditBadgeTokenCreate, "badge_token", token.ID, gin.H{
+		"name": token.Name, "monitor_id": token.MonitorID, "group_id": token.GroupID,
+	})
+
+	badges := gin.H{}
+	for _, kind := range []string{services.BadgeStatus, services.BadgeUptime, services.BadgeResponse, services.BadgeCert} {
+		badges[kind] = fmt.Sprintf("/api/badge/%s/%s.svg", plain, kind)
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token":       plain,
+		"badge_token": token,
+		"badges":      badges,
+	})
+}
+
+// DeleteBadgeToken stops a token's badges from rendering. Badges already
+// drawn may still be served from cache for a minute.
+func (bc *BadgeController) DeleteBadgeToken(c *gin.Context) {
+	var token models.BadgeToken
+	if err := bc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", c.Param("id")).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge token not found"})
+		return
+	}
+
+	if err := bc.DB.Delete(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete badge token"})
+		return
+	}
+	recordAudit(bc.DB, c, models.AuditBadgeTokenDelete, "badge_token", token.ID, gin.H{"name": token.Name})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Badge token deleted successfully"})
+}
+
+// GetBadge serves a badge as SVG, without authentication beyond the token in
+// the URL. Options: period (24h, 7d or 30d), style and label.
+func (bc *BadgeController) GetBadge(c *gin.Context) {
+	req := services.BadgeRequest{
+		Token:  c.Param("token"),
+		Kind:   strings.TrimSuffix(c.Param("badge"), ".svg"),
+		Period: c.DefaultQuery("period", "24h"),
+		Style:  c.DefaultQuery("style", services.BadgeStyleFlat),
+		Label:  c.Query("label"),
+	}
+	switch req.Kind {
+	case services.BadgeStatus, services.BadgeUptime, services.BadgeResponse, services.BadgeCert:
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown badge"})
+		return
+	}
+	if _, ok := services.BadgePeriods[req.Period]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be 24h, 7d or 30d"})
+		return
+	}
+	if !services.IsValidBadgeStyle(req.Style) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "style must be flat, flat-square, plastic or for-the-badge"})
+		return
+	}
+	if len([]rune(req.Label)) > maxBadgeLabel {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("label must be at most %d characters", maxBadgeLabel)})
+		return
+	}
+
+	svg, err := bc.service.Render(req)
+	if err == services.ErrBadgeNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render badge"})
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(svg))
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(services.BadgeCacheTTL.Seconds())))
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", svg)
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ClusterController struct {
+	DB      *gorm.DB
+	cluster *services.Cluster
+}
+
+func NewClusterController(db *gorm.DB, cluster *services.Cluster) *ClusterController {
+	return &ClusterController{DB: db, cluster: cluster}
+}
+
+// GetCluster shows the running instances, which one leads and how many
+// enabled monitors each checks
+func (cc *ClusterController) GetCluster(c *gin.Context) {
+	var members []models.ClusterMember
+	if err := cc.DB.Where("id IN ?", cc.cluster.Members()).Order("started_at").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cluster members"})
+		return
+	}
+	var lease models.ClusterLease
+	cc.DB.Where("name = ?", services.LeaderLease).Limit(1).Find(&lease)
+
+	var monitorIDs []uint
+	if err := cc.DB.Model(&models.Monitor{}).Where("enabled = ?", true).Pluck("id", &monitorIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitors"})
+		return
+	}
+	owned := map[string]int{}
+	for _, owner := range cc.cluster.Owners(monitorIDs) {
+		owned[owner]++
+	}
+
+	items := make([]gin.H, 0, len(members))
+	for _, member := range members {
+		items = append(items, gin.H{
+			"id":           member.ID,
+			"hostname":     member.Hostname,
+			"started_at":   member.StartedAt,
+			"heartbeat_at": member.HeartbeatAt,
+			"leader":       member.ID == lease.Holder,
+			"monitors":     owned[member.ID],
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"instance": cc.cluster.ID(),
+		"leader":   lease.Holder,
+		"members":  items,
+	})
+}
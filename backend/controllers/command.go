@@ -1,12 +1,14 @@
 package controllers
 
 import (
+    "errors"
     "net/http"
     "strconv"
 
     "github.com/gin-gonic/gin"
     "gorm.io/gorm"
     "runnerx/middleware"
+    "runnerx/models"
     "runnerx/services"
 )
 
@@ -33,12 +35,26 @@ func (cc *CommandController) ExecuteCommand(c *gin.Context) {
     }
     
     req.UserID = userID
+
+    // Diagnostics can only be attached to a monitor of the current workspace
+    if req.MonitorID != nil && !taken(cc.DB.Model(&models.Monitor{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", *req.MonitorID)) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
+        return
+    }
     
     response, err := cc.commandService.ExecuteCommand(req)
+    details := gin.H{"type": req.Type, "target": req.Target, "monitor_id": req.MonitorID}
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        details["error"] = err.Error()
+        models.RecordAudit(cc.DB, auditActor(cc.DB, c), models.AuditCommandExecute, "command", nil, false, details)
+        status := http.StatusBadRequest
+        if errors.Is(err, services.ErrShuttingDown) {
+            status = http.StatusServiceUnavailable
+        }
+        c.JSON(status, gin.H{"error": err.Error()})
         return
     }
+    recordAudit(cc.DB, c, models.AuditCommandExecute, "command", response.ID, details)
     
     c.JSON(http.StatusOK, response)
 }
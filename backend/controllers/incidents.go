@@ -3,6 +3,7 @@ package controllers
 import (
     "net/http"
     "strconv"
+    "time"
 
     "github.com/gin-gonic/gin"
     "gorm.io/gorm"
@@ -25,16 +26,15 @@ func NewIncidentsController(db *gorm.DB) *IncidentsController {
 
 // GET /api/incidents/:serverId -> timeline
 func (ic *IncidentsController) Get(c *gin.Context) {
-    userID, _ := middleware.GetUserID(c)
     idStr := c.Param("serverId")
     mid, _ := strconv.ParseUint(idStr, 10, 64)
     var mon models.Monitor
-    if err := ic.DB.Where("id = ? AND user_id = ?", mid, userID).First(&mon).Error; err != nil {
+    if err := ic.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", mid).First(&mon).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
         return
     }
     var items []models.Incident
-    if err := ic.DB.Where("monitor_id = ? AND user_id = ?", mid, userID).Order("timestamp DESC").Limit(100).Find(&items).Error; err != nil {
+    if err := ic.DB.Where("monitor_id = ?", mid).Order("timestamp DESC").Limit(100).Find(&items).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch"})
         return
     }
@@ -43,8 +43,6 @@ func (ic *IncidentsController) Get(c *gin.Context) {
 
 // POST /api/incidents/summary - Generate AI summary for an incident
 func (ic *IncidentsController) GenerateSummary(c *gin.Context) {
-    userID, _ := middleware.GetUserID(c)
-    
     var req struct {
         IncidentID uint `json:"incident_id" binding:"required"`
     }
@@ -56,7 +54,7 @@ func (ic *IncidentsController) GenerateSummary(c *gin.Context) {
     
     // Get incident and verify ownership
     var incident models.Incident
-    if err := ic.DB.Where("id = ? AND user_id = ?", req.IncidentID, userID).First(&incident).Error; err != nil {
+    if err := ic.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", req.IncidentID).First(&incident).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
         return
     }
@@ -87,4 +85,29 @@ func (ic *IncidentsController) GenerateSummary(c *gin.Context) {
     c.JSON(http.StatusOK, summary)
 }
 
+// POST /api/incident/:id/acknowledge - Mark an incident as acknowledged by the caller
+func (ic *IncidentsController) Acknowledge(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    var incident models.Incident
+    if err := ic.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", c.Param("id")).First(&incident).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+        return
+    }
+
+    if incident.AcknowledgedAt != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": "incident already acknowledged", "incident": incident})
+        return
+    }
+
+    now := time.Now()
+    incident.AcknowledgedAt = &now
+    incident.AcknowledgedBy = &userID
+    if err := ic.DB.Save(&incident).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to acknowledge incident"})
+        return
+    }
+    recordAudit(ic.DB, c, models.AuditIncidentAck, "incident", incident.ID, gin.H{"monitor_id": incident.MonitorID, "summary": incident.Summary})
 
+    c.JSON(http.StatusOK, incident)
+}
@@ -27,6 +27,13 @@ func (lc *LogsController) GetInsights(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "incidentId required"})
         return
     }
+    // Insights for a monitor are recorded under the monitor's owner and shared with its team
+    if monitor, ok := incidentMonitor(c, lc.DB, incidentID); ok {
+        userID = monitor.UserID
+    } else if strings.HasPrefix(incidentID, "monitor-") {
+        c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+        return
+    }
     q := c.Query("q")
     items, err := lc.Svc.GetInsights(userID, incidentID, q)
     if err != nil {
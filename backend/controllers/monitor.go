@@ -3,9 +3,11 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"runnerx/middleware"
@@ -13,27 +15,42 @@ import (
 	"runnerx/services"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"gorm.io/gorm"
 )
 
+// maxImportSize caps the size of an uploaded import file
+const maxImportSize = 10 << 20
+
 type MonitorController struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	monitorService *services.MonitorService
 }
 
-func NewMonitorController(db *gorm.DB) *MonitorController {
-	return &MonitorController{DB: db}
+func NewMonitorController(db *gorm.DB, monitorService *services.MonitorService) *MonitorController {
+	return &MonitorController{DB: db, monitorService: monitorService}
 }
 
 type CreateMonitorRequest struct {
-	Name            string   `json:"name" binding:"required"`
-	Type            string   `json:"type" binding:"required,oneof=http ping tcp dns"`
-	Endpoint        string   `json:"endpoint" binding:"required"`
-	Method          string   `json:"method"`
-	IntervalSeconds int      `json:"interval_seconds" binding:"required,min=10,max=86400"`
-	Timeout         int      `json:"timeout" binding:"min=5,max=60"`
-	HeadersJSON     string   `json:"headers_json"`
-	Enabled         bool     `json:"enabled"`
-	Tags            []string `json:"tags"`
+	Name               string   `json:"name" binding:"required"`
+	Type               string   `json:"type" binding:"required,oneof=http ping tcp dns push"`
+	Endpoint           string   `json:"endpoint" binding:"required"`
+	Method             string   `json:"method"`
+	IntervalSeconds    int      `json:"interval_seconds" binding:"required,min=10,max=86400"`
+	Timeout            int      `json:"timeout" binding:"min=5,max=60"`
+	HeadersJSON        string   `json:"headers_json"`
+	Enabled            bool     `json:"enabled"`
+	Tags               []string `json:"tags"`
+	Criticality        string   `json:"criticality" binding:"omitempty,oneof=low normal high critical"`
+	Locations          []string `json:"locations"`
+	MinFailedLocations int      `json:"min_failed_locations" binding:"min=0"`
+}
+
+type HeartbeatRequest struct {
+	Status    string `json:"status" binding:"omitempty,oneof=up down"`
+	Message   string `json:"message"`
+	LatencyMs int64  `json:"latency_ms" binding:"min=0"`
 }
 
 type TestMonitorRequest struct {
@@ -44,10 +61,9 @@ type TestMonitorRequest struct {
 }
 
 func (mc *MonitorController) GetMonitors(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 
 	var monitors []models.Monitor
-	if err := mc.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&monitors).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Order("created_at DESC").Find(&monitors).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitors"})
 		return
 	}
@@ -56,11 +72,10 @@ func (mc *MonitorController) GetMonitors(c *gin.Context) {
 }
 
 func (mc *MonitorController) GetMonitor(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -70,11 +85,10 @@ func (mc *MonitorController) GetMonitor(c *gin.Context) {
 
 // GetMonitorSnapshot returns last stored response snapshot for HTTP monitors
 func (mc *MonitorController) GetMonitorSnapshot(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -98,11 +112,10 @@ func (mc *MonitorController) GetMonitorSnapshot(c *gin.Context) {
 
 // GetMonitorForecast returns recent forecast entries for a monitor
 func (mc *MonitorController) GetMonitorForecast(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -128,11 +141,10 @@ func (mc *MonitorController) GetMonitorForecast(c *gin.Context) {
 
 // GetRootCauseTimeline aggregates failed checks into cause timeline
 func (mc *MonitorController) GetRootCauseTimeline(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -155,10 +167,11 @@ func (mc *MonitorController) GetRootCauseTimeline(c *gin.Context) {
 
 	// Build timeline
 	type TimelineItem struct {
-		Timestamp  time.Time `json:"timestamp"`
-		CauseType  string    `json:"cause_type"`
-		Detail     string    `json:"detail"`
-		StatusCode int       `json:"status_code"`
+		Timestamp  time.Time           `json:"timestamp"`
+		CauseType  string              `json:"cause_type"`
+		Detail     string              `json:"detail"`
+		StatusCode int                 `json:"status_code"`
+		Timing     *models.CheckTiming `json:"timing,omitempty"`
 	}
 	items := make([]TimelineItem, 0, len(checks))
 	for _, ch := range checks {
@@ -167,30 +180,43 @@ func (mc *MonitorController) GetRootCauseTimeline(c *gin.Context) {
 			CauseType:  ch.CauseType,
 			Detail:     ch.CauseDetail,
 			StatusCode: ch.StatusCode,
+			Timing:     ch.Timing,
 		})
 	}
 
 	// Heuristics summary
 	type Summary struct {
 		Trend string `json:"trend"`
+		// Timing is the average breakdown of the successful checks in range
+		Timing *models.HourlyTiming `json:"timing,omitempty"`
 	}
 	trend := "inconclusive"
 	if len(items) >= 3 {
-		// crude heuristic: many http_error with 5xx -> backend issue
-		http5xx := 0
+		// crude heuristic: 5xx or no response once connected -> backend
+		// issue, failed lookups -> DNS issue
+		backend, dns := 0, 0
 		for _, it := range items {
-			if it.CauseType == "http_error" && it.StatusCode >= 500 {
-				http5xx++
+			switch {
+			case it.CauseType == "http_error" && it.StatusCode >= 500, it.CauseType == "response_timeout":
+				backend++
+			case it.CauseType == "dns_error":
+				dns++
 			}
 		}
-		if http5xx >= 2 {
+		if backend >= 2 {
 			trend = "backend_issue_suspected"
+		} else if dns >= 2 {
+			trend = "dns_issue_suspected"
 		}
 	}
+	summary := Summary{Trend: trend}
+	if _, overall, err := models.MonitorTimings(mc.DB, monitor.ID, since); err == nil && overall.TimedChecks > 0 {
+		summary.Timing = &overall
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"items":   items,
-		"summary": Summary{Trend: trend},
+		"summary": summary,
 	})
 }
 
@@ -202,28 +228,42 @@ func (mc *MonitorController) CreateMonitor(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if msg := mc.checkLocations(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 
 	// Create monitoring config service
 	configService := services.NewMonitoringConfigService(mc.DB)
 
 	monitor := models.Monitor{
-		UserID:          userID,
-		Name:            req.Name,
-		Type:            req.Type,
-		Endpoint:        req.Endpoint,
-		Method:          req.Method,
-		IntervalSeconds: req.IntervalSeconds,
-		Timeout:         req.Timeout,
-		HeadersJSON:     req.HeadersJSON,
-		Enabled:         req.Enabled,
-		Tags:            req.Tags,
-		Status:          "pending",
+		UserID:             userID,
+		TeamID:             middleware.GetTeamIDPtr(c),
+		Name:               req.Name,
+		Type:               req.Type,
+		Endpoint:           req.Endpoint,
+		Method:             req.Method,
+		IntervalSeconds:    req.IntervalSeconds,
+		Timeout:            req.Timeout,
+		HeadersJSON:        req.HeadersJSON,
+		Enabled:            req.Enabled,
+		Tags:               req.Tags,
+		Criticality:        req.Criticality,
+		Locations:          req.Locations,
+		MinFailedLocations: req.MinFailedLocations,
+		Status:             "pending",
 	}
 
 	// Set defaults if not provided
 	if monitor.Method == "" {
 		monitor.Method = "GET"
 	}
+	if monitor.MinFailedLocations == 0 {
+		monitor.MinFailedLocations = 1
+	}
+	if monitor.Criticality == "" {
+		monitor.Criticality = models.CriticalityNormal
+	}
 	if monitor.IntervalSeconds == 0 {
 		monitor.IntervalSeconds = configService.GetOptimalInterval(monitor.Type)
 	}
@@ -238,19 +278,22 @@ func (mc *MonitorController) CreateMonitor(c *gin.Context) {
 	}
 
 	// Test the monitor connection before saving
-	isOnline, errorMsg, latencyMs, err := configService.TestMonitorConnection(&monitor)
+	isOnline, errorMsg, latencyMs, err := configService.TestMonitorConnection(c.Request.Context(), &monitor)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to test connection: %v", err)})
 		return
 	}
 
 	// Log the test result
-	configService.LogMonitorEvent(0, "connection_test", fmt.Sprintf("Test result: %v, Latency: %dms, Error: %s", isOnline, latencyMs, errorMsg))
+	configService.LogMonitorEvent(auditActor(mc.DB, c), 0, "connection_test", fmt.Sprintf("Test result: %v, Latency: %dms, Error: %s", isOnline, latencyMs, errorMsg))
 
 	if err := mc.DB.Create(&monitor).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create monitor"})
 		return
 	}
+	recordAudit(mc.DB, c, models.AuditMonitorCreate, "monitor", monitor.ID, gin.H{
+		"name": monitor.Name, "type": monitor.Type, "endpoint": monitor.Endpoint,
+	})
 
 	// Return monitor with test results
 	response := gin.H{
@@ -266,11 +309,10 @@ func (mc *MonitorController) CreateMonitor(c *gin.Context) {
 }
 
 func (mc *MonitorController) UpdateMonitor(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -281,6 +323,12 @@ func (mc *MonitorController) UpdateMonitor(c *gin.Context) {
 		return
 	}
 
+	if msg := mc.checkLocations(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	before := monitorAuditFields(&monitor)
 	monitor.Name = req.Name
 	monitor.Type = req.Type
 	monitor.Endpoint = req.Endpoint
@@ -289,39 +337,49 @@ func (mc *MonitorController) UpdateMonitor(c *gin.Context) {
 	monitor.HeadersJSON = req.HeadersJSON
 	monitor.Enabled = req.Enabled
 	monitor.Tags = req.Tags
+	if req.Criticality != "" {
+		monitor.Criticality = req.Criticality
+	}
+	monitor.Locations = req.Locations
+	monitor.MinFailedLocations = req.MinFailedLocations
+	if monitor.MinFailedLocations == 0 {
+		monitor.MinFailedLocations = 1
+	}
 
 	if err := mc.DB.Save(&monitor).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update monitor"})
 		return
 	}
+	recordAudit(mc.DB, c, models.AuditMonitorUpdate, "monitor", monitor.ID, gin.H{
+		"before": before, "after": monitorAuditFields(&monitor),
+	})
 
 	c.JSON(http.StatusOK, monitor)
 }
 
 func (mc *MonitorController) DeleteMonitor(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
-	result := mc.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Monitor{})
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete monitor"})
+	var monitor models.Monitor
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
+	if err := mc.DB.Delete(&monitor).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete monitor"})
 		return
 	}
+	recordAudit(mc.DB, c, models.AuditMonitorDelete, "monitor", monitor.ID, monitorAuditFields(&monitor))
 
 	c.JSON(http.StatusOK, gin.H{"message": "Monitor deleted successfully"})
 }
 
 func (mc *MonitorController) ToggleMonitor(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -345,16 +403,16 @@ func (mc *MonitorController) ToggleMonitor(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle monitor"})
 		return
 	}
+	recordAudit(mc.DB, c, models.AuditMonitorToggle, "monitor", monitor.ID, gin.H{"name": monitor.Name, "enabled": monitor.Enabled})
 
 	c.JSON(http.StatusOK, monitor)
 }
 
 func (mc *MonitorController) GetMonitorStats(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -372,13 +430,12 @@ func (mc *MonitorController) GetMonitorStats(c *gin.Context) {
 }
 
 func (mc *MonitorController) GetMonitorHistory(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 	daysStr := c.DefaultQuery("days", "7")
 	days, _ := strconv.Atoi(daysStr)
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -396,6 +453,33 @@ func (mc *MonitorController) GetMonitorHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, checks)
 }
 
+// GetMonitorTimings returns the average DNS, connect, TLS, time to first byte
+// and transfer durations of an HTTP monitor's successful checks per hour
+func (mc *MonitorController) GetMonitorTimings(c *gin.Context) {
+	id := c.Param("id")
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 {
+		days = 7
+	}
+
+	var monitor models.Monitor
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
+		return
+	}
+
+	hours, overall, err := models.MonitorTimings(mc.DB, monitor.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hours":   hours,
+		"overall": overall,
+	})
+}
+
 func (mc *MonitorController) TestMonitor(c *gin.Context) {
 	var req TestMonitorRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -435,7 +519,7 @@ func (mc *MonitorController) TestMonitor(c *gin.Context) {
 		method = "GET"
 	}
 
-	httpReq, err := http.NewRequest(method, req.Endpoint, nil)
+	httpReq, err := http.NewRequestWithContext(c.Request.Context(), method, req.Endpoint, nil)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status": "error",
@@ -458,6 +542,7 @@ func (mc *MonitorController) TestMonitor(c *gin.Context) {
 			}
 		}
 	}
+	otel.GetTextMapPropagator().Inject(c.Request.Context(), propagation.HeaderCarrier(httpReq.Header))
 
 	resp, err := client.Do(httpReq)
 	latencyMs = time.Since(startTime).Milliseconds()
@@ -490,13 +575,127 @@ func (mc *MonitorController) TestMonitor(c *gin.Context) {
 	})
 }
 
+// PushHeartbeat records a heartbeat for a push monitor. The body is optional;
+// without one the heartbeat reports the monitor as up.
+func (mc *MonitorController) PushHeartbeat(c *gin.Context) {
+	id := c.Param("id")
+
+	var monitor models.Monitor
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
+		return
+	}
+	if monitor.Type != "push" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Heartbeats are only accepted by push monitors"})
+		return
+	}
+	if !monitor.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Monitor is paused"})
+		return
+	}
+
+	var req HeartbeatRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Status == "" {
+		req.Status = "up"
+	}
+
+	mc.monitorService.RecordHeartbeat(c.Request.Context(), &monitor, req.Status, req.LatencyMs, req.Message)
+
+	c.JSON(http.StatusOK, gin.H{
+		"monitor_id": monitor.ID,
+		"status":     monitor.Status,
+	})
+}
+
+// ImportMonitors creates monitors from an Uptime Kuma backup, Uptime Robot CSV export
+// or Prometheus blackbox_exporter scrape config. The file is sent either as the
+// multipart field "file" or as the raw request body.
+func (mc *MonitorController) ImportMonitors(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	source := c.Query("source")
+	if source == "" {
+		source = c.PostForm("source")
+	}
+	if source == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source is required (uptime_kuma, uptime_robot or blackbox)"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var data []byte
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, ferr := c.Request.FormFile("file")
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field is required"})
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(c.Request.Body)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import file"})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is empty"})
+		return
+	}
+
+	importer := services.NewMonitorImporter(mc.DB)
+	result, err := importer.Import(userID, middleware.GetTeamIDPtr(c), source, data, dryRun)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	} else {
+		ids := make([]uint, len(result.Monitors))
+		for i, monitor := range result.Monitors {
+			ids[i] = monitor.ID
+		}
+		recordAudit(mc.DB, c, models.AuditMonitorImport, "monitor", nil, gin.H{
+			"source": source, "imported": result.Imported, "monitor_ids": ids,
+		})
+	}
+	c.JSON(status, result)
+}
+
+// monitorAuditFields is the part of a monitor's configuration kept in audit entries
+func monitorAuditFields(monitor *models.Monitor) gin.H {
+	return gin.H{
+		"name":             monitor.Name,
+		"type":             monitor.Type,
+		"endpoint":         monitor.Endpoint,
+		"method":           monitor.Method,
+		"interval_seconds": monitor.IntervalSeconds,
+		"enabled":          monitor.Enabled,
+		"tags":             monitor.Tags,
+		"criticality":      monitor.Criticality,
+		"locations":        monitor.Locations,
+	}
+}
+
 // GetMonitorHealth provides detailed health status for a monitor
 func (mc *MonitorController) GetMonitorHealth(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	id := c.Param("id")
 
 	var monitor models.Monitor
-	if err := mc.DB.Where("id = ? AND user_id = ?", id, userID).First(&monitor).Error; err != nil {
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", id).First(&monitor).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor not found"})
 		return
 	}
@@ -513,3 +712,34 @@ func (mc *MonitorController) GetMonitorHealth(c *gin.Context) {
 
 	c.JSON(http.StatusOK, healthStatus)
 }
+
+// checkLocations validates where a monitor is to be checked from: known
+// locations only, none for push monitors, and a failure threshold that the
+// locations can reach
+func (mc *MonitorController) checkLocations(req *CreateMonitorRequest) string {
+	if len(req.Locations) == 0 {
+		if req.MinFailedLocations > 1 {
+			return "min_failed_locations needs as many locations"
+		}
+		return ""
+	}
+	if req.Type == "push" {
+		return "Push monitors cannot be assigned to locations"
+	}
+
+	known := knownLocations(mc.DB, mc.monitorService.Location())
+	seen := map[string]bool{}
+	for _, location := range req.Locations {
+		if seen[location] {
+			return fmt.Sprintf("Location %q is listed twice", location)
+		}
+		seen[location] = true
+		if !known[location] {
+			return fmt.Sprintf("Unknown location %q", location)
+		}
+	}
+	if req.MinFailedLocations > len(req.Locations) {
+		return "min_failed_locations cannot exceed the number of locations"
+	}
+	return ""
+}
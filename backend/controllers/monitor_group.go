@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxGroupHistory caps the number of history entries returned at once
+const maxGroupHistory = 1000
+
+type MonitorGroupController struct {
+	DB           *gorm.DB
+	groupService *services.MonitorGroupService
+	slaService   *services.SLAService
+}
+
+func NewMonitorGroupController(db *gorm.DB, groupService *services.MonitorGroupService) *MonitorGroupController {
+	return &MonitorGroupController{DB: db, groupService: groupService, slaService: services.NewSLAService(db)}
+}
+
+// GroupMemberRequest adds either a monitor or a nested group to a group
+type GroupMemberRequest struct {
+	MonitorID    *uint   `json:"monitor_id"`
+	ChildGroupID *uint   `json:"child_group_id"`
+	Weight       float64 `json:"weight" binding:"min=0"`
+	Critical     bool    `json:"critical"`
+}
+
+type MonitorGroupRequest struct {
+	Name        string               `json:"name" binding:"required"`
+	Description string               `json:"description"`
+	Policy      string               `json:"policy" binding:"omitempty,oneof=all_up any_up quorum"`
+	Quorum      float64              `json:"quorum" binding:"min=0,max=100"`
+	Members     []GroupMemberRequest `json:"members" binding:"dive"`
+}
+
+func (gc *MonitorGroupController) GetGroups(c *gin.Context) {
+	var groups []models.MonitorGroup
+	if err := gc.DB.Scopes(middleware.OwnedBy(c)).Order("name").Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch monitor groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+func (gc *MonitorGroupController) GetGroup(c *gin.Context) {
+	group, ok := gc.findGroup(c)
+	if !ok {
+		return
+	}
+
+	members, err := gc.groupService.MemberStatuses(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch group members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"group": group, "members": members})
+}
+
+func (gc *MonitorGroupController) CreateGroup(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req MonitorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group := models.MonitorGroup{
+		UserID:      userID,
+		TeamID:      middleware.GetTeamIDPtr(c),
+		Name:        req.Name,
+		Description: req.Description,
+		Status:      models.GroupStatusPending,
+	}
+	applyGroupSettings(&group, req)
+
+	members, err := gc.validateMembers(c, 0, req.Members)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = gc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return replaceMembers(tx, group.ID, members)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create monitor group"})
+		return
+	}
+	recordAudit(gc.DB, c, models.AuditGroupCreate, "monitor_group", group.ID, groupAuditFields(&group, members))
+
+	gc.groupService.Evaluate(group.ID)
+	gc.DB.First(&group, group.ID)
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateGroup changes a group's settings and replaces its members
+func (gc *MonitorGroupController) UpdateGroup(c *gin.Context) {
+	group, ok := gc.findGroup(c)
+	if !ok {
+		return
+	}
+
+	var req MonitorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	members, err := gc.validateMembers(c, group.ID, req.Members)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var oldMembers []models.MonitorGroupMember
+	gc.DB.Where("group_id = ?", group.ID).Find(&oldMembers)
+	before := groupAuditFields(group, oldMembers)
+
+	group.Name = req.Name
+	group.Description = req.Description
+	applyGroupSettings(group, req)
+
+	err = gc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(group).Error; err != nil {
+			return err
+		}
+		return replaceMembers(tx, group.ID, members)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update monitor group"})
+		return
+	}
+	recordAudit(gc.DB, c, models.AuditGroupUpdate, "monitor_group", group.ID, gin.H{
+		"before": before, "after": groupAuditFields(group, members),
+	})
+
+	gc.groupService.Evaluate(group.ID)
+	gc.DB.First(group, group.ID)
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup removes a group and takes it out of the groups containing it
+func (gc *MonitorGroupController) DeleteGroup(c *gin.Context) {
+	group, ok := gc.findGroup(c)
+	if !ok {
+		return
+	}
+
+	parentIDs, _ := models.ParentGroupIDs(gc.DB, group.ID)
+
+	err := gc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ? OR child_group_id = ?", group.ID, group.ID).
+			Delete(&models.MonitorGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete monitor group"})
+		return
+	}
+	recordAudit(gc.DB, c, models.AuditGroupDelete, "monitor_group", group.ID, gin.H{"name": group.Name})
+
+	for _, parentID := range parentIDs {
+		gc.groupService.Evaluate(parentID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Monitor group deleted successfully"})
+}
+
+func (gc *MonitorGroupController) GetGroupHistory(c *gin.Context) {
+	group, ok := gc.findGroup(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxGroupHistory {
+		limit = 100
+	}
+
+	var history []models.MonitorGroupCheck
+	if err := gc.DB.Where("group_id = ?", group.ID).Order("created_at DESC").Limit(limit).Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (gc *MonitorGroupController) GetGroupSLA(c *gin.Context) {
+	group, ok := gc.findGroup(c)
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		days = 30
+	}
+
+	reports, err := gc.slaService.GetSLAReportsForGroup(middleware.OwnedBy(c), group.ID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch SLA reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// findGroup loads the group named in the URL from the current workspace,
+// answering 404 when there is none
+func (gc *MonitorGroupController) findGroup(c *gin.Context) (*models.MonitorGroup, bool) {
+	var group models.MonitorGroup
+	if err := gc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", c.Param("id")).First(&group).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Monitor group not found"})
+		return nil, false
+	}
+	return &group, true
+}
+
+// validateMembers checks that every member is a monitor or group of the
+// current workspace, listed once, and that no group would contain itself
+func (gc *MonitorGroupController) validateMembers(c *gin.Context, groupID uint, reqs []GroupMemberRequest) ([]models.MonitorGroupMember, error) {
+	members := make([]models.MonitorGroupMember, 0, len(reqs))
+	seen := map[string]bool{}
+
+	for _, req := range reqs {
+		if (req.MonitorID == nil) == (req.ChildGroupID == nil) {
+			return nil, errors.New("each member needs exactly one of monitor_id and child_group_id")
+		}
+
+		var key string
+		if req.MonitorID != nil {
+			key = fmt.Sprintf("monitor:%d", *req.MonitorID)
+			var count int64
+			gc.DB.Model(&models.Monitor{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", *req.MonitorID).Count(&count)
+			if count == 0 {
+				return nil, fmt.Errorf("monitor %d not found", *req.MonitorID)
+			}
+		} else {
+			childID := *req.ChildGroupID
+			key = fmt.Sprintf("group:%d", childID)
+			var count int64
+			gc.DB.Model(&models.MonitorGroup{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", childID).Count(&count)
+			if count == 0 {
+				return nil, fmt.Errorf("monitor group %d not found", childID)
+			}
+			if groupID != 0 {
+				cycle, err := models.GroupContains(gc.DB, childID, groupID)
+				if err != nil {
+					return nil, err
+				}
+				if cycle {
+					return nil, fmt.Errorf("monitor group %d contains this group", childID)
+				}
+			}
+		}
+
+		if seen[key] {
+			return nil, fmt.Errorf("%s is listed more than once", key)
+		}
+		seen[key] = true
+
+		weight := req.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		members = append(members, models.MonitorGroupMember{
+			MonitorID:    req.MonitorID,
+			ChildGroupID: req.ChildGroupID,
+			Weight:       weight,
+			Critical:     req.Critical,
+		})
+	}
+	return members, nil
+}
+
+func applyGroupSettings(group *models.MonitorGroup, req MonitorGroupRequest) {
+	group.Policy = req.Policy
+	if group.Policy == "" {
+		group.Policy = models.GroupPolicyAllUp
+	}
+	group.Quorum = req.Quorum
+	if group.Quorum == 0 {
+		group.Quorum = 50
+	}
+}
+
+func replaceMembers(tx *gorm.DB, groupID uint, members []models.MonitorGroupMember) error {
+	if err := tx.Where("group_id = ?", groupID).Delete(&models.MonitorGroupMember{}).Error; err != nil {
+		return err
+	}
+	for i := range members {
+		members[i].ID = 0
+		members[i].GroupID = groupID
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return tx.Create(&members).Error
+}
+
+// groupAuditFields is the part of a group recorded in the audit trail
+func groupAuditFields(group *models.MonitorGroup, members []models.MonitorGroupMember) gin.H {
+	list := make([]gin.H, 0, len(members))
+	for _, m := range members {
+		list = append(list, gin.H{
+			"monitor_id": m.MonitorID, "child_group_id": m.ChildGroupID, "weight": m.Weight, "critical": m.Critical,
+		})
+	}
+	return gin.H{
+		"name": group.Name, "policy": group.Policy, "quorum": group.Quorum, "members": list,
+	}
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MoodController struct {
+	DB *gorm.DB
+}
+
+func NewMoodController(db *gorm.DB) *MoodController {
+	return &MoodController{DB: db}
+}
+
+// GetMood returns the mood of the selected workspace and of each of its tags
+func (mc *MoodController) GetMood(c *gin.Context) {
+	var monitors []models.Monitor
+	if err := mc.DB.Scopes(middleware.OwnedBy(c)).Find(&monitors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute mood"})
+		return
+	}
+
+	mood := services.ComputeMood(monitors)
+	mood.Scope = "user"
+	if teamID, ok := middleware.GetTeamID(c); ok {
+		mood.Scope = "team"
+		mood.TeamID = &teamID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mood": mood,
+		"tags": services.ComputeTagMoods(monitors),
+	})
+}
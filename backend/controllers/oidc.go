@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCStatus tells the login page whether to offer single sign-on
+func (ac *AuthController) OIDCStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": ac.OIDC != nil && ac.OIDC.Enabled()})
+}
+
+// OIDCLogin redirects the browser to the identity provider. With
+// ?response=json it returns the URL instead, and the callback will answer with
+// JSON rather than redirecting to the frontend. Either way the login can only
+// be completed by a client that sends back the cookie set here.
+func (ac *AuthController) OIDCLogin(c *gin.Context) {
+	if ac.OIDC == nil || !ac.OIDC.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	responseMode := "redirect"
+	if c.Query("response") == "json" {
+		responseMode = "json"
+	}
+
+	authURL, binding, err := ac.OIDC.LoginURL(c.Request.Context(), responseMode)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+	ac.setOIDCBinding(c, binding, int(models.OIDCLoginTTL.Seconds()))
+
+	if responseMode == "json" {
+		c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback finishes the login started by OIDCLogin and starts a session.
+// Tokens are handed to the frontend in the URL fragment so they never reach
+// server logs.
+func (ac *AuthController) OIDCCallback(c *gin.Context) {
+	if ac.OIDC == nil || !ac.OIDC.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrOIDCDisabled.Error()})
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		ac.auditLogin(c, nil, "", false, gin.H{"method": "oidc", "reason": providerErr})
+		ac.oidcFail(c, "", http.StatusUnauthorized, "Identity provider returned an error: "+providerErr)
+		return
+	}
+
+	binding, _ := c.Cookie(oidcBindingCookie)
+	user, login, err := ac.OIDC.Callback(c.Request.Context(), c.Query("state"), c.Query("code"), binding)
+	if login != nil {
+		ac.setOIDCBinding(c, "", -1)
+	}
+	if err != nil {
+		ac.auditLogin(c, nil, "", false, gin.H{"method": "oidc", "reason": err.Error()})
+		mode := ""
+		if login != nil {
+			mode = login.ResponseMode
+		}
+		switch {
+		case errors.Is(err, services.ErrOIDCInvalidState):
+			ac.oidcFail(c, mode, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrOIDCUnverified):
+			ac.oidcFail(c, mode, http.StatusForbidden, err.Error())
+		default:
+			log.Printf("OIDC callback failed: %v", err)
+			ac.oidcFail(c, mode, http.StatusUnauthorized, "Single sign-on failed")
+		}
+		return
+	}
+
+	// With 2FA enabled the tokens are only issued by VerifyLogin, as for
+	// password logins
+	if user.TOTPEnabled {
+		mfaToken, err := generateMFAToken(ac.JWTSecret, user)
+		if err != nil {
+			ac.oidcFail(c, login.ResponseMode, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+		if login.ResponseMode == "json" {
+			c.JSON(http.StatusOK, gin.H{
+				"mfa_required": true,
+				"mfa_token":    mfaToken,
+				"expires_in":   int(mfaTokenTTL.Seconds()),
+			})
+			return
+		}
+		fragment := url.Values{}
+		fragment.Set("mfa_required", "true")
+		fragment.Set("mfa_token", mfaToken)
+		fragment.Set("expires_in", strconv.Itoa(int(mfaTokenTTL.Seconds())))
+		c.Redirect(http.StatusFound, ac.OIDCFrontendURL+"#"+fragment.Encode())
+		return
+	}
+
+	response, err := ac.startSession(c, user)
+	if err != nil {
+		ac.oidcFail(c, login.ResponseMode, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	ac.auditLogin(c, user, user.Email, true, gin.H{"method": "oidc"})
+
+	if login.ResponseMode == "json" {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	fragment := url.Values{}
+	fragment.Set("token", response["token"].(string))
+	fragment.Set("refresh_token", response["refresh_token"].(string))
+	fragment.Set("expires_in", strconv.Itoa(response["expires_in"].(int)))
+	c.Redirect(http.StatusFound, ac.OIDCFrontendURL+"#"+fragment.Encode())
+}
+
+// oidcBindingCookie ties a login attempt to the browser that started it
+const oidcBindingCookie = "runnerx_oidc"
+
+// setOIDCBinding stores the login binding in an HttpOnly cookie scoped to the
+// callback, or removes it when maxAge is negative
+func (ac *AuthController) setOIDCBinding(c *gin.Context, binding string, maxAge int) {
+	path := "/"
+	secure := c.Request.TLS != nil
+	if u, err := url.Parse(ac.OIDC.CallbackURL()); err == nil {
+		if u.Path != "" {
+			path = u.Path
+		}
+		secure = secure || u.Scheme == "https"
+	}
+	// Lax still sends the cookie on the identity provider's top-level
+	// redirect back to the callback
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, binding, maxAge, path, "", secure, true)
+}
+
+// oidcFail reports a failed SSO login as JSON, or back to the frontend login
+// page when the browser is being redirected
+func (ac *AuthController) oidcFail(c *gin.Context, mode string, status int, message string) {
+	if mode == "json" || ac.OIDCFrontendURL == "" {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.Redirect(http.StatusFound, ac.OIDCFrontendURL+"#"+url.Values{"error": {message}}.Encode())
+}
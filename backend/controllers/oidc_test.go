@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"runnerx/config"
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	mockClientID    = "runnerx-test"
+	mockRedirectURL = "http://runnerx.test/api/auth/oidc/callback"
+)
+
+// mockIdP is a minimal OpenID provider serving discovery, JWKS, authorize and
+// token endpoints. It enforces PKCE and signs RS256 ID tokens carrying the
+// nonce from the authorization request.
+type mockIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu sync.Mutex
+	// claims are added to the next ID tokens
+	claims jwt.MapClaims
+	// nonce, when set, replaces the nonce from the authorization request
+	nonce  string
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client_id") != mockClientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+			http.Error(w, "bad authorization request", http.StatusBadRequest)
+			return
+		}
+		code := randomTestString(t)
+		idp.mu.Lock()
+		idp.grants[code] = mockGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+		idp.mu.Unlock()
+		redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		clientID, _, ok := r.BasicAuth()
+		if !ok {
+			clientID = r.PostForm.Get("client_id")
+		}
+		idp.mu.Lock()
+		grant, found := idp.grants[r.PostForm.Get("code")]
+		delete(idp.grants, r.PostForm.Get("code"))
+		claims := jwt.MapClaims{}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		nonce := idp.nonce
+		idp.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !found || clientID != mockClientID || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		if nonce == "" {
+			nonce = grant.nonce
+		}
+		now := time.Now()
+		claims["iss"] = idp.URL
+		claims["aud"] = mockClientID
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(time.Minute).Unix()
+		claims["nonce"] = nonce
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "mock-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) setClaims(claims jwt.MapClaims) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.claims = claims
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomTestString(t *testing.T) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type oidcTestEnv struct {
+	db     *gorm.DB
+	idp    *mockIdP
+	router *gin.Engine
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+	db := newTestDB(t)
+	idp := newMockIdP(t)
+	ac := &AuthController{
+		DB:        db,
+		JWTSecret: "test-secret",
+		OIDC: services.NewOIDCService(db, config.OIDCConfig{
+			Issuer:      idp.URL,
+			ClientID:    mockClientID,
+			RedirectURL: mockRedirectURL,
+			Scopes:      []string{"openid", "email", "profile"},
+			DefaultRole: "user",
+		}),
+	}
+
+	router := gin.New()
+	auth := router.Group("/api/auth")
+	auth.GET("/oidc/login", ac.OIDCLogin)
+	auth.GET("/oidc/callback", ac.OIDCCallback)
+	return &oidcTestEnv{db: db, idp: idp, router: router}
+}
+
+// authorize starts a JSON login, lets the mock provider approve it and returns
+// the callback URL together with the binding cookie set by the login
+func (env *oidcTestEnv) authorize(t *testing.T) (*url.URL, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login?response=json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var binding *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == oidcBindingCookie {
+			binding = cookie
+		}
+	}
+	if binding == nil || binding.Value == "" {
+		t.Fatal("login did not set the binding cookie")
+	}
+	if !binding.HttpOnly || binding.Path != "/api/auth/oidc/callback" {
+		t.Fatalf("binding cookie must be HttpOnly and scoped to the callback, got %+v", binding)
+	}
+
+	var body struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(body.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: got %d", resp.StatusCode)
+	}
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback, binding
+}
+
+func (env *oidcTestEnv) callback(callback *url.URL, binding *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	if binding != nil {
+		req.AddCookie(binding)
+	}
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func verifiedClaims(sub, email string) jwt.MapClaims {
+	return jwt.MapClaims{"sub": sub, "email": email, "email_verified": true, "name": "SSO User"}
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.idp.setClaims(verifiedClaims("sub-1", "SSO@example.com"))
+
+	callback, binding := env.authorize(t)
+	rec := env.callback(callback, binding)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback: got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"refresh_token"`) {
+		t.Fatalf("callback did not start a session: %s", rec.Body.String())
+	}
+
+	var user models.User
+	if err := env.db.Where("oidc_subject = ?", "sub-1").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "sso@example.com" || !user.EmailVerified() {
+		t.Fatalf("unexpected user %q verified=%v", user.Email, user.EmailVerified())
+	}
+
+	// The attempt is consumed by the first callback
+	if rec := env.callback(callback, binding); rec.Code != http.StatusBadRequest {
+		t.Fatalf("replayed callback: got %d", rec.Code)
+	}
+}
+
+func TestOIDCCallbackRequiresBinding(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.idp.setClaims(verifiedClaims("sub-1", "sso@example.com"))
+	callback, binding := env.authorize(t)
+
+	forged := &http.Cookie{Name: oidcBindingCookie, Value: "forged"}
+	for name, cookie := range map[string]*http.Cookie{"missing": nil, "forged": forged} {
+		if rec := env.callback(callback, cookie); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s binding: got %d", name, rec.Code)
+		}
+	}
+
+	// Failed attempts from another browser leave the login usable
+	if rec := env.callback(callback, binding); rec.Code != http.StatusOK {
+		t.Fatalf("callback with binding: got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOIDCCallbackRejectsUnknownState(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.idp.setClaims(verifiedClaims("sub-1", "sso@example.com"))
+	callback, binding := env.authorize(t)
+
+	q := callback.Query()
+	q.Set("state", "unknown")
+	callback.RawQuery = q.Encode()
+	if rec := env.callback(callback, binding); rec.Code != http.StatusBadRequest {
+		t.Fatalf("got %d", rec.Code)
+	}
+}
+
+func TestOIDCCallbackChecksNonce(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.idp.setClaims(verifiedClaims("sub-1", "sso@example.com"))
+	env.idp.nonce = "another-login"
+	callback, binding := env.authorize(t)
+
+	if rec := env.callback(callback, binding); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+	var count int64
+	env.db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("a user was created despite the nonce mismatch")
+	}
+}
+
+func TestOIDCCallbackChecksPKCE(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.idp.setClaims(verifiedClaims("sub-1", "sso@example.com"))
+	callback, binding := env.authorize(t)
+
+	// A verifier that does not match the challenge sent to the provider
+	env.db.Model(&models.OIDCLogin{}).Where("state = ?", callback.Query().Get("state")).
+		Update("code_verifier", "not-the-original-verifier-0123456789abcdefghijk")
+	if rec := env.callback(callback, binding); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOIDCCallbackRequiresVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+	}{
+		{"provision", false},
+		{"link", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			if tt.existing {
+				if err := env.db.Create(&models.User{Name: "Local", Email: "sso@example.com", Password: "password"}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			claims := verifiedClaims("sub-1", "sso@example.com")
+			claims["email_verified"] = false
+			env.idp.setClaims(claims)
+
+			callback, binding := env.authorize(t)
+			if rec := env.callback(callback, binding); rec.Code != http.StatusForbidden {
+				t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+			}
+			var count int64
+			env.db.Model(&models.User{}).Where("oidc_subject = ?", "sub-1").Count(&count)
+			if count != 0 {
+				t.Fatalf("unverified email was linked or provisioned")
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -13,10 +14,13 @@ import (
 	"gorm.io/gorm"
 )
 
-type ScreenshotsController struct{ DB *gorm.DB }
+type ScreenshotsController struct {
+	DB          *gorm.DB
+	screenshots *services.ScreenshotService
+}
 
-func NewScreenshotsController(db *gorm.DB) *ScreenshotsController {
-	return &ScreenshotsController{DB: db}
+func NewScreenshotsController(db *gorm.DB, screenshots *services.ScreenshotService) *ScreenshotsController {
+	return &ScreenshotsController{DB: db, screenshots: screenshots}
 }
 
 // GET /api/screenshots/:incidentId -> returns latest screenshot file
@@ -27,6 +31,13 @@ func (sc *ScreenshotsController) GetLatest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "incidentId required"})
 		return
 	}
+	// Monitor incidents are shared with the monitor's team, so look them up under the owner
+	if monitor, ok := incidentMonitor(c, sc.DB, incidentID); ok {
+		userID = monitor.UserID
+	} else if strings.HasPrefix(incidentID, "monitor-") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+		return
+	}
 	var rec models.IncidentScreenshot
 	if err := sc.DB.Where("user_id = ? AND incident_id = ?", userID, incidentID).Order("created_at DESC").First(&rec).Error; err != nil {
 		// Don't log error - 404 is expected when no screenshot exists yet
@@ -42,33 +53,39 @@ func (sc *ScreenshotsController) GetLatest(c *gin.Context) {
 
 // POST /api/screenshots/:incidentId/capture -> triggers capture for http monitors
 func (sc *ScreenshotsController) CaptureLatest(c *gin.Context) {
-	userID, _ := middleware.GetUserID(c)
 	incidentID := c.Param("incidentId")
 	if incidentID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "incidentId required"})
 		return
 	}
 	// Expect incidentId format monitor-<id>
-	var monitor models.Monitor
-	if strings.HasPrefix(incidentID, "monitor-") {
-		idStr := strings.TrimPrefix(incidentID, "monitor-")
-		if idStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid incidentId"})
-			return
-		}
-		if err := sc.DB.Where("id = ? AND user_id = ?", idStr, userID).First(&monitor).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
-			return
-		}
-	} else {
+	if !strings.HasPrefix(incidentID, "monitor-") || strings.TrimPrefix(incidentID, "monitor-") == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported incidentId"})
 		return
 	}
+	monitor, ok := incidentMonitor(c, sc.DB, incidentID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+		return
+	}
 	if monitor.Type != "http" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "screenshots supported for HTTP monitors"})
 		return
 	}
-	ss := services.NewScreenshotService(sc.DB, nil)
-	go ss.CaptureAndStore(userID, incidentID, monitor.Endpoint, monitor.ID)
+	go sc.screenshots.CaptureAndStore(context.WithoutCancel(c.Request.Context()), monitor.UserID, incidentID, monitor.Endpoint, monitor.ID)
 	c.JSON(http.StatusAccepted, gin.H{"status": "capture started"})
 }
+
+// incidentMonitor resolves a monitor-<id> incident ID to a monitor in the
+// workspace selected for the request
+func incidentMonitor(c *gin.Context, db *gorm.DB, incidentID string) (*models.Monitor, bool) {
+	idStr := strings.TrimPrefix(incidentID, "monitor-")
+	if idStr == incidentID || idStr == "" {
+		return nil, false
+	}
+	var monitor models.Monitor
+	if err := db.Scopes(middleware.OwnedBy(c)).Where("id = ?", idStr).First(&monitor).Error; err != nil {
+		return nil, false
+	}
+	return &monitor, true
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	ws "runnerx/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SessionController struct {
+	DB  *gorm.DB
+	Hub *ws.Hub
+}
+
+func NewSessionController(db *gorm.DB, hub *ws.Hub) *SessionController {
+	return &SessionController{DB: db, Hub: hub}
+}
+
+// SessionResponse is a login session as listed to its owner
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions lists the current user's active login sessions
+func (sc *SessionController) GetSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	currentID, _ := middleware.GetSessionID(c)
+
+	var sessions []models.Session
+	if err := sc.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.ID == currentID})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession ends one of the current user's sessions and disconnects its WebSockets
+func (sc *SessionController) RevokeSession(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	revoked, err := revokeSessions(sc.DB, sc.Hub, "id = ? AND user_id = ?", c.Param("id"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if revoked == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions ends every other session of the current user. Pass
+// include_current=true to end the current session as well.
+func (sc *SessionController) RevokeAllSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	currentID, _ := middleware.GetSessionID(c)
+
+	query := sc.DB.Where("user_id = ?", userID)
+	if c.Query("include_current") != "true" {
+		query = query.Where("id <> ?", currentID)
+	}
+
+	revoked, err := revokeSessions(sc.DB, sc.Hub, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
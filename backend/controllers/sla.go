@@ -8,6 +8,7 @@ import (
     "github.com/gin-gonic/gin"
     "gorm.io/gorm"
     "runnerx/middleware"
+    "runnerx/models"
     "runnerx/services"
 )
 
@@ -25,15 +26,13 @@ func NewSLAController(db *gorm.DB) *SLAController {
 
 // GET /api/sla - Get SLA reports for user
 func (sc *SLAController) GetSLAReports(c *gin.Context) {
-    userID, _ := middleware.GetUserID(c)
-    
     daysStr := c.DefaultQuery("days", "30")
     days, err := strconv.Atoi(daysStr)
     if err != nil || days < 1 || days > 365 {
         days = 30
     }
     
-    reports, err := sc.slaService.GetSLAReportsForUser(userID, days)
+    reports, err := sc.slaService.GetSLAReports(middleware.OwnedBy(c), days)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch SLA reports"})
         return
@@ -44,8 +43,6 @@ func (sc *SLAController) GetSLAReports(c *gin.Context) {
 
 // GET /api/sla/:monitorId - Get SLA reports for specific monitor
 func (sc *SLAController) GetMonitorSLAReports(c *gin.Context) {
-    userID, _ := middleware.GetUserID(c)
-    
     monitorIDStr := c.Param("monitorId")
     monitorID, err := strconv.ParseUint(monitorIDStr, 10, 64)
     if err != nil {
@@ -59,7 +56,7 @@ func (sc *SLAController) GetMonitorSLAReports(c *gin.Context) {
         days = 30
     }
     
-    reports, err := sc.slaService.GetSLAReportsForMonitor(userID, uint(monitorID), days)
+    reports, err := sc.slaService.GetSLAReportsForMonitor(middleware.OwnedBy(c), uint(monitorID), days)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch SLA reports"})
         return
@@ -70,8 +67,6 @@ func (sc *SLAController) GetMonitorSLAReports(c *gin.Context) {
 
 // POST /api/sla/generate - Manually generate SLA reports
 func (sc *SLAController) GenerateSLAReports(c *gin.Context) {
-    userID, _ := middleware.GetUserID(c)
-    
     var req struct {
         MonitorID *uint `json:"monitor_id,omitempty"`
         Days      int   `json:"days,omitempty"`
@@ -88,8 +83,13 @@ func (sc *SLAController) GenerateSLAReports(c *gin.Context) {
     
     if req.MonitorID != nil {
         // Generate for specific monitor
+        var monitor models.Monitor
+        if err := sc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", *req.MonitorID).First(&monitor).Error; err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
+            return
+        }
         reportDate := time.Now().Truncate(24 * time.Hour).Add(-24 * time.Hour) // Yesterday
-        _, err := sc.slaService.CalculateSLAForMonitor(userID, *req.MonitorID, reportDate)
+        _, err := sc.slaService.CalculateSLAForMonitor(monitor.UserID, monitor.ID, reportDate)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate SLA report"})
             return
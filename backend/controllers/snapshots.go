@@ -16,12 +16,11 @@ func NewSnapshotsController(db *gorm.DB) *SnapshotsController { return &Snapshot
 
 // GET /api/snapshots/:serverId -> recent performance snapshots
 func (sc *SnapshotsController) Get(c *gin.Context) {
-    userID, _ := middleware.GetUserID(c)
     idStr := c.Param("serverId")
     monitorID, _ := strconv.ParseUint(idStr, 10, 64)
-    // ensure ownership: monitor belongs to user
+    // ensure ownership: monitor belongs to the user or their selected team
     var mon models.Monitor
-    if err := sc.DB.Where("id = ? AND user_id = ?", monitorID, userID).First(&mon).Error; err != nil {
+    if err := sc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", monitorID).First(&mon).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "monitor not found"})
         return
     }
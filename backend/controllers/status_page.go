@@ -1,3 +1,762 @@
 package controllers
 
-// Status Page controller removed per spec
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StatusPageTokenHeader carries the token that unlocks a password protected page
+const StatusPageTokenHeader = "X-Status-Page-Token"
+
+var (
+	slugPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}[a-z0-9]$`)
+	domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+type StatusPageController struct {
+	DB        *gorm.DB
+	service   *services.StatusPageService
+	notifier  *services.StatusNotifier
+	jwtSecret string
+}
+
+func NewStatusPageController(db *gorm.DB, jwtSecret string, notifier *services.StatusNotifier) *StatusPageController {
+	return &StatusPageController{DB: db, service: services.NewStatusPageService(db), notifier: notifier, jwtSecret: jwtSecret}
+}
+
+// StatusPageItemRequest shows either a monitor or a group on the page
+type StatusPageItemRequest struct {
+	MonitorID   *uint  `json:"monitor_id"`
+	GroupID     *uint  `json:"group_id"`
+	DisplayName string `json:"display_name"`
+}
+
+type StatusPageRequest struct {
+	Slug         string `json:"slug" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description"`
+	Layout       string `json:"layout" binding:"omitempty,oneof=grid list"`
+	ShowUptime   *bool  `json:"show_uptime"`
+	ShowLatency  *bool  `json:"show_latency"`
+	CustomDomain string `json:"custom_domain"`
+	// Password sets the page's password; an empty string removes it and
+	// leaving it out keeps the current one
+	Password *string                 `json:"password"`
+	Items    []StatusPageItemRequest `json:"items" binding:"dive"`
+}
+
+type StatusIncidentRequest struct {
+	Title   string `json:"title" binding:"required"`
+	Status  string `json:"status" binding:"omitempty,oneof=investigating identified monitoring resolved"`
+	Impact  string `json:"impact" binding:"omitempty,oneof=none minor major critical"`
+	Message string `json:"message" binding:"required"`
+	// Components are the affected items, as "monitor:<id>" or "group:<id>";
+	// none means the whole page
+	Components []string `json:"components"`
+}
+
+type StatusIncidentUpdateRequest struct {
+	Status  string `json:"status" binding:"required,oneof=investigating identified monitoring resolved"`
+	Message string `json:"message" binding:"required"`
+}
+
+type MaintenanceRequest struct {
+	Title       string    `json:"title" binding:"required"`
+	Description string    `json:"description"`
+	StartsAt    time.Time `json:"starts_at" binding:"required"`
+	EndsAt      time.Time `json:"ends_at" binding:"required"`
+}
+
+func (sc *StatusPageController) GetStatusPages(c *gin.Context) {
+	var pages []models.StatusPage
+	if err := sc.DB.Scopes(middleware.OwnedBy(c)).Order("name").Find(&pages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch status pages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pages)
+}
+
+func (sc *StatusPageController) GetStatusPage(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	if err := sc.DB.Where("status_page_id = ?", page.ID).Order("position, id").Find(&page.Items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch status page"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (sc *StatusPageController) CreateStatusPage(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req StatusPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := models.StatusPage{UserID: userID, TeamID: middleware.GetTeamIDPtr(c), ShowUptime: true, ShowLatency: true}
+	items, err := sc.applyPageRequest(c, &page, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(&page).Error; err != nil {
+			return err
+		}
+		return replaceItems(tx, page.ID, items)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create status page"})
+		return
+	}
+	page.Items = items
+	recordAudit(sc.DB, c, models.AuditStatusPageCreate, "status_page", page.ID, gin.H{"slug": page.Slug, "name": page.Name})
+
+	c.JSON(http.StatusCreated, page)
+}
+
+// UpdateStatusPage changes a page's settings and replaces its items
+func (sc *StatusPageController) UpdateStatusPage(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var req StatusPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := statusPageAuditFields(page)
+	items, err := sc.applyPageRequest(c, page, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = sc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Save(page).Error; err != nil {
+			return err
+		}
+		return replaceItems(tx, page.ID, items)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status page"})
+		return
+	}
+	page.Items = items
+	recordAudit(sc.DB, c, models.AuditStatusPageUpdate, "status_page", page.ID, gin.H{
+		"before": before, "after": statusPageAuditFields(page),
+	})
+
+	c.JSON(http.StatusOK, page)
+}
+
+// DeleteStatusPage removes a page with its incidents and maintenance. The
+// page is deleted for good so its slug and domain can be used again.
+func (sc *StatusPageController) DeleteStatusPage(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteStatusPages(sc.DB, []uint{page.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete status page"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditStatusPageDelete, "status_page", page.ID, statusPageAuditFields(page))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Status page deleted successfully"})
+}
+
+// VerifyDomain looks for the page's domain token in the TXT record at
+// _runnerx-challenge.<pending domain> and, when found, starts serving the
+// page on that domain, taking it from any page that held it before
+func (sc *StatusPageController) VerifyDomain(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+	if page.PendingDomain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No custom domain is waiting for verification"})
+		return
+	}
+
+	record := models.DomainChallengePrefix + page.PendingDomain
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	values, _ := net.DefaultResolver.LookupTXT(ctx, record)
+	found := false
+	for _, value := range values {
+		if strings.TrimSpace(value) == page.DomainToken {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "The verification record was not found, DNS changes can take a while to propagate",
+			"record": gin.H{"type": "TXT", "name": record, "value": page.DomainToken},
+		})
+		return
+	}
+
+	before := statusPageAuditFields(page)
+	page.VerifyDomain(time.Now())
+	err := sc.DB.Transaction(func(tx *gorm.DB) error {
+		var previous []models.StatusPage
+		if err := tx.Where("custom_domain = ? AND id <> ?", *page.CustomDomain, page.ID).Find(&previous).Error; err != nil {
+			return err
+		}
+		for _, other := range previous {
+			if err := tx.Model(&other).Updates(map[string]interface{}{"custom_domain": nil, "domain_verified_at": nil}).Error; err != nil {
+				return err
+			}
+			log.Printf("Status page %d lost custom domain %s to status page %d", other.ID, *page.CustomDomain, page.ID)
+		}
+		return tx.Omit("Items").Save(page).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify custom domain"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditStatusDomainVerify, "status_page", page.ID, gin.H{
+		"before": before, "after": statusPageAuditFields(page),
+	})
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetStatusIncidents lists all incidents of a page, newest first
+func (sc *StatusPageController) GetStatusIncidents(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var incidents []models.StatusPageIncident
+	if err := sc.DB.Preload("Updates", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		Where("status_page_id = ?", page.ID).Order("created_at DESC").Find(&incidents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch incidents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incidents)
+}
+
+// CreateStatusIncident announces an incident with its first update
+func (sc *StatusPageController) CreateStatusIncident(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var req StatusIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == "" {
+		req.Status = models.StatusIncidentInvestigating
+	}
+	if req.Impact == "" {
+		req.Impact = "minor"
+	}
+	if err := sc.checkComponents(page, req.Components); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	incident := models.StatusPageIncident{
+		StatusPageID: page.ID,
+		Title:        req.Title,
+		Status:       req.Status,
+		Impact:       req.Impact,
+		Components:   models.StringArray(req.Components),
+		Updates:      []models.StatusPageIncidentUpdate{{Status: req.Status, Message: req.Message}},
+	}
+	if req.Status == models.StatusIncidentResolved {
+		now := time.Now()
+		incident.ResolvedAt = &now
+	}
+
+	if err := sc.DB.Create(&incident).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create incident"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditStatusIncidentCreate, "status_page_incident", incident.ID, gin.H{
+		"status_page_id": page.ID, "title": incident.Title, "status": incident.Status,
+	})
+	go sc.notifier.NotifyIncident(context.WithoutCancel(c.Request.Context()), page, &incident, &incident.Updates[0], services.IncidentOpened)
+
+	c.JSON(http.StatusCreated, incident)
+}
+
+// AddStatusIncidentUpdate posts an update and moves the incident to its status
+func (sc *StatusPageController) AddStatusIncidentUpdate(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var incident models.StatusPageIncident
+	if err := sc.DB.Where("id = ? AND status_page_id = ?", c.Param("incidentId"), page.ID).First(&incident).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	var req StatusIncidentUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := models.StatusPageIncidentUpdate{IncidentID: incident.ID, Status: req.Status, Message: req.Message}
+	err := sc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&update).Error; err != nil {
+			return err
+		}
+		incident.Status = req.Status
+		incident.ResolvedAt = nil
+		if req.Status == models.StatusIncidentResolved {
+			incident.ResolvedAt = &update.CreatedAt
+		}
+		return tx.Model(&incident).Select("status", "resolved_at").Updates(&incident).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update incident"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditStatusIncidentUpdate, "status_page_incident", incident.ID, gin.H{
+		"status_page_id": page.ID, "status": req.Status,
+	})
+	event := services.IncidentUpdated
+	if req.Status == models.StatusIncidentResolved {
+		event = services.IncidentResolved
+	}
+	go sc.notifier.NotifyIncident(context.WithoutCancel(c.Request.Context()), page, &incident, &update, event)
+
+	c.JSON(http.StatusCreated, update)
+}
+
+func (sc *StatusPageController) DeleteStatusIncident(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var incident models.StatusPageIncident
+	if err := sc.DB.Where("id = ? AND status_page_id = ?", c.Param("incidentId"), page.ID).First(&incident).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+		return
+	}
+
+	err := sc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("incident_id = ?", incident.ID).Delete(&models.StatusPageIncidentUpdate{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&incident).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete incident"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditStatusIncidentDelete, "status_page_incident", incident.ID, gin.H{
+		"status_page_id": page.ID, "title": incident.Title,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Incident deleted successfully"})
+}
+
+// GetMaintenances lists all maintenance windows of a page
+func (sc *StatusPageController) GetMaintenances(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var maintenances []models.StatusPageMaintenance
+	if err := sc.DB.Where("status_page_id = ?", page.ID).Order("starts_at DESC").Find(&maintenances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch maintenance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, maintenances)
+}
+
+func (sc *StatusPageController) CreateMaintenance(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+
+	maintenance := models.StatusPageMaintenance{
+		StatusPageID: page.ID,
+		Title:        req.Title,
+		Description:  req.Description,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+	}
+	if err := sc.DB.Create(&maintenance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule maintenance"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditMaintenanceCreate, "status_page_maintenance", maintenance.ID, gin.H{
+		"status_page_id": page.ID, "title": maintenance.Title, "starts_at": maintenance.StartsAt, "ends_at": maintenance.EndsAt,
+	})
+
+	c.JSON(http.StatusCreated, maintenance)
+}
+
+func (sc *StatusPageController) UpdateMaintenance(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var maintenance models.StatusPageMaintenance
+	if err := sc.DB.Where("id = ? AND status_page_id = ?", c.Param("maintenanceId"), page.ID).First(&maintenance).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance not found"})
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+
+	maintenance.Title = req.Title
+	maintenance.Description = req.Description
+	maintenance.StartsAt = req.StartsAt
+	maintenance.EndsAt = req.EndsAt
+	if err := sc.DB.Save(&maintenance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update maintenance"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditMaintenanceUpdate, "status_page_maintenance", maintenance.ID, gin.H{
+		"status_page_id": page.ID, "title": maintenance.Title, "starts_at": maintenance.StartsAt, "ends_at": maintenance.EndsAt,
+	})
+
+	c.JSON(http.StatusOK, maintenance)
+}
+
+func (sc *StatusPageController) DeleteMaintenance(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var maintenance models.StatusPageMaintenance
+	if err := sc.DB.Where("id = ? AND status_page_id = ?", c.Param("maintenanceId"), page.ID).First(&maintenance).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance not found"})
+		return
+	}
+
+	if err := sc.DB.Delete(&maintenance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete maintenance"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditMaintenanceDelete, "status_page_maintenance", maintenance.ID, gin.H{
+		"status_page_id": page.ID, "title": maintenance.Title,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance deleted successfully"})
+}
+
+// GetPublicStatus serves a page by slug, without authentication
+func (sc *StatusPageController) GetPublicStatus(c *gin.Context) {
+	var page models.StatusPage
+	if err := sc.DB.Where("slug = ?", c.Param("slug")).First(&page).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Status page not found"})
+		return
+	}
+	sc.servePublicStatus(c, &page)
+}
+
+// GetPublicStatusByDomain serves the page mapped to the request's Host
+func (sc *StatusPageController) GetPublicStatusByDomain(c *gin.Context) {
+	var page models.StatusPage
+	if err := sc.DB.Where("custom_domain = ?", requestDomain(c)).First(&page).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Status page not found"})
+		return
+	}
+	sc.servePublicStatus(c, &page)
+}
+
+// UnlockStatusPage exchanges a page's password for a token to send in the
+// X-Status-Page-Token header. Changing the password invalidates old tokens.
+func (sc *StatusPageController) UnlockStatusPage(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, ok := sc.findPublicPage(c)
+	if !ok {
+		return
+	}
+
+	if !page.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": sc.pageToken(page)})
+}
+
+func (sc *StatusPageController) servePublicStatus(c *gin.Context, page *models.StatusPage) {
+	if page.Protected {
+		if !sc.unlocked(c, page) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "This status page is password protected", "password_required": true, "name": page.Name})
+			return
+		}
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		c.Header("Cache-Control", "public, max-age=30")
+	}
+
+	status, err := sc.service.BuildPublicStatus(page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load status page"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page": gin.H{
+			"name":         page.Name,
+			"slug":         page.Slug,
+			"description":  page.Description,
+			"layout":       page.Layout,
+			"show_uptime":  page.ShowUptime,
+			"show_latency": page.ShowLatency,
+		},
+		"status":       status.Status,
+		"components":   status.Components,
+		"incidents":    status.Incidents,
+		"maintenances": status.Maintenances,
+		"generated_at": status.GeneratedAt,
+	})
+}
+
+// unlocked reports whether the request carries the page's unlock token, in
+// the header or, for feed readers, the token query parameter
+func (sc *StatusPageController) unlocked(c *gin.Context, page *models.StatusPage) bool {
+	if !page.Protected {
+		return true
+	}
+	token := c.GetHeader(StatusPageTokenHeader)
+	if token == "" {
+		token = c.Query("token")
+	}
+	return hmac.Equal([]byte(token), []byte(sc.pageToken(page)))
+}
+
+// pageToken derives the unlock token from the page's password hash
+func (sc *StatusPageController) pageToken(page *models.StatusPage) string {
+	mac := hmac.New(sha256.New, []byte(sc.jwtSecret))
+	fmt.Fprintf(mac, "status-page:%d:%s", page.ID, page.PasswordHash)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// findPage loads the page named in the URL from the current workspace,
+// answering 404 when there is none
+func (sc *StatusPageController) findPage(c *gin.Context) (*models.StatusPage, bool) {
+	var page models.StatusPage
+	if err := sc.DB.Scopes(middleware.OwnedBy(c)).Where("id = ?", c.Param("id")).First(&page).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Status page not found"})
+		return nil, false
+	}
+	return &page, true
+}
+
+// findPublicPage loads the page named by the slug in the URL or, without
+// one, the page mapped to the request's Host
+func (sc *StatusPageController) findPublicPage(c *gin.Context) (*models.StatusPage, bool) {
+	var page models.StatusPage
+	query := sc.DB.Where("slug = ?", c.Param("slug"))
+	if c.Param("slug") == "" {
+		query = sc.DB.Where("custom_domain = ?", requestDomain(c))
+	}
+	if err := query.First(&page).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Status page not found"})
+		return nil, false
+	}
+	return &page, true
+}
+
+// applyPageRequest validates the request and copies it onto the page,
+// returning the page's new items
+func (sc *StatusPageController) applyPageRequest(c *gin.Context, page *models.StatusPage, req StatusPageRequest) ([]models.StatusPageItem, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !slugPattern.MatchString(slug) {
+		return nil, errors.New("slug must be 3-64 lowercase letters, digits or hyphens")
+	}
+	if taken(sc.DB.Unscoped().Model(&models.StatusPage{}).Where("slug = ? AND id <> ?", slug, page.ID)) {
+		return nil, errors.New("slug is already taken")
+	}
+
+	// Another page holding the domain does not block the request: the domain
+	// goes to whoever proves control of it through VerifyDomain
+	domain := strings.ToLower(strings.TrimSpace(req.CustomDomain))
+	if domain != "" && !domainPattern.MatchString(domain) {
+		return nil, errors.New("custom_domain must be a host name such as status.example.com")
+	}
+
+	items := make([]models.StatusPageItem, 0, len(req.Items))
+	seen := map[string]bool{}
+	for i, item := range req.Items {
+		if (item.MonitorID == nil) == (item.GroupID == nil) {
+			return nil, errors.New("each item needs exactly one of monitor_id and group_id")
+		}
+		var key string
+		if item.MonitorID != nil {
+			key = fmt.Sprintf("monitor %d", *item.MonitorID)
+			if !taken(sc.DB.Model(&models.Monitor{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", *item.MonitorID)) {
+				return nil, fmt.Errorf("%s not found", key)
+			}
+		} else {
+			key = fmt.Sprintf("monitor group %d", *item.GroupID)
+			if !taken(sc.DB.Model(&models.MonitorGroup{}).Scopes(middleware.OwnedBy(c)).Where("id = ?", *item.GroupID)) {
+				return nil, fmt.Errorf("%s not found", key)
+			}
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s is listed more than once", key)
+		}
+		seen[key] = true
+		items = append(items, models.StatusPageItem{
+			MonitorID:   item.MonitorID,
+			GroupID:     item.GroupID,
+			DisplayName: strings.TrimSpace(item.DisplayName),
+			Position:    i,
+		})
+	}
+
+	page.Slug = slug
+	page.Name = req.Name
+	page.Description = req.Description
+	page.Layout = req.Layout
+	if page.Layout == "" {
+		page.Layout = "grid"
+	}
+	if req.ShowUptime != nil {
+		page.ShowUptime = *req.ShowUptime
+	}
+	if req.ShowLatency != nil {
+		page.ShowLatency = *req.ShowLatency
+	}
+	if err := page.RequestDomain(domain); err != nil {
+		return nil, err
+	}
+	if req.Password != nil {
+		if err := page.SetPassword(*req.Password); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// checkComponents makes sure every component key names an item of the page
+func (sc *StatusPageController) checkComponents(page *models.StatusPage, components []string) error {
+	if len(components) == 0 {
+		return nil
+	}
+	var items []models.StatusPageItem
+	if err := sc.DB.Where("status_page_id = ?", page.ID).Find(&items).Error; err != nil {
+		return err
+	}
+	keys := map[string]bool{}
+	for _, item := range items {
+		if item.MonitorID != nil {
+			keys[models.ComponentKey("monitor", *item.MonitorID)] = true
+		} else if item.GroupID != nil {
+			keys[models.ComponentKey("group", *item.GroupID)] = true
+		}
+	}
+	for _, key := range components {
+		if !keys[key] {
+			return fmt.Errorf("component %q is not on this status page", key)
+		}
+	}
+	return nil
+}
+
+func taken(query *gorm.DB) bool {
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+func replaceItems(tx *gorm.DB, pageID uint, items []models.StatusPageItem) error {
+	if err := tx.Where("status_page_id = ?", pageID).Delete(&models.StatusPageItem{}).Error; err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].ID = 0
+		items[i].StatusPageID = pageID
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tx.Create(&items).Error
+}
+
+// requestDomain is the request's Host without the port
+func requestDomain(c *gin.Context) string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// statusPageAuditFields is the part of a page recorded in the audit trail
+func statusPageAuditFields(page *models.StatusPage) gin.H {
+	return gin.H{
+		"slug": page.Slug, "name": page.Name, "custom_domain": page.CustomDomain, "pending_domain": page.PendingDomain, "password_protected": page.Protected,
+	}
+}
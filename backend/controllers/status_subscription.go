@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SubscribeRequest struct {
+	Type       string   `json:"type" binding:"required,oneof=email webhook"`
+	Email      string   `json:"email" binding:"omitempty,email"`
+	URL        string   `json:"url"`
+	Components []string `json:"components"`
+}
+
+type SubscriptionTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type UnsubscribeRequest struct {
+	ID    uint   `json:"id" binding:"required"`
+	Token string `json:"token" binding:"required"`
+}
+
+// Subscribe signs a visitor up for incident updates. Email addresses get a
+// confirmation link and the response does not tell whether the address was
+// already subscribed. Webhooks are active at once and receive their signing
+// secret in the response.
+func (sc *StatusPageController) Subscribe(c *gin.Context) {
+	page, ok := sc.findPublicPage(c)
+	if !ok {
+		return
+	}
+	if !sc.unlocked(c, page) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This status page is password protected", "password_required": true})
+		return
+	}
+
+	var req SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sc.checkComponents(page, req.Components); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Type == models.SubscriberEmail {
+		sc.subscribeEmail(c, page, req)
+		return
+	}
+	sc.subscribeWebhook(c, page, req)
+}
+
+func (sc *StatusPageController) subscribeEmail(c *gin.Context, page *models.StatusPage, req SubscribeRequest) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+
+	var sub models.StatusPageSubscriber
+	err := sc.DB.Where("status_page_id = ? AND type = ? AND email = ?", page.ID, models.SubscriberEmail, email).First(&sub).Error
+	if err == nil && sub.ConfirmedAt != nil {
+		// Already subscribed; the owner of the address keeps their choices
+		c.JSON(http.StatusAccepted, gin.H{"message": "Check your inbox to confirm the subscription"})
+		return
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+
+	token, hash, err := models.GenerateSecretToken(models.StatusSubscriberTokenPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+	now := time.Now()
+	sub.StatusPageID = page.ID
+	sub.Type = models.SubscriberEmail
+	sub.Email = email
+	sub.Components = models.StringArray(req.Components)
+	sub.ConfirmTokenHash = hash
+	sub.ConfirmSentAt = &now
+	if err := sc.DB.Save(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+	sc.notifier.SendConfirmation(page, &sub, token)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check your inbox to confirm the subscription"})
+}
+
+func (sc *StatusPageController) subscribeWebhook(c *gin.Context, page *models.StatusPage, req SubscribeRequest) {
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http or https address"})
+		return
+	}
+
+	secret, _, err := models.GenerateSecretToken("whsec_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+	now := time.Now()
+	sub := models.StatusPageSubscriber{
+		StatusPageID:  page.ID,
+		Type:          models.SubscriberWebhook,
+		WebhookURL:    target.String(),
+		Components:    models.StringArray(req.Components),
+		ConfirmedAt:   &now,
+		WebhookSecret: secret,
+	}
+	if err := sc.DB.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+
+	// The secret and unsubscribe token are only shown here
+	c.JSON(http.StatusCreated, gin.H{
+		"id":                sub.ID,
+		"secret":            secret,
+		"unsubscribe_token": sc.notifier.UnsubscribeToken(&sub),
+	})
+}
+
+// ConfirmSubscription activates an email subscription from the emailed link
+func (sc *StatusPageController) ConfirmSubscription(c *gin.Context) {
+	var req SubscriptionTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sub models.StatusPageSubscriber
+	err := sc.DB.Where("confirm_token_hash = ? AND confirmed_at IS NULL AND confirm_sent_at > ?",
+		models.HashToken(req.Token), time.Now().Add(-models.SubscriberConfirmTTL)).First(&sub).Error
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+		return
+	}
+
+	now := time.Now()
+	if err := sc.DB.Model(&sub).Updates(map[string]interface{}{"confirmed_at": now, "confirm_token_hash": ""}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscription confirmed"})
+}
+
+// Unsubscribe removes a subscription with the token sent in every message
+func (sc *StatusPageController) Unsubscribe(c *gin.Context) {
+	var req UnsubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sub models.StatusPageSubscriber
+	if err := sc.DB.First(&sub, req.ID).Error; err != nil ||
+		!hmac.Equal([]byte(req.Token), []byte(sc.notifier.UnsubscribeToken(&sub))) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+
+	if err := sc.DB.Delete(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You have been unsubscribed"})
+}
+
+// GetRSSFeed serves the page's incident updates as RSS 2.0
+func (sc *StatusPageController) GetRSSFeed(c *gin.Context) {
+	sc.serveFeed(c, "application/rss+xml; charset=utf-8", services.RenderRSS)
+}
+
+// GetAtomFeed serves the page's incident updates as Atom
+func (sc *StatusPageController) GetAtomFeed(c *gin.Context) {
+	sc.serveFeed(c, "application/atom+xml; charset=utf-8", services.RenderAtom)
+}
+
+func (sc *StatusPageController) serveFeed(c *gin.Context, contentType string, render func(*models.StatusPage, string, []services.FeedEntry) ([]byte, error)) {
+	page, ok := sc.feedPage(c)
+	if !ok {
+		return
+	}
+
+	entries, err := sc.service.FeedEntries(page.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+	body, err := render(page, sc.notifier.PageURL(page), entries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// GetMaintenanceCalendar serves scheduled maintenance as an iCalendar feed
+func (sc *StatusPageController) GetMaintenanceCalendar(c *gin.Context) {
+	page, ok := sc.feedPage(c)
+	if !ok {
+		return
+	}
+
+	maintenances, err := sc.service.MaintenanceCalendar(page.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s-maintenance.ics"`, page.Slug))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", services.RenderICal(page, sc.notifier.PageURL(page), maintenances))
+}
+
+// feedPage loads the public page of a feed. Feed readers cannot send headers,
+// so protected pages take the unlock token as the token query parameter.
+func (sc *StatusPageController) feedPage(c *gin.Context) (*models.StatusPage, bool) {
+	page, ok := sc.findPublicPage(c)
+	if !ok {
+		return nil, false
+	}
+	if !sc.unlocked(c, page) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This status page is password protected", "password_required": true})
+		return nil, false
+	}
+	if page.Protected {
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		c.Header("Cache-Control", "public, max-age=300")
+	}
+	return page, true
+}
+
+// GetSubscribers lists the subscribers of a page
+func (sc *StatusPageController) GetSubscribers(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var subscribers []models.StatusPageSubscriber
+	if err := sc.DB.Where("status_page_id = ?", page.ID).Order("created_at DESC").Find(&subscribers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscribers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscribers)
+}
+
+func (sc *StatusPageController) DeleteSubscriber(c *gin.Context) {
+	page, ok := sc.findPage(c)
+	if !ok {
+		return
+	}
+
+	var sub models.StatusPageSubscriber
+	if err := sc.DB.Where("id = ? AND status_page_id = ?", c.Param("subscriberId"), page.ID).First(&sub).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
+		return
+	}
+
+	if err := sc.DB.Delete(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscriber"})
+		return
+	}
+	recordAudit(sc.DB, c, models.AuditSubscriberDelete, "status_page_subscriber", sub.ID, gin.H{
+		"status_page_id": page.ID, "type": sub.Type, "email": sub.Email, "webhook_url": sub.WebhookURL,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscriber deleted successfully"})
+}
@@ -0,0 +1,437 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// invitationPrefix marks team invitation tokens
+const invitationPrefix = "rnxi_"
+
+// invitationTTL is how long an invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+var errAlreadyMember = errors.New("already a member")
+
+type TeamController struct {
+	DB *gorm.DB
+}
+
+func NewTeamController(db *gorm.DB) *TeamController {
+	return &TeamController{DB: db}
+}
+
+type TeamRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TeamWithRole is a team as seen by one of its members
+type TeamWithRole struct {
+	models.Team
+	Role string `json:"role"`
+}
+
+// GetTeams lists the teams the current user belongs to
+func (tc *TeamController) GetTeams(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var teams []TeamWithRole
+	if err := tc.DB.Model(&models.Team{}).
+		Select("teams.*, team_memberships.role").
+		Joins("JOIN team_memberships ON team_memberships.team_id = teams.id").
+		Where("team_memberships.user_id = ?", userID).
+		Order("teams.name").
+		Scan(&teams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teams"})
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// CreateTeam creates a team; the creator becomes its owner
+func (tc *TeamController) CreateTeam(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req TeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	team := models.Team{Name: strings.TrimSpace(req.Name), CreatedByID: userID}
+	err := tc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&team).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TeamMembership{TeamID: team.ID, UserID: userID, Role: models.RoleOwner}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TeamWithRole{Team: team, Role: models.RoleOwner})
+}
+
+// GetTeam returns a team with its members
+func (tc *TeamController) GetTeam(c *gin.Context) {
+	team, membership, ok := tc.loadTeam(c, models.PermissionView)
+	if !ok {
+		return
+	}
+
+	var members []models.TeamMembership
+	if err := tc.DB.Preload("User").Where("team_id = ?", team.ID).Order("created_at").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch team members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team":    team,
+		"role":    membership.Role,
+		"members": members,
+	})
+}
+
+// UpdateTeam renames a team
+func (tc *TeamController) UpdateTeam(c *gin.Context) {
+	team, _, ok := tc.loadTeam(c, models.PermissionManageTeam)
+	if !ok {
+		return
+	}
+
+	var req TeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	team.Name = strings.TrimSpace(req.Name)
+	if err := tc.DB.Save(team).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeam deletes a team. Its monitors, monitor groups, status pages,
+// badge tokens, incidents and SLA reports return to the personal workspaces of the users who
+// created them.
+func (tc *TeamController) DeleteTeam(c *gin.Context) {
+	team, _, ok := tc.loadTeam(c, models.PermissionManageTeam)
+	if !ok {
+		return
+	}
+
+	err := tc.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.Monitor{}, &models.MonitorGroup{}, &models.StatusPage{}, &models.BadgeToken{}, &models.Incident{}, &models.SLAReport{}} {
+			if err := tx.Model(model).Where("team_id = ?", team.ID).Update("team_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("team_id = ?", team.ID).Delete(&models.TeamInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", team.ID).Delete(&models.TeamMembership{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(team).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+}
+
+// UpdateMember changes a member's role. Only owners can grant or change the
+// owner role, and a team always keeps at least one owner.
+func (tc *TeamController) UpdateMember(c *gin.Context) {
+	team, actor, ok := tc.loadTeam(c, models.PermissionManageMembers)
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidTeamRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + req.Role})
+		return
+	}
+
+	var member models.TeamMembership
+	if err := tc.DB.Where("team_id = ? AND user_id = ?", team.ID, c.Param("userId")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+		return
+	}
+
+	if (member.Role == models.RoleOwner || req.Role == models.RoleOwner) && actor.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can grant or change the owner role"})
+		return
+	}
+	if member.Role == models.RoleOwner && req.Role != models.RoleOwner && tc.isLastOwner(team.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A team must keep at least one owner"})
+		return
+	}
+
+	member.Role = req.Role
+	if err := tc.DB.Save(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member from a team. Any member may remove themselves.
+func (tc *TeamController) RemoveMember(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	team, actor, ok := tc.loadTeam(c, models.PermissionView)
+	if !ok {
+		return
+	}
+
+	var member models.TeamMembership
+	if err := tc.DB.Where("team_id = ? AND user_id = ?", team.ID, c.Param("userId")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+		return
+	}
+
+	if member.UserID != userID {
+		if !models.RoleAllows(actor.Role, models.PermissionManageMembers) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your team role does not allow this action"})
+			return
+		}
+		if member.Role == models.RoleOwner && actor.Role != models.RoleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can remove an owner"})
+			return
+		}
+	}
+	if member.Role == models.RoleOwner && tc.isLastOwner(team.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A team must keep at least one owner"})
+		return
+	}
+
+	if err := tc.DB.Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove team member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team member removed successfully"})
+}
+
+// GetInvitations lists a team's pending invitations
+func (tc *TeamController) GetInvitations(c *gin.Context) {
+	team, _, ok := tc.loadTeam(c, models.PermissionManageMembers)
+	if !ok {
+		return
+	}
+
+	var invitations []models.TeamInvitation
+	if err := tc.DB.Where("team_id = ? AND accepted_at IS NULL AND expires_at > ?", team.ID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// CreateInvitation invites an email address to a team. The plain invitation
+// token is only returned in this response.
+func (tc *TeamController) CreateInvitation(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	team, actor, ok := tc.loadTeam(c, models.PermissionManageMembers)
+	if !ok {
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidTeamRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + req.Role})
+		return
+	}
+	if req.Role == models.RoleOwner && actor.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can invite owners"})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	var existing int64
+	tc.DB.Model(&models.TeamMembership{}).
+		Joins("JOIN users ON users.id = team_memberships.user_id").
+		Where("team_memberships.team_id = ? AND LOWER(users.email) = ?", team.ID, email).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this team"})
+		return
+	}
+
+	plain, hash, err := models.GenerateSecretToken(invitationPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation"})
+		return
+	}
+
+	invitation := models.TeamInvitation{
+		TeamID:      team.ID,
+		Email:       email,
+		Role:        req.Role,
+		TokenHash:   hash,
+		InvitedByID: userID,
+		ExpiresAt:   time.Now().Add(invitationTTL),
+	}
+	if err := tc.DB.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      plain,
+		"invitation": invitation,
+	})
+}
+
+// DeleteInvitation withdraws a pending invitation
+func (tc *TeamController) DeleteInvitation(c *gin.Context) {
+	team, _, ok := tc.loadTeam(c, models.PermissionManageMembers)
+	if !ok {
+		return
+	}
+
+	result := tc.DB.Where("id = ? AND team_id = ? AND accepted_at IS NULL", c.Param("invitationId"), team.ID).
+		Delete(&models.TeamInvitation{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete invitation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation deleted successfully"})
+}
+
+// AcceptInvitation joins the team an invitation was issued for. The invitation
+// must have been sent to the current user's email address.
+func (tc *TeamController) AcceptInvitation(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var invitation models.TeamInvitation
+	if err := tc.DB.Where("token_hash = ?", models.HashToken(req.Token)).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if invitation.AcceptedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has expired or was already used"})
+		return
+	}
+
+	var user models.User
+	if err := tc.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This invitation was sent to a different email address"})
+		return
+	}
+
+	membership := models.TeamMembership{TeamID: invitation.TeamID, UserID: userID, Role: invitation.Role}
+	err := tc.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := models.GetTeamMembership(tx, invitation.TeamID, userID); err == nil {
+			return errAlreadyMember
+		}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&invitation).Update("accepted_at", now).Error
+	})
+	if err == errAlreadyMember {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this team"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
+// loadTeam fetches the team in the :id path parameter and checks that the
+// current user's role in it grants the permission. It writes the error
+// response itself and returns ok=false when the request should stop.
+func (tc *TeamController) loadTeam(c *gin.Context, permission string) (*models.Team, *models.TeamMembership, bool) {
+	userID, _ := middleware.GetUserID(c)
+
+	var team models.Team
+	if err := tc.DB.First(&team, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return nil, nil, false
+	}
+
+	membership, err := models.GetTeamMembership(tc.DB, team.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return nil, nil, false
+	}
+	if !models.RoleAllows(membership.Role, permission) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":               "Your team role does not allow this action",
+			"role":                membership.Role,
+			"required_permission": permission,
+		})
+		return nil, nil, false
+	}
+
+	return &team, membership, true
+}
+
+// isLastOwner reports whether a team has a single owner left
+func (tc *TeamController) isLastOwner(teamID uint) bool {
+	var owners int64
+	tc.DB.Model(&models.TeamMembership{}).Where("team_id = ? AND role = ?", teamID, models.RoleOwner).Count(&owners)
+	return owners <= 1
+}
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+	"runnerx/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// mfaTokenTTL is how long a password-verified login may wait for its second factor
+const mfaTokenTTL = 5 * time.Minute
+
+// mfaAudience keeps MFA challenge tokens from being accepted anywhere else
+const mfaAudience = "runnerx:mfa"
+
+// Failed second-factor attempts allowed before a login is locked for a while
+const (
+	maxSecondFactorFailures = 5
+	secondFactorLockout     = 15 * time.Minute
+)
+
+type mfaClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+type SecondFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type VerifyLoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	SecondFactorRequest
+}
+
+type EnableTwoFactorRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	SecondFactorRequest
+}
+
+func generateMFAToken(jwtSecret string, user *models.User) (string, error) {
+	claims := mfaClaims{
+		UserID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtSecret))
+}
+
+func parseMFAToken(jwtSecret, tokenString string) (uint, error) {
+	claims := &mfaClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(mfaAudience))
+	if err != nil || !token.Valid {
+		return 0, errors.New("invalid or expired MFA token")
+	}
+	return claims.UserID, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery
+// code. A TOTP time step is only accepted once per user.
+func verifySecondFactor(db *gorm.DB, user *models.User, req SecondFactorRequest) bool {
+	if req.RecoveryCode != "" {
+		return models.UseRecoveryCode(db, user.ID, req.RecoveryCode)
+	}
+	if req.Code == "" || user.TOTPSecret == "" {
+		return false
+	}
+	step, ok := services.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return false
+	}
+	result := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// secondFactorGuard locks out second-factor verification for a user after
+// repeated failures, so codes cannot be brute-forced with a known password.
+// Counters live in the rate limit store, so a shared store makes the lockout
+// hold across replicas.
+type secondFactorGuard struct {
+	store middleware.RateLimitStore
+}
+
+func newSecondFactorGuard(store middleware.RateLimitStore) *secondFactorGuard {
+	return &secondFactorGuard{store: store}
+}
+
+func secondFactorKey(userID uint) string {
+	return "2fa:user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// locked reports how long the user must wait before trying again
+func (g *secondFactorGuard) locked(userID uint) time.Duration {
+	count, resetAt, err := g.store.Get("lock:" + secondFactorKey(userID))
+	if err != nil || count == 0 {
+		return 0
+	}
+	return time.Until(resetAt)
+}
+
+func (g *secondFactorGuard) fail(userID uint) {
+	key := secondFactorKey(userID)
+	failures, _, err := g.store.Increment("fail:"+key, secondFactorLockout)
+	if err != nil || failures < maxSecondFactorFailures {
+		return
+	}
+	g.store.Reset("fail:" + key)
+	g.store.Reset("lock:" + key)
+	g.store.Increment("lock:"+key, secondFactorLockout)
+}
+
+func (g *secondFactorGuard) succeed(userID uint) {
+	g.store.Reset("fail:" + secondFactorKey(userID))
+}
+
+type TwoFactorController struct {
+	DB *gorm.DB
+}
+
+func NewTwoFactorController(db *gorm.DB) *TwoFactorController {
+	return &TwoFactorController{DB: db}
+}
+
+// GetStatus reports whether 2FA is enabled and how many recovery codes remain
+func (tc *TwoFactorController) GetStatus(c *gin.Context) {
+	user, ok := tc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TOTPEnabled,
+		"recovery_codes_remaining": models.RemainingRecoveryCodes(tc.DB, user.ID),
+	})
+}
+
+// Setup generates a new TOTP secret and returns its provisioning URI for a QR
+// code. The secret only takes effect once confirmed through Enable.
+func (tc *TwoFactorController) Setup(c *gin.Context) {
+	user, ok := tc.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := tc.DB.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": services.TOTPProvisioningURI(user.Email, secret),
+	})
+}
+
+// Enable confirms enrollment with a code from the authenticator app and
+// returns the recovery codes; they are not shown again
+func (tc *TwoFactorController) Enable(c *gin.Context) {
+	user, ok := tc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req EnableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment with POST /api/user/2fa/setup first"})
+		return
+	}
+	if !verifySecondFactor(tc.DB, user, SecondFactorRequest{Code: req.Code}) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, err := models.GenerateRecoveryCodes(tc.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := tc.DB.Model(user).Update("totp_enabled", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	recordAudit(tc.DB, c, models.AuditTwoFactorEnable, "user", user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns 2FA off; it requires the password and a second factor
+func (tc *TwoFactorController) Disable(c *gin.Context) {
+	user, ok := tc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !user.CheckPassword(req.Password) || !verifySecondFactor(tc.DB, user, req.SecondFactorRequest) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password or verification code"})
+		return
+	}
+
+	if err := resetTwoFactor(tc.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	recordAudit(tc.DB, c, models.AuditTwoFactorDisable, "user", user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; it requires a second factor
+func (tc *TwoFactorController) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := tc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req SecondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !verifySecondFactor(tc.DB, user, req) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, err := models.GenerateRecoveryCodes(tc.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUser lets an administrator turn off 2FA for a user who lost their
+// authenticator and recovery codes
+func (tc *TwoFactorController) ResetUser(c *gin.Context) {
+	var user models.User
+	if err := tc.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := resetTwoFactor(tc.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	recordAudit(tc.DB, c, models.AuditTwoFactorReset, "user", user.ID, gin.H{"email": user.Email})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully", "user_id": user.ID})
+}
+
+func (tc *TwoFactorController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := middleware.GetUserID(c)
+
+	var user models.User
+	if err := tc.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// resetTwoFactor disables 2FA and removes the secret and recovery codes
+func resetTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return models.DeleteRecoveryCodes(tx, userID)
+	})
+}
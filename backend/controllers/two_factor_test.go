@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"runnerx/models"
+	"runnerx/services"
+)
+
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	db := newTestDB(t)
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "A", Email: "a@example.com", Password: "password", TOTPEnabled: true, TOTPSecret: secret}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	step := time.Now().Unix() / 30
+	code, err := services.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifySecondFactor(db, &user, SecondFactorRequest{Code: code}) {
+		t.Fatal("current code was rejected")
+	}
+	if verifySecondFactor(db, &user, SecondFactorRequest{Code: code}) {
+		t.Fatal("code was accepted twice")
+	}
+
+	// An earlier step still inside the window is as good as used
+	previous, err := services.TOTPCode(secret, step-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifySecondFactor(db, &user, SecondFactorRequest{Code: previous}) {
+		t.Fatal("code of an earlier step was accepted after a later one")
+	}
+
+	next, err := services.TOTPCode(secret, step+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifySecondFactor(db, &user, SecondFactorRequest{Code: next}) {
+		t.Fatal("code of the next step was rejected")
+	}
+}
@@ -37,10 +37,12 @@ func (uc *UserController) GetCurrentUser(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":    user.ID,
-		"name":  user.Name,
-		"email": user.Email,
-		"role":  user.Role,
+		"id":             user.ID,
+		"name":           user.Name,
+		"email":          user.Email,
+		"role":           user.Role,
+		"totp_enabled":   user.TOTPEnabled,
+		"email_verified": user.EmailVerified(),
 	})
 }
 
@@ -115,6 +117,7 @@ func (uc *UserController) UpdateUserPreferences(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
 		return
 	}
+	recordAudit(uc.DB, c, models.AuditPreferencesUpdate, "preferences", preferences.ID, updates)
 
 	c.JSON(http.StatusOK, preferences)
 }
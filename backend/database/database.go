@@ -31,7 +31,6 @@ func Migrate(db *gorm.DB) error {
 		&models.Notification{},
 		&models.UserPreferences{},
 		&models.MonitorForecast{},
-			// StatusPage removed
 			// AutomationRule removed
 		&models.LogInsight{},
 		&models.IncidentScreenshot{},
@@ -41,12 +40,44 @@ func Migrate(db *gorm.DB) error {
         &models.IncidentAISummary{},
         &models.SLAReport{},
         &models.CommandLog{},
+        &models.APIToken{},
+        &models.Session{},
+        &models.RecoveryCode{},
+        &models.OIDCLogin{},
+        &models.UserToken{},
+        &models.AuditLog{},
+        &models.RateLimitCounter{},
+        &models.Team{},
+        &models.TeamMembership{},
+        &models.TeamInvitation{},
+        &models.MonitorGroup{},
+        &models.MonitorGroupMember{},
+        &models.MonitorGroupCheck{},
+        &models.StatusPage{},
+        &models.StatusPageItem{},
+        &models.StatusPageIncident{},
+        &models.StatusPageIncidentUpdate{},
+        &models.StatusPageMaintenance{},
+        &models.StatusPageSubscriber{},
+        &models.UptimeRollup{},
+        &models.BadgeToken{},
+        &models.Agent{},
+        &models.MonitorLocationStatus{},
+        &models.ClusterMember{},
+        &models.ClusterLease{},
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if err := models.ProtectAuditLog(db); err != nil {
+		return err
+	}
+	if err := models.RequireDomainVerification(db); err != nil {
+		return err
+	}
+
 	log.Println("Migrations completed successfully")
 	return nil
 }
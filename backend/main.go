@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 	"runnerx/config"
 	"runnerx/database"
 	"runnerx/middleware"
+	"runnerx/models"
 	"runnerx/routes"
+	"runnerx/server"
 	"runnerx/services"
+	"runnerx/telemetry"
 	ws "runnerx/websocket"
 
 	"github.com/gin-contrib/cors"
@@ -16,101 +29,334 @@ import (
 )
 
 func main() {
-	// Load configuration
-	cfg := config.Load()
+	// Load configuration: defaults, then the config file, then the environment
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if !models.IsValidLocation(cfg.ProbeLocation) {
+		log.Fatalf("Invalid PROBE_LOCATION %q", cfg.ProbeLocation)
+	}
+	if cfg.DevMode {
+		log.Println("Running in dev mode")
+	}
+
+	// Cancelled on SIGINT or SIGTERM; everything started below stops with it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Export traces of requests, checks and queries to the OTLP collector
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.Tracing, cfg.Cluster.InstanceID)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
 	// Initialize database
 	db := database.Init(cfg.DatabaseURL)
+	if cfg.Tracing.Enabled() {
+		if err := db.Use(telemetry.GormPlugin()); err != nil {
+			log.Fatalf("Failed to trace database queries: %v", err)
+		}
+	}
 
 	// Run migrations
 	if err := database.Migrate(db); err != nil {
 		log.Fatalf("Migration failed: %v", err)
 	}
 
-	// Initialize WebSocket hub
-	hub := ws.NewHub()
-	go hub.Run()
+	// Outgoing email (verification and password reset links)
+	mailer := services.NewMailer(cfg.Mail)
+
+	// Initialize WebSocket hub; the broker shares live events between replicas
+	broker, err := ws.NewBroker(ws.BrokerConfig{
+		Kind:     cfg.PubSub.Broker,
+		RedisURL: cfg.PubSub.RedisURL,
+		Channel:  cfg.PubSub.Channel,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to pub/sub broker: %v", err)
+	}
+	defer broker.Close()
+	hub, err := ws.NewHub(broker)
+	if err != nil {
+		log.Fatalf("Failed to subscribe to pub/sub broker: %v", err)
+	}
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(hubDone)
+	}()
+
+	// Join the instances sharing the database: monitors are sharded between
+	// them and singleton jobs run on the leader only
+	cluster := services.NewCluster(db, cfg.Cluster)
+	cluster.Start()
 
 	// Initialize monitor service with WebSocket hub
-	monitorService := services.NewMonitorService(db, hub)
+	monitorGroupService := services.NewMonitorGroupService(db, hub)
+	screenshotService := services.NewScreenshotService(db, hub, cfg.Monitoring.ScreenshotTimeout)
+	monitorService := services.NewMonitorService(db, hub, monitorGroupService, screenshotService, cfg.Monitoring, cfg.ProbeLocation, cluster)
+	if cluster.IsLeader() {
+		services.StartRollupBackfill(db)
+	}
 	logInsightsService := services.NewLogInsightsService(db, hub)
-	go monitorService.Start()
+	go monitorService.Start(ctx)
 
 	// Start analytics and system mood services
-	analyticsService := services.NewAnalyticsService(db, hub)
+	analyticsService := services.NewAnalyticsService(db, hub, cfg.Analytics.Interval)
 	// Seed hourly snapshots once at startup for immediate UI
-	go func() { analyticsService.AggregateLastHour(); }()
-	go analyticsService.StartHourly()
+	if cluster.IsLeader() {
+		go func() { analyticsService.AggregateLastHour(); }()
+	}
+	go analyticsService.Start(ctx, cluster)
+
+	systemMoodService := services.NewSystemMoodService(db, hub, cfg.Analytics.MoodInterval)
+	go systemMoodService.Start(ctx, cluster)
 
-	systemMoodService := services.NewSystemMoodService(db, hub)
-	go systemMoodService.Start()
+	// Initialize command service; it also fails the commands a previous
+	// process left running
+	commandService := services.NewCommandService(db, hub, cfg.Commands.Timeout)
 
 	// Start SLA scheduler
 	slaService := services.NewSLAService(db)
 	scheduler := gocron.NewScheduler(time.UTC)
 	scheduler.Every(1).Day().At("00:05").Do(func() {
+		if !cluster.IsLeader() {
+			return
+		}
 		if err := slaService.GenerateDailySLAReports(); err != nil {
 			log.Printf("Failed to generate SLA reports: %v", err)
 		}
 	})
+	scheduler.Every(1).Day().At("00:15").Do(func() {
+		if !cluster.IsLeader() {
+			return
+		}
+		if err := models.PurgeExpiredSessions(db); err != nil {
+			log.Printf("Failed to purge expired sessions: %v", err)
+		}
+		if err := models.PurgeExpiredUserTokens(db); err != nil {
+			log.Printf("Failed to purge expired user tokens: %v", err)
+		}
+		if err := models.PurgeOldRollups(db); err != nil {
+			log.Printf("Failed to purge uptime rollups: %v", err)
+		}
+		commandService.FailInterrupted()
+	})
 	scheduler.StartAsync()
 
-	// Initialize command service
-	commandService := services.NewCommandService(db, hub)
+	// HTTPS, with certificates from files or ACME
+	var tlsSetup *server.TLS
+	if cfg.TLS.Enabled() {
+		// ACME also covers the verified custom domains of status pages
+		statusPageDomain := func(ctx context.Context, host string) bool {
+			return models.IsStatusPageDomain(db.WithContext(ctx), host)
+		}
+		if tlsSetup, err = server.NewTLS(cfg.TLS, cfg.Port, statusPageDomain); err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+	}
 
 	// Setup Gin router
 	r := gin.Default()
+	if cfg.Tracing.Enabled() {
+		r.Use(middleware.Tracing())
+	}
+	if tlsSetup != nil && cfg.TLS.HSTSMaxAge > 0 {
+		r.Use(middleware.HSTS(cfg.TLS.HSTSMaxAge, cfg.TLS.HSTSIncludeSubdomains))
+	}
 
-	// CORS middleware
+	// CORS middleware; the origins can be reloaded
+	var corsOrigins atomic.Pointer[[]string]
+	corsOrigins.Store(&cfg.CORS.Origins)
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001"},
+		AllowOriginFunc: func(origin string) bool {
+			return slices.Contains(*corsOrigins.Load(), origin)
+		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middleware.TeamHeader},
+		ExposeHeaders:    []string{"Content-Length", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 	}))
 
-	// Rate limiting middleware
-	r.Use(middleware.RateLimiter())
+	// Rate limiting: a global per-IP budget here, stricter tiers on the
+	// auth, authenticated and expensive routes
+	limiter := middleware.NewRateLimiter(middleware.NewRateLimitStore(cfg.RateLimit.Store, db), cfg.RateLimit, db)
+	r.Use(limiter.Global())
 
 	// WebSocket endpoint
-	r.GET("/ws", ws.HandleWebSocket(hub, cfg.JWTSecret))
+	r.GET("/ws", ws.HandleWebSocket(hub, db, cfg.JWTSecret))
 
 	// API routes
 	api := r.Group("/api")
 	{
 		// Auth routes (public)
 		auth := api.Group("/auth")
-		routes.AuthRoutes(auth, db)
+		routes.AuthRoutes(auth, db, cfg, hub, mailer, limiter)
 
-		// Protected routes
+		// Protected routes. Monitor and incident routes also accept personal
+		// access tokens, checked per route against the token's scopes.
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-		routes.MonitorRoutes(protected, db)
-		routes.NotificationRoutes(protected, db)
-		routes.UserRoutes(protected, db)
-		// Status page and automation removed per spec
-		routes.LogsRoutes(protected, db, logInsightsService)
-		routes.ScreenshotsRoutes(protected, db)
-		routes.SnapshotsRoutes(protected, db)
+		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret, db), middleware.TeamContext(db), limiter.Authenticated())
+		routes.MonitorRoutes(protected, db, monitorService, limiter)
+		routes.MonitorGroupRoutes(protected, db, monitorGroupService)
+		routes.StatusPageRoutes(protected, db, cfg, mailer)
+		routes.BadgeRoutes(protected, db)
 		routes.IncidentsRoutes(protected, db)
-		routes.SLARoutes(protected, db)
-		routes.CommandRoutes(protected, db, commandService)
+		routes.EventRoutes(protected, db, hub)
+		routes.MoodRoutes(protected, db)
+
+		// Everything else requires an interactive login
+		session := protected.Group("")
+		session.Use(middleware.RequireSession())
+		routes.NotificationRoutes(session, db)
+		routes.UserRoutes(session, db, cfg, hub, mailer, limiter)
+		routes.SessionRoutes(session, db, hub)
+		routes.APITokenRoutes(session, db)
+		routes.TeamRoutes(session, db)
+		routes.AdminRoutes(session, db, cluster)
+		routes.AuditRoutes(session, db)
+		// Automation removed per spec
+		routes.LogsRoutes(session, db, logInsightsService)
+		routes.ScreenshotsRoutes(session, db, screenshotService, limiter)
+		routes.SnapshotsRoutes(session, db)
+		routes.SLARoutes(session, db)
+		routes.CommandRoutes(session, db, commandService, limiter)
 	}
 
 	// Public routes (no auth)
-	public := r.Group("")
-	routes.PublicRoutes(public, db)
+	public := r.Group("/api")
+	routes.PublicRoutes(public, db, cfg, mailer, limiter)
+	routes.AgentRoutes(public, db, monitorService, limiter)
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
-	// Start server
-	log.Printf("Server starting on :%s", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
+	// Start server, and with TLS the plain HTTP listener redirecting to it
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	servers := []*http.Server{srv}
+	serverErr := make(chan error, 2)
+	if tlsSetup != nil {
+		srv.TLSConfig = tlsSetup.Config
+		go func() {
+			log.Printf("Server starting on :%s (HTTPS)", cfg.Port)
+			serverErr <- srv.ListenAndServeTLS("", "")
+		}()
+		go tlsSetup.Watch(ctx)
+
+		if cfg.TLS.HTTPPort != "" {
+			redirect := &http.Server{Addr: ":" + cfg.TLS.HTTPPort, Handler: tlsSetup.HTTPHandler, ReadHeaderTimeout: 10 * time.Second}
+			servers = append(servers, redirect)
+			go func() {
+				log.Printf("Redirecting HTTP on :%s to HTTPS", cfg.TLS.HTTPPort)
+				serverErr <- redirect.ListenAndServe()
+			}()
+		}
+	} else {
+		go func() {
+			log.Printf("Server starting on :%s", cfg.Port)
+			serverErr <- srv.ListenAndServe()
+		}()
+	}
+
+	// SIGHUP reloads the settings that can change while the server runs
+	go reloadOnSIGHUP(ctx, *configPath, cfg, func(next *config.Config) {
+		corsOrigins.Store(&next.CORS.Origins)
+		limiter.Reload(next.RateLimit)
+		monitorService.Reload(next.Monitoring)
+		screenshotService.SetTimeout(next.Monitoring.ScreenshotTimeout)
+		commandService.SetTimeout(next.Commands.Timeout)
+		analyticsService.SetInterval(next.Analytics.Interval)
+		systemMoodService.SetInterval(next.Analytics.MoodInterval)
+		if err := tlsSetup.Reload(); err != nil {
+			log.Printf("TLS: %v", err)
+		}
+	})
+
+	select {
+	case err := <-serverErr:
+		cluster.Leave()
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	// A second signal stops the process at once
+	stop()
+
+	log.Printf("Shutting down, waiting up to %s for work in flight", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Hand the monitors and the leader lease to the other instances now
+	// rather than once the heartbeat expires
+	cluster.Leave()
+	scheduler.Stop()
+
+	// The hub closed the live connections when ctx was cancelled, which also
+	// ends the SSE requests the server waits for below
+	select {
+	case <-hubDone:
+	case <-shutdownCtx.Done():
+	}
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server shutdown: %v", err)
+		}
 	}
+
+	// Let the checks and commands in flight record their results
+	var drained sync.WaitGroup
+	drained.Add(2)
+	go func() {
+		defer drained.Done()
+		commandService.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		defer drained.Done()
+		if err := monitorService.Drain(shutdownCtx); err != nil {
+			log.Printf("Checks still running at shutdown: %v", err)
+		}
+	}()
+	drained.Wait()
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	log.Println("Server stopped")
 }
 
+// reloadOnSIGHUP loads the configuration again on every SIGHUP and hands the
+// reloadable settings to apply. A configuration that fails to load or
+// validate is ignored; changes that need a restart are logged.
+func reloadOnSIGHUP(ctx context.Context, path string, cfg *config.Config, apply func(*config.Config)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		next, err := config.Load(path)
+		if err != nil {
+			log.Printf("Config reload failed, keeping the current configuration: %v", err)
+			continue
+		}
+		var restart []string
+		cfg, restart = cfg.Reload(next)
+		apply(cfg)
+		for _, setting := range restart {
+			log.Printf("Config reload: %s changed, restart to apply", setting)
+		}
+		log.Println("Configuration reloaded")
+	}
+}
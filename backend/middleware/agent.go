@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"runnerx/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AgentAuth authenticates probe agents by their agent token. Agents have no
+// user, so these routes sit outside AuthMiddleware.
+func AgentAuth(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(tokenString, models.AgentTokenPrefix) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Agent token required"})
+			c.Abort()
+			return
+		}
+
+		var agent models.Agent
+		if err := db.Where("token_hash = ?", models.HashToken(tokenString)).First(&agent).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked agent token"})
+			c.Abort()
+			return
+		}
+
+		now := time.Now()
+		if agent.LastSeenAt == nil || now.Sub(*agent.LastSeenAt) > lastUsedResolution || agent.LastSeenIP != c.ClientIP() {
+			db.Model(&agent).UpdateColumns(map[string]interface{}{
+				"last_seen_at": now,
+				"last_seen_ip": c.ClientIP(),
+			})
+			agent.LastSeenAt, agent.LastSeenIP, agent.Online = &now, c.ClientIP(), true
+		}
+
+		c.Set("agent", &agent)
+		c.Next()
+	}
+}
+
+// GetAgent returns the agent authenticated by AgentAuth
+func GetAgent(c *gin.Context) (*models.Agent, bool) {
+	agent, ok := c.Get("agent")
+	if !ok {
+		return nil, false
+	}
+	a, ok := agent.(*models.Agent)
+	return a, ok
+}
@@ -1,20 +1,65 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
+
+	"runnerx/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Authentication methods stored in the request context under "auth_type"
+const (
+	AuthTypeSession = "session"
+	AuthTypeToken   = "token"
 )
 
+// lastUsedResolution limits how often token usage is written back to the database
+const lastUsedResolution = time.Minute
+
+// Claims are carried by access tokens; SessionID ties the token to a
+// revocable login session
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID uint   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// ErrSessionRevoked is returned for access tokens whose session has ended
+var ErrSessionRevoked = errors.New("session revoked or expired")
+
+// ParseAccessToken validates an access token and checks that its login
+// session is still active
+func ParseAccessToken(db *gorm.DB, jwtSecret, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.SessionID == 0 {
+		return nil, ErrSessionRevoked
+	}
+
+	var session models.Session
+	if err := db.Select("id", "user_id", "expires_at", "revoked_at").First(&session, claims.SessionID).Error; err != nil {
+		return nil, ErrSessionRevoked
+	}
+	if session.UserID != claims.UserID || !session.IsActive() {
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// AuthMiddleware accepts either a JWT issued at login or a personal access token
+func AuthMiddleware(jwtSecret string, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -33,31 +78,118 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+		if models.IsAPIToken(tokenString) {
+			authenticateAPIToken(c, db, tokenString)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Parse and validate token
+		claims, err := ParseAccessToken(db, jwtSecret, tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Extract claims
-		if claims, ok := token.Claims.(*Claims); ok {
-			c.Set("user_id", claims.UserID)
-			c.Set("email", claims.Email)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		c.Set("auth_type", AuthTypeSession)
+		c.Next()
+	}
+}
+
+func authenticateAPIToken(c *gin.Context, db *gorm.DB, tokenString string) {
+	var apiToken models.APIToken
+	if err := db.Where("token_hash = ?", models.HashToken(tokenString)).First(&apiToken).Error; err != nil || !apiToken.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or revoked API token"})
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > lastUsedResolution || apiToken.LastUsedIP != c.ClientIP() {
+		db.Model(&apiToken).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.ClientIP(),
+		})
+	}
+
+	c.Set("user_id", apiToken.UserID)
+	c.Set("auth_type", AuthTypeToken)
+	c.Set("token_id", apiToken.ID)
+	c.Set("scopes", []string(apiToken.Scopes))
+	c.Next()
+}
+
+// RequireScope lets personal access tokens through only if they carry one of the
+// given scopes. Login sessions have full access and are always allowed.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if HasScope(c, scopes...) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":           "API token lacks the required scope",
+			"required_scopes": scopes,
+		})
+		c.Abort()
+	}
+}
+
+// HasScope reports whether the request may act with one of the given scopes.
+// Login sessions have every scope.
+func HasScope(c *gin.Context, scopes ...string) bool {
+	if c.GetString("auth_type") != AuthTypeToken {
+		return true
+	}
+	for _, want := range scopes {
+		for _, have := range c.GetStringSlice("scopes") {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireSession rejects personal access tokens on routes that are only meant
+// for interactive logins, such as account settings and token management
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") == AuthTypeToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an API token"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
+// RequireAdmin only lets instance administrators through
+func RequireAdmin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		var user models.User
+		if err := db.Select("id", "role").First(&user, userID).Error; err != nil || !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Administrator access required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// GetSessionID returns the login session of the request; it is not set for API tokens
+func GetSessionID(c *gin.Context) (uint, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return 0, false
+	}
+	return sessionID.(uint), true
+}
+
 // GetUserID retrieves user ID from context
 func GetUserID(c *gin.Context) (uint, bool) {
 	userID, exists := c.Get("user_id")
@@ -66,4 +198,3 @@ func GetUserID(c *gin.Context) (uint, bool) {
 	}
 	return userID.(uint), true
 }
-
@@ -1,81 +1,308 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"runnerx/config"
+	"runnerx/models"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-type visitor struct {
-	lastSeen time.Time
-	count    int
+// Failed logins are counted over this window before an account or IP is locked
+const loginFailureWindow = 15 * time.Minute
+
+// A single IP may fail this many times more than one account before it is
+// locked itself, which catches password spraying across accounts
+const ipFailureMultiplier = 4
+
+// RateLimiter enforces the request budgets configured for each tier. Counters
+// live in a RateLimitStore so they can be shared across replicas.
+type RateLimiter struct {
+	store RateLimitStore
+	db    *gorm.DB
+
+	mu  sync.RWMutex
+	cfg config.RateLimitConfig
 }
 
-var (
-	visitors = make(map[string]*visitor)
-	mu       sync.RWMutex
-)
+func NewRateLimiter(store RateLimitStore, cfg config.RateLimitConfig, db *gorm.DB) *RateLimiter {
+	return &RateLimiter{store: store, cfg: cfg, db: db}
+}
 
-const (
-	rateLimit  = 1000        // requests
-	timeWindow = time.Minute // per minute
-)
+// Reload applies new budgets and lockouts to the requests that follow. The
+// store cannot change.
+func (rl *RateLimiter) Reload(cfg config.RateLimitConfig) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.cfg = cfg
+}
+
+// Store returns the store holding the limiter's counters, for other lockouts
+// that must hold across replicas
+func (rl *RateLimiter) Store() RateLimitStore {
+	return rl.store
+}
+
+func (rl *RateLimiter) settings() config.RateLimitConfig {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.cfg
+}
 
-func RateLimiter() gin.HandlerFunc {
-	// Cleanup old entries periodically
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-			mu.Lock()
-			for ip, v := range visitors {
-				if time.Since(v.lastSeen) > timeWindow {
-					delete(visitors, ip)
-				}
+// NewRateLimitStore returns the store selected by RATE_LIMIT_STORE
+func NewRateLimitStore(kind string, db *gorm.DB) RateLimitStore {
+	switch kind {
+	case "database":
+		return NewDBRateLimitStore(db)
+	case "memory", "":
+		return NewMemoryRateLimitStore()
+	default:
+		log.Printf("Unknown RATE_LIMIT_STORE %q, using memory", kind)
+		return NewMemoryRateLimitStore()
+	}
+}
+
+// Global limits every request by client IP
+func (rl *RateLimiter) Global() gin.HandlerFunc {
+	return rl.perIP("global", func(cfg config.RateLimitConfig) config.Rate { return cfg.IP })
+}
+
+// Auth limits unauthenticated account endpoints by client IP
+func (rl *RateLimiter) Auth() gin.HandlerFunc {
+	return rl.perIP("auth", func(cfg config.RateLimitConfig) config.Rate { return cfg.Auth })
+}
+
+// Register limits account creation by client IP
+func (rl *RateLimiter) Register() gin.HandlerFunc {
+	return rl.perIP("register", func(cfg config.RateLimitConfig) config.Rate { return cfg.Register })
+}
+
+// Authenticated limits requests per user, or per token for personal access
+// tokens so a busy integration cannot use up its owner's budget. It must run
+// after AuthMiddleware.
+func (rl *RateLimiter) Authenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenID, ok := c.Get("token_id"); ok {
+			if !rl.allow(c, fmt.Sprintf("token:%v", tokenID), rl.settings().Token) {
+				return
+			}
+		} else if userID, ok := GetUserID(c); ok {
+			if !rl.allow(c, fmt.Sprintf("user:%d", userID), rl.settings().User) {
+				return
 			}
-			mu.Unlock()
 		}
-	}()
+		c.Next()
+	}
+}
 
+// Expensive gives endpoints that run diagnostics or outbound checks a small
+// budget per user, shared by all of them
+func (rl *RateLimiter) Expensive() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-
-		mu.Lock()
-		v, exists := visitors[ip]
-		if !exists {
-			visitors[ip] = &visitor{
-				lastSeen: time.Now(),
-				count:    1,
+		userID, _ := GetUserID(c)
+		if !rl.allow(c, fmt.Sprintf("expensive:%d", userID), rl.settings().Expensive) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Agent limits probe agents per agent, on the token budget. It must run after
+// AgentAuth.
+func (rl *RateLimiter) Agent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if agent, ok := GetAgent(c); ok {
+			if !rl.allow(c, fmt.Sprintf("agent:%d", agent.ID), rl.settings().Token) {
+				return
 			}
-			mu.Unlock()
-			c.Next()
+		}
+		c.Next()
+	}
+}
+
+func (rl *RateLimiter) perIP(name string, rate func(config.RateLimitConfig) config.Rate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rl.allow(c, name+":"+c.ClientIP(), rate(rl.settings())) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Reset count if time window has passed
-		if time.Since(v.lastSeen) > timeWindow {
-			v.lastSeen = time.Now()
-			v.count = 1
-			mu.Unlock()
-			c.Next()
+// allow counts the request against key and reports whether it is within the
+// rate; otherwise it responds with 429. Store failures let requests through.
+func (rl *RateLimiter) allow(c *gin.Context, key string, rate config.Rate) bool {
+	count, resetAt, err := rl.store.Increment(key, rate.Window)
+	if err != nil {
+		log.Printf("Rate limit store error: %v", err)
+		return true
+	}
+
+	setRateLimitHeaders(c, rate, rate.Requests-count, resetAt)
+	if count > rate.Requests {
+		rejectUntil(c, resetAt, "Rate limit exceeded. Please try again later.")
+		return false
+	}
+	return true
+}
+
+// setRateLimitHeaders writes the RateLimit-* headers. When several limits
+// apply to a request, the one closest to running out is reported.
+func setRateLimitHeaders(c *gin.Context, rate config.Rate, remaining int, resetAt time.Time) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	if current := c.Writer.Header().Get("RateLimit-Remaining"); current != "" {
+		if n, err := strconv.Atoi(current); err == nil && n <= remaining {
 			return
 		}
+	}
+	c.Header("RateLimit-Limit", strconv.Itoa(rate.Requests))
+	c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(secondsUntil(resetAt)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rate.Requests, int(rate.Window.Seconds())))
+}
+
+func rejectUntil(c *gin.Context, resetAt time.Time, message string) {
+	retryAfter := secondsUntil(resetAt)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"retry_after": retryAfter,
+	})
+}
+
+func secondsUntil(t time.Time) int {
+	seconds := int(math.Ceil(time.Until(t).Seconds()))
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+// LoginGuard locks out an account, or a client IP, after repeated failed
+// logins. Each new lockout within a day doubles in length up to the
+// configured maximum. A successful login clears the account's failures.
+func (rl *RateLimiter) LoginGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rl.guardPassword(c, peekLoginEmail(c), "Too many failed login attempts, try again later")
+	}
+}
 
-		// Check rate limit
-		if v.count >= rateLimit {
-			mu.Unlock()
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
+// PasswordGuard puts the password checks of signed-in users, such as changing
+// the password or deleting the account, under the login lockout: their
+// failures count toward it, and a locked account cannot guess here either
+func (rl *RateLimiter) PasswordGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := strings.ToLower(strings.TrimSpace(c.GetString("email")))
+		rl.guardPassword(c, email, "Too many failed password attempts, try again later")
+	}
+}
+
+// guardPassword rejects the request while the account or client IP is locked,
+// and counts an unauthorized response as a failed attempt
+func (rl *RateLimiter) guardPassword(c *gin.Context, email, message string) {
+	ipKey := "login:ip:" + c.ClientIP()
+	accountKey := ""
+	if email != "" {
+		accountKey = "login:account:" + email
+	}
+
+	for _, key := range []string{accountKey, ipKey} {
+		if key == "" {
+			continue
+		}
+		if count, resetAt, err := rl.store.Get("lock:" + key); err == nil && count > 0 {
+			rejectUntil(c, resetAt, message)
 			return
 		}
+	}
+
+	c.Next()
+
+	switch c.Writer.Status() {
+	case http.StatusUnauthorized:
+		maxFailures := rl.settings().LoginMaxFailures
+		if accountKey != "" {
+			if lockout := rl.loginFailed(accountKey, maxFailures); lockout > 0 {
+				rl.auditLockout(c, email, "account", lockout)
+			}
+		}
+		if lockout := rl.loginFailed(ipKey, maxFailures*ipFailureMultiplier); lockout > 0 {
+			rl.auditLockout(c, email, "ip", lockout)
+		}
+	case http.StatusOK:
+		if accountKey != "" {
+			rl.store.Reset("fail:" + accountKey)
+			rl.store.Reset("strikes:" + accountKey)
+		}
+	}
+}
 
-		v.count++
-		v.lastSeen = time.Now()
-		mu.Unlock()
+// loginFailed counts a failure and returns the lockout it triggered, if any
+func (rl *RateLimiter) loginFailed(key string, maxFailures int) time.Duration {
+	failures, _, err := rl.store.Increment("fail:"+key, loginFailureWindow)
+	if err != nil || maxFailures < 1 || failures < maxFailures {
+		return 0
+	}
 
-		c.Next()
+	strikes, _, err := rl.store.Increment("strikes:"+key, 24*time.Hour)
+	if err != nil {
+		return 0
+	}
+	cfg := rl.settings()
+	lockout := cfg.LoginLockout
+	for i := 1; i < strikes && lockout < cfg.LoginMaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > cfg.LoginMaxLockout {
+		lockout = cfg.LoginMaxLockout
+	}
+
+	rl.store.Reset("fail:" + key)
+	rl.store.Reset("lock:" + key)
+	rl.store.Increment("lock:"+key, lockout)
+	return lockout
+}
+
+func (rl *RateLimiter) auditLockout(c *gin.Context, email, scope string, lockout time.Duration) {
+	actor := models.AuditActor{Email: email, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	models.RecordAudit(rl.db, actor, models.AuditLoginLocked, "login", nil, false, gin.H{
+		"scope":           scope,
+		"lockout_seconds": int(lockout.Seconds()),
+	})
+}
+
+// peekLoginEmail reads the email from a JSON login body and puts the body
+// back for the handler
+func peekLoginEmail(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
 	}
+	return strings.ToLower(strings.TrimSpace(req.Email))
 }
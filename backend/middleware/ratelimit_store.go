@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"runnerx/models"
+
+	"gorm.io/gorm"
+)
+
+// RateLimitStore keeps fixed-window counters. Implementations must be safe
+// for concurrent use; a shared store makes limits hold across replicas.
+type RateLimitStore interface {
+	// Increment counts a hit against key and returns the count in the current
+	// window and when that window ends. A new window starts once it has ended.
+	Increment(key string, window time.Duration) (int, time.Time, error)
+	// Get returns the count in the current window without counting a hit
+	Get(key string) (int, time.Time, error)
+	// Reset forgets key
+	Reset(key string) error
+}
+
+type memoryBucket struct {
+	count   int
+	resetAt time.Time
+}
+
+// MemoryRateLimitStore keeps counters in process memory
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+	go s.cleanup()
+	return s
+}
+
+func (s *MemoryRateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok || !now.Before(b.resetAt) {
+		b = &memoryBucket{resetAt: now.Add(window)}
+		s.buckets[key] = b
+	}
+	b.count++
+	return b.count, b.resetAt, nil
+}
+
+func (s *MemoryRateLimitStore) Get(key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok || !time.Now().Before(b.resetAt) {
+		return 0, time.Time{}, nil
+	}
+	return b.count, b.resetAt, nil
+}
+
+func (s *MemoryRateLimitStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets, key)
+	return nil
+}
+
+// cleanup drops expired windows periodically
+func (s *MemoryRateLimitStore) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		s.mu.Lock()
+		for key, b := range s.buckets {
+			if !now.Before(b.resetAt) {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// DBRateLimitStore keeps counters in the database so every replica sharing it
+// enforces the same limits
+type DBRateLimitStore struct {
+	db *gorm.DB
+}
+
+func NewDBRateLimitStore(db *gorm.DB) *DBRateLimitStore {
+	s := &DBRateLimitStore{db: db}
+	go s.cleanup()
+	return s
+}
+
+func (s *DBRateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	now := time.Now().UnixMilli()
+	var row models.RateLimitCounter
+	// A single upsert keeps concurrent increments from different replicas exact
+	err := s.db.Raw(`INSERT INTO rate_limit_counters (bucket, count, reset_at) VALUES (?, 1, ?)
+		ON CONFLICT (bucket) DO UPDATE SET
+			count = CASE WHEN rate_limit_counters.reset_at <= ? THEN 1 ELSE rate_limit_counters.count + 1 END,
+			reset_at = CASE WHEN rate_limit_counters.reset_at <= ? THEN excluded.reset_at ELSE rate_limit_counters.reset_at END
+		RETURNING bucket, count, reset_at`,
+		key, now+window.Milliseconds(), now, now).Scan(&row).Error
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return row.Count, time.UnixMilli(row.ResetAt), nil
+}
+
+func (s *DBRateLimitStore) Get(key string) (int, time.Time, error) {
+	var row models.RateLimitCounter
+	err := s.db.Where("bucket = ? AND reset_at > ?", key, time.Now().UnixMilli()).Limit(1).Find(&row).Error
+	if err != nil || row.Bucket == "" {
+		return 0, time.Time{}, err
+	}
+	return row.Count, time.UnixMilli(row.ResetAt), nil
+}
+
+func (s *DBRateLimitStore) Reset(key string) error {
+	return s.db.Where("bucket = ?", key).Delete(&models.RateLimitCounter{}).Error
+}
+
+func (s *DBRateLimitStore) cleanup() {
+	for range time.Tick(5 * time.Minute) {
+		s.db.Where("reset_at <= ?", time.Now().UnixMilli()).Delete(&models.RateLimitCounter{})
+	}
+}
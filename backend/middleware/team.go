@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"runnerx/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TeamHeader selects the team a request acts on; without it requests act on
+// the caller's personal workspace
+const TeamHeader = "X-Team-ID"
+
+// TeamContext resolves the team selected by the X-Team-ID header (or team_id
+// query parameter) and verifies that the authenticated user is a member
+func TeamContext(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(TeamHeader)
+		if raw == "" {
+			raw = c.Query("team_id")
+		}
+		if raw == "" {
+			c.Next()
+			return
+		}
+
+		teamID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			c.Abort()
+			return
+		}
+
+		userID, _ := GetUserID(c)
+		membership, err := models.GetTeamMembership(db, uint(teamID), userID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this team"})
+			c.Abort()
+			return
+		}
+
+		c.Set("team_id", membership.TeamID)
+		c.Set("team_role", membership.Role)
+		c.Next()
+	}
+}
+
+// RequirePermission rejects team requests whose member role lacks the permission.
+// In the personal workspace the user owns everything and is always allowed.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := GetTeamID(c); !ok {
+			c.Next()
+			return
+		}
+		role := c.GetString("team_role")
+		if !models.RoleAllows(role, permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":               "Your team role does not allow this action",
+				"role":                role,
+				"required_permission": permission,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetTeamID returns the team selected for the request, if any
+func GetTeamID(c *gin.Context) (uint, bool) {
+	teamID, exists := c.Get("team_id")
+	if !exists {
+		return 0, false
+	}
+	return teamID.(uint), true
+}
+
+// GetTeamIDPtr returns the selected team as a nullable column value
+func GetTeamIDPtr(c *gin.Context) *uint {
+	if teamID, ok := GetTeamID(c); ok {
+		return &teamID
+	}
+	return nil
+}
+
+// OwnedBy scopes a query on a team-ownable table (monitors, incidents, SLA
+// reports) to the workspace selected for the request
+func OwnedBy(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	userID, _ := GetUserID(c)
+	teamID, inTeam := GetTeamID(c)
+	return func(db *gorm.DB) *gorm.DB {
+		if inTeam {
+			return db.Where("team_id = ?", teamID)
+		}
+		return db.Where("user_id = ? AND team_id IS NULL", userID)
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AgentTokenPrefix marks the tokens agents authenticate with
+const AgentTokenPrefix = "rnxa_"
+
+// AgentOnlineWindow is how recently an agent must have called in to count as online
+const AgentOnlineWindow = 2 * time.Minute
+
+var locationPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// IsValidLocation reports whether name can be used as a probe location
+func IsValidLocation(name string) bool {
+	return locationPattern.MatchString(name)
+}
+
+// Agent is a remote worker that runs the checks of the monitors assigned to
+// its location and reports the results. Several agents may share a location.
+type Agent struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Name       string     `gorm:"not null" json:"name"`
+	Location   string     `gorm:"not null;index" json:"location"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // first characters, for display
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Version    string     `json:"version,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	LastSeenIP string     `json:"last_seen_ip,omitempty"`
+	Online     bool       `gorm:"-" json:"online"`
+}
+
+// AfterFind derives whether the agent is online
+func (a *Agent) AfterFind(*gorm.DB) error {
+	a.Online = a.LastSeenAt != nil && time.Since(*a.LastSeenAt) < AgentOnlineWindow
+	return nil
+}
+
+// MonitorLocationStatus is the latest result of a monitor at one location
+type MonitorLocationStatus struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	MonitorID uint      `gorm:"not null;uniqueIndex:idx_monitor_location" json:"monitor_id"`
+	Location  string    `gorm:"not null;uniqueIndex:idx_monitor_location" json:"location"`
+	Status    string    `gorm:"not null" json:"status"`
+	LatencyMs int64     `json:"latency_ms"`
+	ErrorMsg  string    `json:"error_msg,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix marks personal access tokens so they can be told apart from JWTs
+const APITokenPrefix = "rnx_"
+
+// Scopes that can be granted to a personal access token
+const (
+	ScopeMonitorsRead    = "monitors:read"
+	ScopeMonitorsWrite   = "monitors:write"
+	ScopeIncidentsRead   = "incidents:read"
+	ScopeIncidentsManage = "incidents:manage"
+	ScopeHeartbeatsPush  = "heartbeats:push"
+)
+
+// ValidScopes lists every scope a token may carry
+var ValidScopes = []string{ScopeMonitorsRead, ScopeMonitorsWrite, ScopeIncidentsRead, ScopeIncidentsManage, ScopeHeartbeatsPush}
+
+// APIToken is a long-lived personal access token. Only the SHA-256 hash of the
+// token is stored; the plain value is shown once when the token is created.
+type APIToken struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	UserID     uint           `gorm:"not null;index" json:"user_id"`
+	Name       string         `gorm:"not null" json:"name"`
+	Prefix     string         `gorm:"not null" json:"prefix"` // first characters, for display
+	TokenHash  string         `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     StringArray    `gorm:"type:text" json:"scopes"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	LastUsedIP string         `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty"`
+}
+
+// GenerateAPIToken returns a new random personal access token together with its hash
+func GenerateAPIToken() (string, string, error) {
+	return GenerateSecretToken(APITokenPrefix)
+}
+
+// GenerateSecretToken returns a random URL-safe token with the given prefix and its hash
+func GenerateSecretToken(prefix string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := prefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes a plain secret token for storage and lookup
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken reports whether a bearer credential is a personal access token
+func IsAPIToken(credential string) bool {
+	return strings.HasPrefix(credential, APITokenPrefix)
+}
+
+// IsActive reports whether the token is neither revoked nor expired
+func (t *APIToken) IsActive() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt)
+}
+
+// IsValidScope reports whether scope is one of ValidScopes
+func IsValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audited actions. Names are "<resource>.<verb>" so they can be filtered by prefix.
+const (
+	AuditLogin                = "auth.login"
+	AuditLoginFailed          = "auth.login_failed"
+	AuditLoginLocked          = "auth.login_locked"
+	AuditLogout               = "auth.logout"
+	AuditPasswordChange       = "auth.password_change"
+	AuditPasswordReset        = "auth.password_reset"
+	AuditTwoFactorEnable      = "auth.2fa_enable"
+	AuditTwoFactorDisable     = "auth.2fa_disable"
+	AuditTwoFactorReset       = "auth.2fa_reset"
+	AuditAccountDelete        = "user.delete"
+	AuditPreferencesUpdate    = "preferences.update"
+	AuditAPITokenCreate       = "api_token.create"
+	AuditAPITokenRevoke       = "api_token.revoke"
+	AuditMonitorCreate        = "monitor.create"
+	AuditMonitorUpdate        = "monitor.update"
+	AuditMonitorToggle        = "monitor.toggle"
+	AuditMonitorDelete        = "monitor.delete"
+	AuditMonitorImport        = "monitor.import"
+	AuditGroupCreate          = "monitor_group.create"
+	AuditGroupUpdate          = "monitor_group.update"
+	AuditGroupDelete          = "monitor_group.delete"
+	AuditStatusPageCreate     = "status_page.create"
+	AuditStatusPageUpdate     = "status_page.update"
+	AuditStatusPageDelete     = "status_page.delete"
+	AuditStatusDomainVerify   = "status_page.domain_verify"
+	AuditStatusIncidentCreate = "status_page_incident.create"
+	AuditStatusIncidentUpdate = "status_page_incident.update"
+	AuditStatusIncidentDelete = "status_page_incident.delete"
+	AuditMaintenanceCreate    = "status_page_maintenance.create"
+	AuditMaintenanceUpdate    = "status_page_maintenance.update"
+	AuditMaintenanceDelete    = "status_page_maintenance.delete"
+	AuditSubscriberDelete     = "status_page_subscriber.delete"
+	AuditBadgeTokenCreate     = "badge_token.create"
+	AuditBadgeTokenDelete     = "badge_token.delete"
+	AuditAgentCreate          = "agent.create"
+	AuditAgentDelete          = "agent.delete"
+	AuditIncidentAck          = "incident.acknowledge"
+	AuditCommandExecute       = "command.execute"
+)
+
+// ErrAuditLogImmutable is returned when something tries to change or remove an audit entry
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be changed or deleted")
+
+// AuditLog is one entry of the append-only audit trail. Entries keep the
+// actor's email so they stay readable after the account is deleted.
+type AuditLog struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	UserID       *uint     `gorm:"index" json:"user_id,omitempty"`
+	TeamID       *uint     `gorm:"index" json:"team_id,omitempty"`
+	ActorEmail   string    `json:"actor_email,omitempty"`
+	Action       string    `gorm:"not null;index" json:"action"`
+	ResourceType string    `gorm:"index" json:"resource_type,omitempty"`
+	ResourceID   string    `gorm:"index" json:"resource_id,omitempty"`
+	Success      bool      `gorm:"index" json:"success"`
+	IP           string    `json:"ip,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	Details      string    `gorm:"type:text" json:"details,omitempty"` // JSON object
+}
+
+// BeforeUpdate keeps entries immutable
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete keeps entries from being removed
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// AuditActor identifies who performed an audited action and from where
+type AuditActor struct {
+	UserID    *uint
+	TeamID    *uint
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+// RecordAudit appends an entry to the audit trail. Failures are logged rather
+// than returned so auditing never breaks the action being audited.
+func RecordAudit(db *gorm.DB, actor AuditActor, action, resourceType string, resourceID interface{}, success bool, details interface{}) {
+	entry := AuditLog{
+		UserID:       actor.UserID,
+		TeamID:       actor.TeamID,
+		ActorEmail:   actor.Email,
+		Action:       action,
+		ResourceType: resourceType,
+		Success:      success,
+		IP:           actor.IP,
+		UserAgent:    actor.UserAgent,
+	}
+	if resourceID != nil {
+		entry.ResourceID = fmt.Sprint(resourceID)
+	}
+	if details != nil {
+		if raw, err := json.Marshal(details); err == nil {
+			entry.Details = string(raw)
+		}
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record audit entry %s: %v", action, err)
+	}
+}
+
+// auditTriggers back the hooks at the database level for writes that bypass GORM
+var auditTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS audit_logs_no_update BEFORE UPDATE ON audit_logs
+	BEGIN SELECT RAISE(ABORT, 'audit log entries cannot be changed'); END`,
+	`CREATE TRIGGER IF NOT EXISTS audit_logs_no_delete BEFORE DELETE ON audit_logs
+	BEGIN SELECT RAISE(ABORT, 'audit log entries cannot be deleted'); END`,
+}
+
+// ProtectAuditLog installs the triggers that make the audit table append-only
+func ProtectAuditLog(db *gorm.DB) error {
+	for _, trigger := range auditTriggers {
+		if err := db.Exec(trigger).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
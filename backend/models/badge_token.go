@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// BadgeTokenPrefix marks badge tokens
+const BadgeTokenPrefix = "rnxb_"
+
+// BadgeToken lets anyone holding it render the badges of one monitor or
+// group, e.g. from a README. Like API tokens, only the hash is stored.
+type BadgeToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	TeamID    *uint     `gorm:"index" json:"team_id,omitempty"`
+	Name      string    `gorm:"not null" json:"name"`
+	MonitorID *uint     `gorm:"index" json:"monitor_id,omitempty"`
+	GroupID   *uint     `gorm:"index" json:"group_id,omitempty"`
+	Prefix    string    `gorm:"not null" json:"prefix"` // first characters, for display
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"`
+}
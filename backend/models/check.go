@@ -16,8 +16,58 @@ type Check struct {
 	StatusCode  int            `json:"status_code,omitempty"`
 	ErrorMsg    string         `json:"error_msg,omitempty"`
 	ResponseTime time.Duration `json:"response_time"`
+	// Location is where the check ran; empty for heartbeats
+	Location    string         `json:"location,omitempty"`
     // Root cause classification
-    CauseType   string         `json:"cause_type,omitempty"`   // dns_error, connection_timeout, http_error, ssl_error, tcp_error, unknown
+    CauseType   string         `json:"cause_type,omitempty"`   // dns_error, connection_timeout, tcp_error, ssl_error, response_timeout, transfer_error, http_error, unknown
     CauseDetail string         `json:"cause_detail,omitempty"`
+    // Timing breaks HTTP checks down into phases; nil for other checks
+    Timing      *CheckTiming   `gorm:"embedded;embeddedPrefix:timing_" json:"timing,omitempty"`
+}
+
+// Phases of an HTTP check, in the order they happen
+const (
+	PhaseDNS      = "dns"
+	PhaseConnect  = "connect"
+	PhaseTLS      = "tls"
+	PhaseTTFB     = "ttfb"
+	PhaseTransfer = "transfer"
+)
+
+// CheckTiming is where the time of an HTTP check went. Phases that did not
+// happen are zero: DNS for an IP address, TLS for plain HTTP, and all of the
+// connection setup when a connection was reused. Across redirects the phases
+// of every request add up.
+type CheckTiming struct {
+	DNSMs     int64 `json:"dns_ms"`
+	ConnectMs int64 `json:"connect_ms"`
+	TLSMs     int64 `json:"tls_ms"`
+	// TTFBMs runs from having a connection to the first byte of the
+	// response: sending the request and the server's processing
+	TTFBMs     int64 `json:"ttfb_ms"`
+	TransferMs int64 `json:"transfer_ms"`
+	// RemoteIP is the address the last request was sent to
+	RemoteIP   string `json:"remote_ip,omitempty"`
+	Protocol   string `json:"protocol,omitempty"` // e.g. HTTP/1.1, HTTP/2.0
+	ConnReused bool   `json:"conn_reused"`
+	// FailedPhase is the phase a failed request stopped in
+	FailedPhase string `json:"failed_phase,omitempty"`
+}
+
+// Slowest returns the phase that took the longest, empty if none took time
+func (t CheckTiming) Slowest() string {
+	phase, longest := "", int64(0)
+	for _, p := range []struct {
+		name string
+		ms   int64
+	}{
+		{PhaseDNS, t.DNSMs}, {PhaseConnect, t.ConnectMs}, {PhaseTLS, t.TLSMs},
+		{PhaseTTFB, t.TTFBMs}, {PhaseTransfer, t.TransferMs},
+	} {
+		if p.ms > longest {
+			phase, longest = p.name, p.ms
+		}
+	}
+	return phase
 }
 
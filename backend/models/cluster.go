@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ClusterMember is a running backend instance. Instances heartbeat while
+// they run; one whose heartbeat is older than the member TTL is gone.
+type ClusterMember struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	Hostname    string    `json:"hostname"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `gorm:"not null;index" json:"heartbeat_at"`
+}
+
+// ClusterLease is held by at most one instance at a time, until ExpiresAt
+// (Unix milliseconds) unless renewed
+type ClusterLease struct {
+	Name      string `gorm:"primaryKey" json:"name"`
+	Holder    string `gorm:"not null" json:"holder"`
+	ExpiresAt int64  `gorm:"not null" json:"expires_at"`
+}
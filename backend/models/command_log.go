@@ -20,3 +20,13 @@ type CommandLog struct {
     Error      string  `gorm:"type:text" json:"error"`
     Duration   int64   `json:"duration_ms"`
 }
+
+// FailInterruptedCommands marks commands still running since before the given
+// time as failed. Commands time out well before that, so they were cut short
+// by a server that stopped without finishing them.
+func FailInterruptedCommands(db *gorm.DB, before time.Time) (int64, error) {
+    result := db.Model(&CommandLog{}).
+        Where("status = ? AND created_at < ?", "running", before).
+        Updates(map[string]interface{}{"status": "failed", "error": "Interrupted by a server restart"})
+    return result.RowsAffected, result.Error
+}
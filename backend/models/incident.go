@@ -12,11 +12,15 @@ type Incident struct {
     DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 
     UserID     uint           `gorm:"not null;index" json:"user_id"`
+    TeamID     *uint          `gorm:"index" json:"team_id,omitempty"`
     MonitorID  uint           `gorm:"not null;index" json:"monitor_id"`
     Timestamp  time.Time      `gorm:"index" json:"timestamp"`
     Severity   string         `gorm:"index" json:"severity"` // info, warn, critical
     Summary    string         `json:"summary"`
     Type       string         `gorm:"index" json:"type"` // down, spike, recovery
+
+    AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+    AcknowledgedBy *uint      `json:"acknowledged_by,omitempty"`
 }
 
 type IncidentEvent struct {
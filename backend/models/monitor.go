@@ -30,12 +30,36 @@ func (s StringArray) Value() (driver.Value, error) {
 	return json.Marshal(s)
 }
 
+// Monitor criticality levels. They weigh a monitor in the mood of its workspace.
+const (
+	CriticalityLow      = "low"
+	CriticalityNormal   = "normal"
+	CriticalityHigh     = "high"
+	CriticalityCritical = "critical"
+)
+
+var criticalityWeights = map[string]float64{
+	CriticalityLow:      0.5,
+	CriticalityNormal:   1,
+	CriticalityHigh:     2,
+	CriticalityCritical: 4,
+}
+
+// CriticalityWeight returns how much a monitor of the given criticality counts
+func CriticalityWeight(criticality string) float64 {
+	if weight, ok := criticalityWeights[criticality]; ok {
+		return weight
+	}
+	return criticalityWeights[CriticalityNormal]
+}
+
 type Monitor struct {
 	ID              uint           `gorm:"primarykey" json:"id"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 	UserID          uint           `gorm:"not null;index" json:"user_id"`
+	TeamID          *uint          `gorm:"index" json:"team_id,omitempty"`
 	Name            string         `gorm:"not null" json:"name"`
 	Type            string         `gorm:"not null" json:"type"` // http, ping, tcp, dns
 	Endpoint        string         `gorm:"not null" json:"endpoint"`
@@ -45,6 +69,11 @@ type Monitor struct {
 	HeadersJSON     string         `json:"headers_json,omitempty"`
 	Enabled         bool           `gorm:"default:true" json:"enabled"`
 	Tags            StringArray    `gorm:"type:text" json:"tags"`
+	Criticality     string         `gorm:"default:normal" json:"criticality"` // low, normal, high, critical
+	// Locations run the checks; empty means the server alone. The monitor is
+	// down once MinFailedLocations of the locations reporting see it down.
+	Locations          StringArray `gorm:"type:text" json:"locations"`
+	MinFailedLocations int         `gorm:"default:1" json:"min_failed_locations"`
 	
 	// Status fields
 	Status         string    `gorm:"default:pending" json:"status"` // up, down, paused, pending
@@ -53,14 +82,20 @@ type Monitor struct {
 	UptimePercent  float64   `gorm:"default:0" json:"uptime_percent"`
 	TotalChecks    int64     `gorm:"default:0" json:"total_checks"`
 	SuccessfulChecks int64   `gorm:"default:0" json:"successful_checks"`
+	// CertExpiresAt is when the certificate seen by the last HTTPS check expires
+	CertExpiresAt  *time.Time `json:"cert_expires_at,omitempty"`
 	
 	// Relations
 	Checks []Check `gorm:"foreignKey:MonitorID;constraint:OnDelete:CASCADE" json:"-"`
 }
 
-// UpdateStatus updates monitor status after a check
-func (m *Monitor) UpdateStatus(db *gorm.DB, status string, latencyMs int64) error {
+// UpdateStatus records a check in the monitor's status and counters, and
+// reports whether it changed the status. The change is only claimed if the
+// status is still the one read, so when several instances record checks of
+// the same monitor just one of them sees, and alerts on, each change.
+func (m *Monitor) UpdateStatus(db *gorm.DB, status string, latencyMs int64) (bool, error) {
 	now := time.Now()
+	previous := m.Status
 	m.Status = status
 	m.LastCheckAt = &now
 	m.LastLatencyMs = &latencyMs
@@ -74,7 +109,43 @@ func (m *Monitor) UpdateStatus(db *gorm.DB, status string, latencyMs int64) erro
 	if m.TotalChecks > 0 {
 		m.UptimePercent = float64(m.SuccessfulChecks) / float64(m.TotalChecks) * 100
 	}
-	
-	return db.Save(m).Error
+
+	// Columns are written directly so UpdatedAt only moves with settings
+	columns := map[string]interface{}{
+		"status":            m.Status,
+		"last_check_at":     m.LastCheckAt,
+		"last_latency_ms":   m.LastLatencyMs,
+		"total_checks":      m.TotalChecks,
+		"successful_checks": m.SuccessfulChecks,
+		"uptime_percent":    m.UptimePercent,
+		"cert_expires_at":   m.CertExpiresAt,
+	}
+	result := db.Model(m).Where("status = ?", previous).UpdateColumns(columns)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Another instance changed the status first
+		return false, db.Model(m).UpdateColumns(columns).Error
+	}
+	return previous != status, nil
+}
+
+// ProbeLocations returns where the monitor is checked; local is the server's
+// own location, used when no locations are set
+func (m *Monitor) ProbeLocations(local string) []string {
+	if len(m.Locations) == 0 {
+		return []string{local}
+	}
+	return m.Locations
 }
 
+// ChecksAt reports whether the monitor is checked from location
+func (m *Monitor) ChecksAt(location, local string) bool {
+	for _, l := range m.ProbeLocations(local) {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Group status policies
+const (
+	// GroupPolicyAllUp takes the group down as soon as any member is down
+	GroupPolicyAllUp = "all_up"
+	// GroupPolicyAnyUp keeps the group up while at least one member is up
+	GroupPolicyAnyUp = "any_up"
+	// GroupPolicyQuorum keeps the group up while the weighted share of
+	// members that are up reaches the group's quorum
+	GroupPolicyQuorum = "quorum"
+)
+
+// Group statuses; degraded means some members are down but the policy still
+// holds
+const (
+	GroupStatusPending  = "pending"
+	GroupStatusUp       = "up"
+	GroupStatusDegraded = "degraded"
+	GroupStatusDown     = "down"
+)
+
+// MonitorGroup aggregates monitors and other groups, e.g. a service made of
+// an API, its database and a CDN
+type MonitorGroup struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	TeamID      *uint          `gorm:"index" json:"team_id,omitempty"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `json:"description,omitempty"`
+	Policy      string         `gorm:"default:all_up" json:"policy"`
+	// Quorum is the percentage used by the quorum policy
+	Quorum float64 `gorm:"default:50" json:"quorum"`
+
+	// Status fields
+	Status             string     `gorm:"default:pending" json:"status"`
+	HealthPercent      float64    `json:"health_percent"`
+	LastCheckAt        *time.Time `json:"last_check_at,omitempty"`
+	LastStatusChangeAt *time.Time `json:"last_status_change_at,omitempty"`
+	UptimePercent      float64    `gorm:"default:0" json:"uptime_percent"`
+	TotalChecks        int64      `gorm:"default:0" json:"total_checks"`
+	SuccessfulChecks   int64      `gorm:"default:0" json:"successful_checks"`
+
+	Members []MonitorGroupMember `gorm:"foreignKey:GroupID" json:"members,omitempty"`
+}
+
+// MonitorGroupMember puts a monitor, or a nested group, in a group. A
+// critical member that is down takes the group down whatever the policy.
+type MonitorGroupMember struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	GroupID      uint      `gorm:"not null;index" json:"group_id"`
+	MonitorID    *uint     `gorm:"index" json:"monitor_id,omitempty"`
+	ChildGroupID *uint     `gorm:"index" json:"child_group_id,omitempty"`
+	Weight       float64   `gorm:"default:1" json:"weight"`
+	Critical     bool      `json:"critical"`
+}
+
+// MonitorGroupCheck is one entry of a group's status history
+type MonitorGroupCheck struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+	GroupID       uint      `gorm:"not null;index" json:"group_id"`
+	Status        string    `gorm:"not null" json:"status"`
+	HealthPercent float64   `json:"health_percent"`
+	UpMembers     int       `json:"up_members"`
+	DownMembers   int       `json:"down_members"`
+}
+
+// IsValidGroupPolicy reports whether policy is a known group policy
+func IsValidGroupPolicy(policy string) bool {
+	switch policy {
+	case GroupPolicyAllUp, GroupPolicyAnyUp, GroupPolicyQuorum:
+		return true
+	}
+	return false
+}
+
+// ParentGroupIDs returns the groups that have the given group as a member
+func ParentGroupIDs(db *gorm.DB, groupID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&MonitorGroupMember{}).Where("child_group_id = ?", groupID).Distinct().Pluck("group_id", &ids).Error
+	return ids, err
+}
+
+// GroupContains reports whether needle is group or one of its nested groups
+func GroupContains(db *gorm.DB, groupID, needle uint) (bool, error) {
+	seen := map[uint]bool{}
+	queue := []uint{groupID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == needle {
+			return true, nil
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var children []uint
+		if err := db.Model(&MonitorGroupMember{}).Where("group_id = ? AND child_group_id IS NOT NULL", id).
+			Pluck("child_group_id", &children).Error; err != nil {
+			return false, err
+		}
+		queue = append(queue, children...)
+	}
+	return false, nil
+}
+
+// GroupMonitorIDs returns the monitors of a group and of its nested groups
+func GroupMonitorIDs(db *gorm.DB, groupID uint) ([]uint, error) {
+	seen := map[uint]bool{}
+	queue := []uint{groupID}
+	var monitorIDs []uint
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var members []MonitorGroupMember
+		if err := db.Where("group_id = ?", id).Find(&members).Error; err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if member.MonitorID != nil {
+				monitorIDs = append(monitorIDs, *member.MonitorID)
+			} else if member.ChildGroupID != nil {
+				queue = append(queue, *member.ChildGroupID)
+			}
+		}
+	}
+	return monitorIDs, nil
+}
@@ -13,6 +13,8 @@ type Notification struct {
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 	UserID     uint           `gorm:"not null;index" json:"user_id"`
 	MonitorID  uint           `gorm:"not null;index" json:"monitor_id"`
+	// GroupID is set, and MonitorID is 0, for monitor group notifications
+	GroupID    *uint          `gorm:"index" json:"group_id,omitempty"`
 	Type       string         `gorm:"not null" json:"type"` // down, up, warning
 	Message    string         `gorm:"not null" json:"message"`
 	SeenAt     *time.Time     `json:"seen_at,omitempty"`
@@ -38,6 +40,22 @@ func CreateNotification(db *gorm.DB, userID, monitorID uint, notifType, message
 	return &notification, nil
 }
 
+// CreateGroupNotification creates a notification about a monitor group
+func CreateGroupNotification(db *gorm.DB, userID, groupID uint, notifType, message string) (*Notification, error) {
+	notification := Notification{
+		UserID:  userID,
+		GroupID: &groupID,
+		Type:    notifType,
+		Message: message,
+	}
+
+	if err := db.Create(&notification).Error; err != nil {
+		return nil, err
+	}
+
+	return &notification, nil
+}
+
 // MarkAsSeen marks a notification as seen
 func (n *Notification) MarkAsSeen(db *gorm.DB) error {
 	now := time.Now()
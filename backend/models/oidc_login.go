@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// OIDCLoginTTL is how long a user has to complete login at the identity provider
+const OIDCLoginTTL = 10 * time.Minute
+
+// OIDCLogin holds the per-attempt secrets of an authorization-code login
+// between the redirect to the identity provider and its callback. Rows are
+// deleted when the callback consumes them.
+type OIDCLogin struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	State        string    `gorm:"uniqueIndex;not null" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	// BindingHash is the hash of the secret kept in the browser that started
+	// the login, so the callback cannot be completed anywhere else
+	BindingHash  string    `json:"-"`
+	ResponseMode string    `json:"response_mode"` // "redirect" or "json"
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at"`
+}
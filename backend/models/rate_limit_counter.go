@@ -0,0 +1,9 @@
+package models
+
+// RateLimitCounter is a fixed-window request counter shared by every replica
+// that uses the database rate limit store. ResetAt is in Unix milliseconds.
+type RateLimitCounter struct {
+	Bucket  string `gorm:"primaryKey"`
+	Count   int    `gorm:"not null"`
+	ResetAt int64  `gorm:"not null;index"`
+}
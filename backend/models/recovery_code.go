@@ -0,0 +1,80 @@
+package models
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at a time
+const RecoveryCodeCount = 10
+
+// recoveryAlphabet has 32 characters, none easy to misread for another
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz023456789"
+
+// RecoveryCode is a one-time code that can stand in for a TOTP code. Only its
+// hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// GenerateRecoveryCodes replaces a user's recovery codes and returns the new
+// plain codes, formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	records := make([]RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryAlphabet[b&31])
+		}
+		codes[i] = sb.String()
+		records[i] = RecoveryCode{UserID: userID, CodeHash: HashToken(codes[i])}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := DeleteRecoveryCodes(tx, userID); err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode consumes a recovery code; it reports false if the code is
+// unknown or was already used
+func UseRecoveryCode(db *gorm.DB, userID uint, code string) bool {
+	hash := HashToken(strings.ToLower(strings.TrimSpace(code)))
+	result := db.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// RemainingRecoveryCodes counts a user's unused recovery codes
+func RemainingRecoveryCodes(db *gorm.DB, userID uint) int64 {
+	var count int64
+	db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// DeleteRecoveryCodes removes all of a user's recovery codes
+func DeleteRecoveryCodes(db *gorm.DB, userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshTokenPrefix marks refresh tokens so they can be told apart from other secrets
+const RefreshTokenPrefix = "rnxr_"
+
+// Lifetimes of the two halves of a login session
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Session is a login on one device. The refresh token is rotated on every use;
+// only hashes of the current and the previous token are stored so that replay
+// of an already rotated token can be detected.
+type Session struct {
+	ID                uint       `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	UserID            uint       `gorm:"not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`
+	UserAgent         string     `json:"user_agent"`
+	IP                string     `json:"ip"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// RevokeSessions revokes every active session matched by the query and
+// returns the IDs that were revoked
+func RevokeSessions(db *gorm.DB, query interface{}, args ...interface{}) ([]uint, error) {
+	var ids []uint
+	if err := db.Model(&Session{}).Where(query, args...).Where("revoked_at IS NULL").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+	now := time.Now()
+	if err := db.Model(&Session{}).Where("id IN ?", ids).Update("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// PurgeExpiredSessions deletes sessions that expired or were revoked more than a week ago
+func PurgeExpiredSessions(db *gorm.DB) error {
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	return db.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&Session{}).Error
+}
@@ -12,7 +12,10 @@ type SLAReport struct {
     DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 
     UserID        uint      `gorm:"not null;index" json:"user_id"`
+    TeamID        *uint     `gorm:"index" json:"team_id,omitempty"`
     MonitorID     uint      `gorm:"not null;index" json:"monitor_id"`
+    // GroupID is set, and MonitorID is 0, for monitor group reports
+    GroupID       *uint     `gorm:"index" json:"group_id,omitempty"`
     ReportDate    time.Time `gorm:"index" json:"report_date"`
     UptimePercent float64   `json:"uptime_percent"`
     DowntimeMinutes int64   `json:"downtime_minutes"`
@@ -1,3 +1,192 @@
 package models
 
-// StatusPage model removed per spec
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// DomainTokenPrefix starts the tokens proving control of a custom domain
+const DomainTokenPrefix = "rnxd_"
+
+// DomainChallengePrefix names the DNS TXT record holding a page's domain
+// token, e.g. _runnerx-challenge.status.example.com
+const DomainChallengePrefix = "_runnerx-challenge."
+
+// Status page incident states, in the order they usually go through
+const (
+	StatusIncidentInvestigating = "investigating"
+	StatusIncidentIdentified    = "identified"
+	StatusIncidentMonitoring    = "monitoring"
+	StatusIncidentResolved      = "resolved"
+)
+
+// StatusPage is a public page showing the status of selected monitors and
+// monitor groups. It is reachable at /status/<slug> and, when CustomDomain is
+// set, on that domain.
+type StatusPage struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	TeamID      *uint          `gorm:"index" json:"team_id,omitempty"`
+	Slug        string         `gorm:"uniqueIndex;not null" json:"slug"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `json:"description,omitempty"`
+	Layout      string         `gorm:"default:grid" json:"layout"` // grid, list
+	ShowUptime  bool           `json:"show_uptime"`
+	ShowLatency bool           `json:"show_latency"`
+	// CustomDomain is served only once control of it was proven; a requested
+	// domain waits in PendingDomain until DomainToken is found in DNS
+	CustomDomain     *string    `gorm:"uniqueIndex" json:"custom_domain,omitempty"`
+	DomainVerifiedAt *time.Time `json:"domain_verified_at,omitempty"`
+	PendingDomain    string     `gorm:"index" json:"pending_domain,omitempty"`
+	DomainToken      string     `json:"domain_token,omitempty"`
+	// PasswordHash is empty for pages anyone can see
+	PasswordHash string `json:"-"`
+	Protected    bool   `gorm:"-" json:"password_protected"`
+
+	Items []StatusPageItem `gorm:"foreignKey:StatusPageID" json:"items,omitempty"`
+}
+
+// StatusPageItem shows a monitor or a monitor group on a status page
+type StatusPageItem struct {
+	ID           uint  `gorm:"primarykey" json:"id"`
+	StatusPageID uint  `gorm:"not null;index" json:"status_page_id"`
+	MonitorID    *uint `gorm:"index" json:"monitor_id,omitempty"`
+	GroupID      *uint `gorm:"index" json:"group_id,omitempty"`
+	// DisplayName replaces the monitor's or group's own name on the page
+	DisplayName string `json:"display_name,omitempty"`
+	Position    int    `json:"position"`
+}
+
+// StatusPageIncident is an incident announced on a status page and kept up
+// to date by hand
+type StatusPageIncident struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	StatusPageID uint      `gorm:"not null;index" json:"status_page_id"`
+	Title        string    `gorm:"not null" json:"title"`
+	Status       string    `gorm:"not null" json:"status"`
+	Impact       string    `gorm:"default:minor" json:"impact"` // none, minor, major, critical
+	// Components lists the affected components as "monitor:<id>" or
+	// "group:<id>"; empty means the whole page
+	Components StringArray `gorm:"type:text" json:"components"`
+	ResolvedAt *time.Time  `json:"resolved_at,omitempty"`
+
+	Updates []StatusPageIncidentUpdate `gorm:"foreignKey:IncidentID" json:"updates,omitempty"`
+}
+
+// StatusPageIncidentUpdate is one message posted on a status page incident
+type StatusPageIncidentUpdate struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	IncidentID uint      `gorm:"not null;index" json:"incident_id"`
+	Status     string    `gorm:"not null" json:"status"`
+	Message    string    `gorm:"not null" json:"message"`
+}
+
+// StatusPageMaintenance is a scheduled maintenance window shown on a status
+// page
+type StatusPageMaintenance struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	StatusPageID uint      `gorm:"not null;index" json:"status_page_id"`
+	Title        string    `gorm:"not null" json:"title"`
+	Description  string    `json:"description,omitempty"`
+	StartsAt     time.Time `gorm:"not null;index" json:"starts_at"`
+	EndsAt       time.Time `gorm:"not null;index" json:"ends_at"`
+}
+
+// SetPassword protects the page with a password, or removes the protection
+// when password is empty
+func (p *StatusPage) SetPassword(password string) error {
+	if password == "" {
+		p.PasswordHash = ""
+		p.Protected = false
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	p.PasswordHash = string(hash)
+	p.Protected = true
+	return nil
+}
+
+// RequestDomain asks for domain to become the page's custom domain. A new
+// domain waits for verification while the current one is still served; an
+// empty domain removes both.
+func (p *StatusPage) RequestDomain(domain string) error {
+	switch {
+	case domain == "":
+		p.CustomDomain, p.DomainVerifiedAt = nil, nil
+		p.PendingDomain, p.DomainToken = "", ""
+	case p.CustomDomain != nil && *p.CustomDomain == domain:
+		p.PendingDomain, p.DomainToken = "", ""
+	case domain != p.PendingDomain:
+		token, _, err := GenerateSecretToken(DomainTokenPrefix)
+		if err != nil {
+			return err
+		}
+		p.PendingDomain, p.DomainToken = domain, token
+	}
+	return nil
+}
+
+// VerifyDomain makes the pending domain the page's custom domain
+func (p *StatusPage) VerifyDomain(at time.Time) {
+	domain := p.PendingDomain
+	p.CustomDomain, p.DomainVerifiedAt = &domain, &at
+	p.PendingDomain, p.DomainToken = "", ""
+}
+
+// IsStatusPageDomain reports whether domain is the verified custom domain of
+// a status page
+func IsStatusPageDomain(db *gorm.DB, domain string) bool {
+	var count int64
+	db.Model(&StatusPage{}).Where("custom_domain = ?", strings.ToLower(domain)).Count(&count)
+	return count > 0
+}
+
+// RequireDomainVerification moves custom domains that were never verified
+// back to pending, so they are no longer served until their owner proves
+// control of them
+func RequireDomainVerification(db *gorm.DB) error {
+	var pages []StatusPage
+	if err := db.Where("custom_domain IS NOT NULL AND domain_verified_at IS NULL").Find(&pages).Error; err != nil {
+		return err
+	}
+	for _, page := range pages {
+		domain := *page.CustomDomain
+		page.CustomDomain = nil
+		if err := page.RequestDomain(domain); err != nil {
+			return err
+		}
+		err := db.Model(&StatusPage{}).Where("id = ?", page.ID).Updates(map[string]interface{}{
+			"custom_domain":  nil,
+			"pending_domain": page.PendingDomain,
+			"domain_token":   page.DomainToken,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *StatusPage) AfterFind(tx *gorm.DB) error {
+	p.Protected = p.PasswordHash != ""
+	return nil
+}
+
+// CheckPassword compares a password with the page's one
+func (p *StatusPage) CheckPassword(password string) bool {
+	return p.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(p.PasswordHash), []byte(password)) == nil
+}
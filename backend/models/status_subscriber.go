@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatusSubscriberTokenPrefix marks status page subscription confirmation tokens
+const StatusSubscriberTokenPrefix = "rnxs_"
+
+// SubscriberConfirmTTL is how long an email subscription can be confirmed
+const SubscriberConfirmTTL = 7 * 24 * time.Hour
+
+// Subscriber delivery types
+const (
+	SubscriberEmail   = "email"
+	SubscriberWebhook = "webhook"
+)
+
+// StatusPageSubscriber receives the incident updates of a status page by
+// email or webhook. Email subscriptions only count once confirmed through the
+// link sent to the address.
+type StatusPageSubscriber struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	StatusPageID uint      `gorm:"not null;index" json:"status_page_id"`
+	Type         string    `gorm:"not null" json:"type"`
+	Email        string    `gorm:"index" json:"email,omitempty"`
+	WebhookURL   string    `json:"webhook_url,omitempty"`
+	// Components limits notifications to incidents affecting these
+	// components; empty means every incident
+	Components StringArray `gorm:"type:text" json:"components"`
+
+	ConfirmTokenHash string     `gorm:"index" json:"-"`
+	ConfirmSentAt    *time.Time `json:"-"`
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty"`
+	// WebhookSecret signs webhook deliveries
+	WebhookSecret string `json:"-"`
+}
+
+// ComponentKey names a status page component in incidents and subscriptions
+func ComponentKey(kind string, id uint) string {
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+// Wants reports whether the subscriber asked for incidents affecting the
+// given components. An incident without components concerns everyone.
+func (s *StatusPageSubscriber) Wants(components []string) bool {
+	if len(s.Components) == 0 || len(components) == 0 {
+		return true
+	}
+	for _, want := range s.Components {
+		for _, c := range components {
+			if want == c {
+				return true
+			}
+		}
+	}
+	return false
+}
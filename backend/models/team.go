@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Team roles, from most to least privileged
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleEditor    = "editor"
+	RoleResponder = "responder"
+	RoleViewer    = "viewer"
+)
+
+// Permissions checked against a member's role
+const (
+	PermissionView          = "view"           // read monitors, incidents and reports
+	PermissionRespond       = "respond"        // acknowledge and annotate incidents
+	PermissionEdit          = "edit"           // create, change and delete monitors
+	PermissionManageMembers = "manage_members" // invite, remove and re-role members
+	PermissionManageTeam    = "manage_team"    // rename or delete the team
+	PermissionViewAudit     = "view_audit"     // read and export the team's audit log
+)
+
+var rolePermissions = map[string][]string{
+	RoleOwner:     {PermissionView, PermissionRespond, PermissionEdit, PermissionManageMembers, PermissionManageTeam, PermissionViewAudit},
+	RoleAdmin:     {PermissionView, PermissionRespond, PermissionEdit, PermissionManageMembers, PermissionViewAudit},
+	RoleEditor:    {PermissionView, PermissionRespond, PermissionEdit},
+	RoleResponder: {PermissionView, PermissionRespond},
+	RoleViewer:    {PermissionView},
+}
+
+// IsValidTeamRole reports whether role is a known team role
+func IsValidTeamRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows reports whether a team role grants a permission
+func RoleAllows(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Team groups users that share monitors, incidents and SLA reports
+type Team struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	Name        string         `gorm:"not null" json:"name"`
+	CreatedByID uint           `gorm:"not null" json:"created_by_id"`
+}
+
+// TeamMembership links a user to a team with a role
+type TeamMembership struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TeamID    uint      `gorm:"not null;uniqueIndex:idx_team_member" json:"team_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_team_member;index" json:"user_id"`
+	Role      string    `gorm:"not null" json:"role"`
+
+	// Relations
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TeamInvitation is a pending invitation to join a team. Only the hash of the
+// invitation token is stored.
+type TeamInvitation struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	TeamID      uint       `gorm:"not null;index" json:"team_id"`
+	Email       string     `gorm:"not null;index" json:"email"`
+	Role        string     `gorm:"not null" json:"role"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedByID uint       `gorm:"not null" json:"invited_by_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
+
+// GetTeamMembership returns the membership of a user in a team
+func GetTeamMembership(db *gorm.DB, teamID, userID uint) (*TeamMembership, error) {
+	var membership TeamMembership
+	if err := db.Where("team_id = ? AND user_id = ?", teamID, userID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// TeamMemberIDs returns the IDs of every member of a team
+func TeamMemberIDs(db *gorm.DB, teamID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&TeamMembership{}).Where("team_id = ?", teamID).Pluck("user_id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rollup subjects
+const (
+	RollupMonitor = "monitor"
+	RollupGroup   = "group"
+)
+
+// RollupRetention is how long hourly rollups are kept
+const RollupRetention = 35 * 24 * time.Hour
+
+// UptimeRollup sums the checks of a monitor, or the status history of a
+// group, per UTC hour so uptime and response time over long periods can be
+// read without scanning every check
+type UptimeRollup struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	Kind         string    `gorm:"not null;uniqueIndex:idx_rollup_subject_hour" json:"kind"`
+	SubjectID    uint      `gorm:"not null;uniqueIndex:idx_rollup_subject_hour" json:"subject_id"`
+	Hour         time.Time `gorm:"not null;uniqueIndex:idx_rollup_subject_hour" json:"hour"`
+	Checks       int64     `json:"checks"`
+	UpChecks     int64     `json:"up_checks"`
+	LatencySumMs int64     `json:"latency_sum_ms"` // over up checks
+
+	// Phases of the up HTTP checks with a timing breakdown
+	TimedChecks   int64 `gorm:"not null;default:0" json:"timed_checks"`
+	DNSSumMs      int64 `gorm:"not null;default:0" json:"dns_sum_ms"`
+	ConnectSumMs  int64 `gorm:"not null;default:0" json:"connect_sum_ms"`
+	TLSSumMs      int64 `gorm:"not null;default:0" json:"tls_sum_ms"`
+	TTFBSumMs     int64 `gorm:"not null;default:0" json:"ttfb_sum_ms"`
+	TransferSumMs int64 `gorm:"not null;default:0" json:"transfer_sum_ms"`
+}
+
+// AddToRollup counts checks in the hour holding at
+func AddToRollup(db *gorm.DB, kind string, subjectID uint, at time.Time, checks, upChecks, latencySumMs int64) error {
+	rollup := UptimeRollup{
+		Kind:         kind,
+		SubjectID:    subjectID,
+		Hour:         at.UTC().Truncate(time.Hour),
+		Checks:       checks,
+		UpChecks:     upChecks,
+		LatencySumMs: latencySumMs,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "kind"}, {Name: "subject_id"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"checks":         gorm.Expr("checks + ?", checks),
+			"up_checks":      gorm.Expr("up_checks + ?", upChecks),
+			"latency_sum_ms": gorm.Expr("latency_sum_ms + ?", latencySumMs),
+		}),
+	}).Create(&rollup).Error
+}
+
+// AddTimingToRollup adds the phases of an up HTTP check of a monitor to the
+// hour holding at
+func AddTimingToRollup(db *gorm.DB, monitorID uint, at time.Time, timing CheckTiming) error {
+	rollup := UptimeRollup{
+		Kind:          RollupMonitor,
+		SubjectID:     monitorID,
+		Hour:          at.UTC().Truncate(time.Hour),
+		TimedChecks:   1,
+		DNSSumMs:      timing.DNSMs,
+		ConnectSumMs:  timing.ConnectMs,
+		TLSSumMs:      timing.TLSMs,
+		TTFBSumMs:     timing.TTFBMs,
+		TransferSumMs: timing.TransferMs,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "kind"}, {Name: "subject_id"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"timed_checks":    gorm.Expr("timed_checks + 1"),
+			"dns_sum_ms":      gorm.Expr("dns_sum_ms + ?", timing.DNSMs),
+			"connect_sum_ms":  gorm.Expr("connect_sum_ms + ?", timing.ConnectMs),
+			"tls_sum_ms":      gorm.Expr("tls_sum_ms + ?", timing.TLSMs),
+			"ttfb_sum_ms":     gorm.Expr("ttfb_sum_ms + ?", timing.TTFBMs),
+			"transfer_sum_ms": gorm.Expr("transfer_sum_ms + ?", timing.TransferMs),
+		}),
+	}).Create(&rollup).Error
+}
+
+// HourlyTiming is the average of each phase of the up HTTP checks of a
+// monitor in an hour
+type HourlyTiming struct {
+	Hour        time.Time `json:"hour"`
+	TimedChecks int64     `json:"timed_checks"`
+	DNSMs       int64     `json:"dns_ms"`
+	ConnectMs   int64     `json:"connect_ms"`
+	TLSMs       int64     `json:"tls_ms"`
+	TTFBMs      int64     `json:"ttfb_ms"`
+	TransferMs  int64     `json:"transfer_ms"`
+	// SlowestPhase is the phase taking the longest on average
+	SlowestPhase string `json:"slowest_phase,omitempty"`
+}
+
+// MonitorTimings returns the average phases of a monitor's checks per hour
+// since the hour holding since, oldest first, and over the whole period,
+// whose Hour is the first hour of the period
+func MonitorTimings(db *gorm.DB, monitorID uint, since time.Time) ([]HourlyTiming, HourlyTiming, error) {
+	since = since.UTC().Truncate(time.Hour)
+	var rollups []UptimeRollup
+	if err := db.Where("kind = ? AND subject_id = ? AND hour >= ? AND timed_checks > 0", RollupMonitor, monitorID, since).
+		Order("hour ASC").
+		Find(&rollups).Error; err != nil {
+		return nil, HourlyTiming{}, err
+	}
+
+	hours := make([]HourlyTiming, 0, len(rollups))
+	var total UptimeRollup
+	for _, rollup := range rollups {
+		hours = append(hours, rollup.averageTiming())
+		total.TimedChecks += rollup.TimedChecks
+		total.DNSSumMs += rollup.DNSSumMs
+		total.ConnectSumMs += rollup.ConnectSumMs
+		total.TLSSumMs += rollup.TLSSumMs
+		total.TTFBSumMs += rollup.TTFBSumMs
+		total.TransferSumMs += rollup.TransferSumMs
+	}
+	overall := total.averageTiming()
+	overall.Hour = since
+	return hours, overall, nil
+}
+
+func (r UptimeRollup) averageTiming() HourlyTiming {
+	avg := HourlyTiming{Hour: r.Hour, TimedChecks: r.TimedChecks}
+	if r.TimedChecks == 0 {
+		return avg
+	}
+	avg.DNSMs = r.DNSSumMs / r.TimedChecks
+	avg.ConnectMs = r.ConnectSumMs / r.TimedChecks
+	avg.TLSMs = r.TLSSumMs / r.TimedChecks
+	avg.TTFBMs = r.TTFBSumMs / r.TimedChecks
+	avg.TransferMs = r.TransferSumMs / r.TimedChecks
+	avg.SlowestPhase = CheckTiming{
+		DNSMs: avg.DNSMs, ConnectMs: avg.ConnectMs, TLSMs: avg.TLSMs, TTFBMs: avg.TTFBMs, TransferMs: avg.TransferMs,
+	}.Slowest()
+	return avg
+}
+
+// PurgeOldRollups removes rollups past the retention period
+func PurgeOldRollups(db *gorm.DB) error {
+	return db.Where("hour < ?", time.Now().UTC().Add(-RollupRetention)).Delete(&UptimeRollup{}).Error
+}
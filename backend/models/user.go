@@ -16,6 +16,32 @@ type User struct {
 	Email     string         `gorm:"uniqueIndex;not null" json:"email"`
 	Password  string         `gorm:"not null" json:"-"`
 	Role      string         `gorm:"default:user" json:"role"`
+
+	// EmailVerifiedAt is set once the user proves they own the email address
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// Two-factor authentication. The secret is set during enrollment and only
+	// enforced once TOTPEnabled is true; TOTPLastStep blocks code replay.
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `json:"-"`
+
+	// OIDCSubject links the account to the configured identity provider's
+	// "sub" claim; nil for password-only accounts
+	OIDCSubject *string `gorm:"column:oidc_subject;uniqueIndex" json:"-"`
+}
+
+// UserRoleAdmin is the account role allowed to administer other accounts
+const UserRoleAdmin = "admin"
+
+// IsAdmin reports whether the user is an instance administrator
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// EmailVerified reports whether the email address has been verified
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // HashPassword hashes the user password before saving
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserTokenPrefix marks email verification and password reset tokens
+const UserTokenPrefix = "rnxu_"
+
+// Purposes of a user token and how long each stays valid
+const (
+	UserTokenEmailVerification = "email_verification"
+	UserTokenPasswordReset     = "password_reset"
+
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// UserToken is a single-use token sent to a user by email. Only its hash is
+// stored; Email records the address the token was sent to.
+type UserToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"not null;index" json:"purpose"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	Email     string     `gorm:"not null" json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// IssueUserToken creates a token for the purpose and returns its plain value.
+// Outstanding tokens with the same purpose stop working.
+func IssueUserToken(db *gorm.DB, user *User, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := GenerateSecretToken(UserTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).Delete(&UserToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&UserToken{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			TokenHash: hash,
+			Email:     user.Email,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it
+func ConsumeUserToken(db *gorm.DB, token, purpose string) (*UserToken, error) {
+	var record UserToken
+	err := db.Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", HashToken(token), purpose, time.Now()).
+		First(&record).Error
+	if err != nil {
+		return nil, ErrInvalidUserToken
+	}
+
+	// Guard against the same token being redeemed concurrently
+	now := time.Now()
+	result := db.Model(&UserToken{}).Where("id = ? AND used_at IS NULL", record.ID).Update("used_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+	record.UsedAt = &now
+	return &record, nil
+}
+
+// PurgeExpiredUserTokens deletes tokens that can no longer be used
+func PurgeExpiredUserTokens(db *gorm.DB) error {
+	return db.Where("expires_at < ? OR used_at IS NOT NULL", time.Now()).Delete(&UserToken{}).Error
+}
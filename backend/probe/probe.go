@@ -0,0 +1,375 @@
+// Package probe runs monitor checks. It has no database dependency so the
+// server and remote agents run exactly the same checks.
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os/exec"
+	"runnerx/models"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("runnerx/probe")
+
+// maxTransferBytes bounds how much of a response body an HTTP check reads
+// to time the transfer
+const maxTransferBytes = 1 << 20
+
+// Result is the outcome of one check
+type Result struct {
+	Status       string        `json:"status"` // up, down
+	LatencyMs    int64         `json:"latency_ms"`
+	StatusCode   int           `json:"status_code,omitempty"`
+	ErrorMsg     string        `json:"error_msg,omitempty"`
+	ResponseTime time.Duration `json:"response_time"`
+	// CertExpiresAt is set by HTTPS checks
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+	// Timing breaks HTTP checks down into phases
+	Timing *models.CheckTiming `json:"timing,omitempty"`
+}
+
+// Supports reports whether monitors of the given type can be probed; push
+// monitors are checked by the heartbeats they receive instead
+func Supports(monitorType string) bool {
+	switch monitorType {
+	case "http", "ping", "tcp", "dns":
+		return true
+	}
+	return false
+}
+
+// Run checks a monitor once. The check is recorded as a span of the trace in
+// ctx, and gives up when ctx is cancelled.
+func Run(ctx context.Context, monitor *models.Monitor) Result {
+	ctx, span := tracer.Start(ctx, "check "+monitor.Type, trace.WithAttributes(
+		attribute.Int("monitor.id", int(monitor.ID)),
+		attribute.String("monitor.type", monitor.Type),
+		attribute.String("monitor.endpoint", monitor.Endpoint),
+	))
+	defer span.End()
+
+	startTime := time.Now()
+	var result Result
+
+	switch monitor.Type {
+	case "http":
+		result.Status, result.LatencyMs, result.StatusCode, result.ErrorMsg, result.CertExpiresAt, result.Timing = checkHTTP(ctx, monitor)
+	case "ping":
+		result.Status, result.LatencyMs, result.ErrorMsg = checkPing(ctx, monitor)
+	case "tcp":
+		result.Status, result.LatencyMs, result.ErrorMsg = checkTCP(ctx, monitor)
+	case "dns":
+		result.Status, result.LatencyMs, result.ErrorMsg = checkDNS(ctx, monitor)
+	default:
+		result.Status = "down"
+		result.ErrorMsg = fmt.Sprintf("Unknown monitor type %q", monitor.Type)
+	}
+
+	result.ResponseTime = time.Since(startTime)
+	span.SetAttributes(
+		attribute.String("check.status", result.Status),
+		attribute.Int64("check.latency_ms", result.LatencyMs),
+	)
+	if result.Timing != nil {
+		span.SetAttributes(
+			attribute.Int64("check.dns_ms", result.Timing.DNSMs),
+			attribute.Int64("check.connect_ms", result.Timing.ConnectMs),
+			attribute.Int64("check.tls_ms", result.Timing.TLSMs),
+			attribute.Int64("check.ttfb_ms", result.Timing.TTFBMs),
+			attribute.Int64("check.transfer_ms", result.Timing.TransferMs),
+		)
+	}
+	if result.Status != "up" {
+		span.SetStatus(codes.Error, result.ErrorMsg)
+	}
+	return result
+}
+
+func checkHTTP(parent context.Context, monitor *models.Monitor) (string, int64, int, string, *time.Time, *models.CheckTiming) {
+	// Create context with timeout
+	timeout := time.Duration(monitor.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// Create HTTP client with proper configuration
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Allow up to 10 redirects
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+
+	method := monitor.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	// Create request with context
+	req, err := http.NewRequestWithContext(ctx, method, monitor.Endpoint, nil)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"monitor_id": monitor.ID,
+			"endpoint":   monitor.Endpoint,
+			"error":      err.Error(),
+		}).Error("Failed to create HTTP request")
+		return "down", 0, 0, fmt.Sprintf("Invalid endpoint: %v", err), nil, nil
+	}
+
+	ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("url.full", monitor.Endpoint),
+	))
+	defer span.End()
+	timings := &timingTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, timings.clientTrace()))
+
+	// Add headers
+	req.Header.Set("User-Agent", "RunnerX-Monitor/1.0")
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Connection", "close")
+
+	// Add custom headers if provided
+	if monitor.HeadersJSON != "" {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(monitor.HeadersJSON), &headers); err == nil {
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+		}
+	}
+
+	// Let the monitored application join its request to the check's trace
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"monitor_id": monitor.ID,
+			"endpoint":   monitor.Endpoint,
+			"latency_ms": latencyMs,
+			"error":      err.Error(),
+		}).Warn("HTTP check failed")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "down", latencyMs, 0, err.Error(), nil, timings.finish("", err)
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	var certExpiresAt *time.Time
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		certExpiresAt = &resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	// Read response body (limited) for better error reporting
+	var bodyPreview string
+	if resp.Body != nil {
+		bodyBytes := make([]byte, 512) // Read first 512 bytes
+		n, _ := io.ReadFull(resp.Body, bodyBytes)
+		if n > 0 {
+			bodyPreview = string(bodyBytes[:n])
+		}
+	}
+	// Read on to time the transfer of the content
+	_, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxTransferBytes))
+	timing := timings.finish(resp.Proto, err)
+
+	// Determine status based on response code
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		logrus.WithFields(logrus.Fields{
+			"monitor_id":  monitor.ID,
+			"endpoint":    monitor.Endpoint,
+			"status_code": resp.StatusCode,
+			"latency_ms":  latencyMs,
+		}).Info("HTTP check successful")
+		return "up", latencyMs, resp.StatusCode, bodyPreview, certExpiresAt, timing
+	}
+
+	errorMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	if bodyPreview != "" {
+		errorMsg += fmt.Sprintf(" - %s", strings.TrimSpace(bodyPreview))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"monitor_id":  monitor.ID,
+		"endpoint":    monitor.Endpoint,
+		"status_code": resp.StatusCode,
+		"latency_ms":  latencyMs,
+		"error":       errorMsg,
+	}).Warn("HTTP check failed with error status")
+
+	return "down", latencyMs, resp.StatusCode, errorMsg, certExpiresAt, timing
+}
+
+func checkPing(parent context.Context, monitor *models.Monitor) (string, int64, string) {
+	startTime := time.Now()
+
+	// Extract hostname/IP from endpoint
+	endpoint := strings.TrimPrefix(monitor.Endpoint, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint = strings.Split(endpoint, "/")[0]
+	endpoint = strings.Split(endpoint, ":")[0]
+
+	// Create context with timeout
+	timeout := time.Duration(monitor.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// Use ping command with proper timeout
+	cmd := exec.CommandContext(ctx, "ping", "-c", "1", "-W", "5", endpoint)
+	err := cmd.Run()
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"monitor_id": monitor.ID,
+			"endpoint":   endpoint,
+			"latency_ms": latencyMs,
+			"error":      err.Error(),
+		}).Warn("Ping check failed")
+		return "down", latencyMs, err.Error()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"monitor_id": monitor.ID,
+		"endpoint":   endpoint,
+		"latency_ms": latencyMs,
+	}).Info("Ping check successful")
+
+	return "up", latencyMs, ""
+}
+
+func checkTCP(ctx context.Context, monitor *models.Monitor) (string, int64, string) {
+	startTime := time.Now()
+
+	// Extract host and port from endpoint
+	endpoint := strings.TrimPrefix(monitor.Endpoint, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint = strings.Split(endpoint, "/")[0]
+
+	// Default port if not specified
+	if !strings.Contains(endpoint, ":") {
+		endpoint += ":80"
+	}
+
+	// Create context with timeout
+	timeout := time.Duration(monitor.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	// Attempt TCP connection
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("server.address", endpoint))
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"monitor_id": monitor.ID,
+			"endpoint":   endpoint,
+			"latency_ms": latencyMs,
+			"error":      err.Error(),
+		}).Warn("TCP check failed")
+		return "down", latencyMs, err.Error()
+	}
+	defer conn.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"monitor_id": monitor.ID,
+		"endpoint":   endpoint,
+		"latency_ms": latencyMs,
+	}).Info("TCP check successful")
+
+	return "up", latencyMs, ""
+}
+
+func checkDNS(parent context.Context, monitor *models.Monitor) (string, int64, string) {
+	startTime := time.Now()
+
+	// Extract hostname from endpoint
+	hostname := strings.TrimPrefix(monitor.Endpoint, "http://")
+	hostname = strings.TrimPrefix(hostname, "https://")
+	hostname = strings.Split(hostname, "/")[0]
+	hostname = strings.Split(hostname, ":")[0]
+
+	// Create context with timeout
+	timeout := time.Duration(monitor.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("dns.question.name", hostname))
+
+	// Perform DNS lookup
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{
+				Timeout: timeout,
+			}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+
+	_, err := resolver.LookupHost(ctx, hostname)
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"monitor_id": monitor.ID,
+			"hostname":   hostname,
+			"latency_ms": latencyMs,
+			"error":      err.Error(),
+		}).Warn("DNS check failed")
+		return "down", latencyMs, err.Error()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"monitor_id": monitor.ID,
+		"hostname":   hostname,
+		"latency_ms": latencyMs,
+	}).Info("DNS check successful")
+
+	return "up", latencyMs, ""
+}
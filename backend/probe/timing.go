@@ -0,0 +1,130 @@
+package probe
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"runnerx/models"
+)
+
+// timingTrace records the phases of the requests of an HTTP check through
+// httptrace. Callbacks can run concurrently, e.g. when dialing IPv4 and IPv6
+// addresses at once.
+type timingTrace struct {
+	mu     sync.Mutex
+	timing models.CheckTiming
+
+	dnsStart, connectStart, tlsStart time.Time
+	gotConn, firstByte               time.Time
+	// failed is the phase that went wrong, if any
+	failed string
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// Each request of a redirect chain starts over
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn, t.firstByte = time.Time{}, time.Time{}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.DNSMs += time.Since(t.dnsStart).Milliseconds()
+			t.dnsStart = time.Time{}
+			if info.Err != nil {
+				t.failed = models.PhaseDNS
+			}
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// With several addresses the first attempt starts the phase
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil && !t.connectStart.IsZero() {
+				t.timing.ConnectMs += time.Since(t.connectStart).Milliseconds()
+				t.connectStart = time.Time{}
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.TLSMs += time.Since(t.tlsStart).Milliseconds()
+			t.tlsStart = time.Time{}
+			if err != nil {
+				t.failed = models.PhaseTLS
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = time.Now()
+			t.timing.ConnReused = info.Reused
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				t.timing.RemoteIP = host
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.firstByte = time.Now()
+			t.timing.TTFBMs += t.firstByte.Sub(t.gotConn).Milliseconds()
+		},
+	}
+}
+
+// finish completes the timing once the body was read, or the request failed
+// with err, and returns it
+func (t *timingTrace) finish(protocol string, err error) *models.CheckTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	timing := t.timing
+	timing.Protocol = protocol
+	switch {
+	case !t.firstByte.IsZero():
+		timing.TransferMs = now.Sub(t.firstByte).Milliseconds()
+		if err != nil {
+			timing.FailedPhase = models.PhaseTransfer
+		}
+	case err == nil:
+	case t.failed != "":
+		timing.FailedPhase = t.failed
+	case !t.dnsStart.IsZero():
+		timing.FailedPhase = models.PhaseDNS
+		timing.DNSMs += now.Sub(t.dnsStart).Milliseconds()
+	case !t.tlsStart.IsZero():
+		timing.FailedPhase = models.PhaseTLS
+		timing.TLSMs += now.Sub(t.tlsStart).Milliseconds()
+	case !t.gotConn.IsZero():
+		timing.FailedPhase = models.PhaseTTFB
+		timing.TTFBMs += now.Sub(t.gotConn).Milliseconds()
+	default:
+		timing.FailedPhase = models.PhaseConnect
+		if !t.connectStart.IsZero() {
+			timing.ConnectMs += now.Sub(t.connectStart).Milliseconds()
+		}
+	}
+	return &timing
+}
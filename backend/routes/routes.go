@@ -3,36 +3,110 @@ package routes
 import (
     "runnerx/config"
     "runnerx/controllers"
+    "runnerx/middleware"
+    "runnerx/models"
     "runnerx/services"
+    ws "runnerx/websocket"
 
     "github.com/gin-gonic/gin"
     "gorm.io/gorm"
 )
 
-func AuthRoutes(router *gin.RouterGroup, db *gorm.DB) {
-	cfg := config.Load()
-	authController := controllers.NewAuthController(db, cfg.JWTSecret)
+func AuthRoutes(router *gin.RouterGroup, db *gorm.DB, cfg *config.Config, hub *ws.Hub, mailer *services.Mailer, limiter *middleware.RateLimiter) {
+	authController := controllers.NewAuthController(db, cfg.JWTSecret, hub, limiter.Store())
+	authController.OIDC = services.NewOIDCService(db, cfg.OIDC)
+	authController.OIDCFrontendURL = cfg.OIDC.FrontendURL
+	authController.Mailer = mailer
+	authController.AppURL = cfg.AppURL
+	authController.RequireEmailVerification = cfg.RequireEmailVerification
+	accountController := controllers.NewAccountController(db, hub, mailer, cfg.AppURL)
 
-	router.POST("/login", authController.Login)
-	router.POST("/register", authController.Register)
+	limited := limiter.Auth()
+
+	router.POST("/login", limited, limiter.LoginGuard(), authController.Login)
+	router.POST("/login/verify", limited, authController.VerifyLogin)
+	router.POST("/register", limited, limiter.Register(), authController.Register)
+	router.POST("/refresh", authController.Refresh)
+	router.POST("/logout", authController.Logout)
+	router.GET("/oidc", authController.OIDCStatus)
+	router.GET("/oidc/login", authController.OIDCLogin)
+	router.GET("/oidc/callback", authController.OIDCCallback)
+	router.POST("/verify-email", limited, accountController.VerifyEmail)
+	router.POST("/verify-email/resend", limited, accountController.ResendVerification)
+	router.POST("/forgot-password", limited, accountController.ForgotPassword)
+	router.POST("/reset-password", limited, accountController.ResetPassword)
+}
+
+func SessionRoutes(router *gin.RouterGroup, db *gorm.DB, hub *ws.Hub) {
+	sessionController := controllers.NewSessionController(db, hub)
+
+	router.GET("/sessions", sessionController.GetSessions)
+	router.DELETE("/session/:id", sessionController.RevokeSession)
+	router.DELETE("/sessions", sessionController.RevokeAllSessions)
 }
 
-func MonitorRoutes(router *gin.RouterGroup, db *gorm.DB) {
-	monitorController := controllers.NewMonitorController(db)
+func EventRoutes(router *gin.RouterGroup, db *gorm.DB, hub *ws.Hub) {
+	router.GET("/events", middleware.RequireScope(models.ScopeMonitorsRead, models.ScopeMonitorsWrite), ws.HandleEvents(hub, db))
+}
 
-	router.GET("/monitors", monitorController.GetMonitors)
-	router.GET("/monitor/:id", monitorController.GetMonitor)
-    router.GET("/monitor/:id/forecast", monitorController.GetMonitorForecast)
-    router.GET("/monitor/:id/rootcause", monitorController.GetRootCauseTimeline)
-	router.POST("/monitor", monitorController.CreateMonitor)
-	router.POST("/monitor/test", monitorController.TestMonitor)
-	router.PUT("/monitor/:id", monitorController.UpdateMonitor)
-	router.DELETE("/monitor/:id", monitorController.DeleteMonitor)
-	router.PATCH("/monitor/:id/toggle", monitorController.ToggleMonitor)
-	router.GET("/monitor/:id/stats", monitorController.GetMonitorStats)
-	router.GET("/monitor/:id/history", monitorController.GetMonitorHistory)
-    router.GET("/monitor/:id/snapshot", monitorController.GetMonitorSnapshot)
-	router.GET("/monitor/:id/health", monitorController.GetMonitorHealth)
+func MoodRoutes(router *gin.RouterGroup, db *gorm.DB) {
+	moodController := controllers.NewMoodController(db)
+	router.GET("/mood", middleware.RequireScope(models.ScopeMonitorsRead, models.ScopeMonitorsWrite), moodController.GetMood)
+}
+
+func MonitorRoutes(router *gin.RouterGroup, db *gorm.DB, monitorService *services.MonitorService, limiter *middleware.RateLimiter) {
+	monitorController := controllers.NewMonitorController(db, monitorService)
+	// monitors:write includes reading
+	read := middleware.RequireScope(models.ScopeMonitorsRead, models.ScopeMonitorsWrite)
+	write := middleware.RequireScope(models.ScopeMonitorsWrite)
+	edit := middleware.RequirePermission(models.PermissionEdit)
+
+	router.GET("/monitors", read, monitorController.GetMonitors)
+	router.GET("/monitor/:id", read, monitorController.GetMonitor)
+    router.GET("/monitor/:id/forecast", read, monitorController.GetMonitorForecast)
+    router.GET("/monitor/:id/rootcause", read, monitorController.GetRootCauseTimeline)
+	router.POST("/monitor", write, edit, monitorController.CreateMonitor)
+	router.POST("/monitor/test", write, edit, limiter.Expensive(), monitorController.TestMonitor)
+	router.POST("/monitor/import", write, edit, monitorController.ImportMonitors)
+	router.PUT("/monitor/:id", write, edit, monitorController.UpdateMonitor)
+	router.DELETE("/monitor/:id", write, edit, monitorController.DeleteMonitor)
+	router.PATCH("/monitor/:id/toggle", write, edit, monitorController.ToggleMonitor)
+	router.POST("/monitor/:id/heartbeat", middleware.RequireScope(models.ScopeHeartbeatsPush), edit, monitorController.PushHeartbeat)
+	router.GET("/monitor/:id/stats", read, monitorController.GetMonitorStats)
+	router.GET("/monitor/:id/history", read, monitorController.GetMonitorHistory)
+	router.GET("/monitor/:id/timings", read, monitorController.GetMonitorTimings)
+    router.GET("/monitor/:id/snapshot", read, monitorController.GetMonitorSnapshot)
+	router.GET("/monitor/:id/health", read, monitorController.GetMonitorHealth)
+
+	agentController := controllers.NewAgentController(db, monitorService)
+	router.GET("/locations", read, agentController.GetLocations)
+	router.GET("/monitor/:id/locations", read, agentController.GetMonitorLocations)
+}
+
+func MonitorGroupRoutes(router *gin.RouterGroup, db *gorm.DB, groupService *services.MonitorGroupService) {
+	groupController := controllers.NewMonitorGroupController(db, groupService)
+	read := middleware.RequireScope(models.ScopeMonitorsRead, models.ScopeMonitorsWrite)
+	write := middleware.RequireScope(models.ScopeMonitorsWrite)
+	edit := middleware.RequirePermission(models.PermissionEdit)
+
+	router.GET("/monitor-groups", read, groupController.GetGroups)
+	router.POST("/monitor-group", write, edit, groupController.CreateGroup)
+	router.GET("/monitor-group/:id", read, groupController.GetGroup)
+	router.PUT("/monitor-group/:id", write, edit, groupController.UpdateGroup)
+	router.DELETE("/monitor-group/:id", write, edit, groupController.DeleteGroup)
+	router.GET("/monitor-group/:id/history", read, groupController.GetGroupHistory)
+	router.GET("/monitor-group/:id/sla", read, groupController.GetGroupSLA)
+}
+
+func BadgeRoutes(router *gin.RouterGroup, db *gorm.DB) {
+	badgeController := controllers.NewBadgeController(db, nil)
+	read := middleware.RequireScope(models.ScopeMonitorsRead, models.ScopeMonitorsWrite)
+	write := middleware.RequireScope(models.ScopeMonitorsWrite)
+	edit := middleware.RequirePermission(models.PermissionEdit)
+
+	router.GET("/badge-tokens", read, badgeController.GetBadgeTokens)
+	router.POST("/badge-tokens", write, edit, badgeController.CreateBadgeToken)
+	router.DELETE("/badge-token/:id", write, edit, badgeController.DeleteBadgeToken)
 }
 
 func NotificationRoutes(router *gin.RouterGroup, db *gorm.DB) {
@@ -45,12 +119,82 @@ func NotificationRoutes(router *gin.RouterGroup, db *gorm.DB) {
 	router.DELETE("/notification/:id", notificationController.DeleteNotification)
 }
 
-func UserRoutes(router *gin.RouterGroup, db *gorm.DB) {
+func UserRoutes(router *gin.RouterGroup, db *gorm.DB, cfg *config.Config, hub *ws.Hub, mailer *services.Mailer, limiter *middleware.RateLimiter) {
 	userController := controllers.NewUserController(db)
 
 	router.GET("/user/me", userController.GetCurrentUser)
 	router.GET("/user/preferences", userController.GetUserPreferences)
 	router.PUT("/user/preferences", userController.UpdateUserPreferences)
+
+	accountController := controllers.NewAccountController(db, hub, mailer, cfg.AppURL)
+	router.PUT("/user/password", limiter.PasswordGuard(), accountController.ChangePassword)
+	router.GET("/user/export", accountController.ExportAccount)
+	router.DELETE("/user/me", limiter.PasswordGuard(), accountController.DeleteAccount)
+
+	twoFactorController := controllers.NewTwoFactorController(db)
+	router.GET("/user/2fa", twoFactorController.GetStatus)
+	router.POST("/user/2fa/setup", twoFactorController.Setup)
+	router.POST("/user/2fa/enable", twoFactorController.Enable)
+	router.POST("/user/2fa/disable", twoFactorController.Disable)
+	router.POST("/user/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+}
+
+func AdminRoutes(router *gin.RouterGroup, db *gorm.DB, cluster *services.Cluster) {
+	twoFactorController := controllers.NewTwoFactorController(db)
+
+	admin := router.Group("/admin", middleware.RequireAdmin(db))
+	admin.POST("/user/:id/2fa/reset", twoFactorController.ResetUser)
+
+	agentController := controllers.NewAgentController(db, nil)
+	admin.GET("/agents", agentController.GetAgents)
+	admin.POST("/agents", agentController.CreateAgent)
+	admin.DELETE("/agent/:id", agentController.DeleteAgent)
+
+	clusterController := controllers.NewClusterController(db, cluster)
+	admin.GET("/cluster", clusterController.GetCluster)
+}
+
+// AgentRoutes are called by probe agents, which authenticate with their own
+// tokens instead of a user's
+func AgentRoutes(router *gin.RouterGroup, db *gorm.DB, monitorService *services.MonitorService, limiter *middleware.RateLimiter) {
+	agentController := controllers.NewAgentController(db, monitorService)
+
+	agent := router.Group("/agent", middleware.AgentAuth(db), limiter.Agent())
+	agent.POST("/register", agentController.RegisterAgent)
+	agent.GET("/monitors", agentController.GetAgentMonitors)
+	agent.POST("/results", agentController.PostAgentResults)
+}
+
+func AuditRoutes(router *gin.RouterGroup, db *gorm.DB) {
+	auditController := controllers.NewAuditController(db)
+	view := middleware.RequirePermission(models.PermissionViewAudit)
+
+	router.GET("/audit", view, auditController.GetAuditLogs)
+	router.GET("/audit/export", view, auditController.ExportAuditLogs)
+}
+
+func APITokenRoutes(router *gin.RouterGroup, db *gorm.DB) {
+	tokenController := controllers.NewAPITokenController(db)
+
+	router.GET("/tokens", tokenController.GetAPITokens)
+	router.POST("/tokens", tokenController.CreateAPIToken)
+	router.DELETE("/token/:id", tokenController.RevokeAPIToken)
+}
+
+func TeamRoutes(router *gin.RouterGroup, db *gorm.DB) {
+	teamController := controllers.NewTeamController(db)
+
+	router.GET("/teams", teamController.GetTeams)
+	router.POST("/teams", teamController.CreateTeam)
+	router.GET("/team/:id", teamController.GetTeam)
+	router.PUT("/team/:id", teamController.UpdateTeam)
+	router.DELETE("/team/:id", teamController.DeleteTeam)
+	router.PUT("/team/:id/members/:userId", teamController.UpdateMember)
+	router.DELETE("/team/:id/members/:userId", teamController.RemoveMember)
+	router.GET("/team/:id/invitations", teamController.GetInvitations)
+	router.POST("/team/:id/invitations", teamController.CreateInvitation)
+	router.DELETE("/team/:id/invitations/:invitationId", teamController.DeleteInvitation)
+	router.POST("/invitations/accept", teamController.AcceptInvitation)
 }
 
 // Automation routes removed per spec
@@ -62,10 +206,10 @@ func LogsRoutes(router *gin.RouterGroup, db *gorm.DB, svc *services.LogInsightsS
     router.GET("/logs/:incidentId", logsController.GetInsights)
 }
 
-func ScreenshotsRoutes(router *gin.RouterGroup, db *gorm.DB) {
-    sc := controllers.NewScreenshotsController(db)
+func ScreenshotsRoutes(router *gin.RouterGroup, db *gorm.DB, screenshots *services.ScreenshotService, limiter *middleware.RateLimiter) {
+    sc := controllers.NewScreenshotsController(db, screenshots)
     router.GET("/screenshots/:incidentId", sc.GetLatest)
-    router.POST("/screenshots/:incidentId/capture", sc.CaptureLatest)
+    router.POST("/screenshots/:incidentId/capture", middleware.RequirePermission(models.PermissionRespond), limiter.Expensive(), sc.CaptureLatest)
 }
 
 func SnapshotsRoutes(router *gin.RouterGroup, db *gorm.DB) {
@@ -75,23 +219,79 @@ func SnapshotsRoutes(router *gin.RouterGroup, db *gorm.DB) {
 
 func IncidentsRoutes(router *gin.RouterGroup, db *gorm.DB) {
     ic := controllers.NewIncidentsController(db)
-    router.GET("/incidents/:serverId", ic.Get)
-    router.POST("/incidents/summary", ic.GenerateSummary)
+    // incidents:manage includes reading
+    read := middleware.RequireScope(models.ScopeIncidentsRead, models.ScopeIncidentsManage)
+    manage := middleware.RequireScope(models.ScopeIncidentsManage)
+    router.GET("/incidents/:serverId", read, ic.Get)
+    respond := middleware.RequirePermission(models.PermissionRespond)
+    router.POST("/incidents/summary", manage, respond, ic.GenerateSummary)
+    router.POST("/incident/:id/acknowledge", manage, respond, ic.Acknowledge)
 }
 
 func SLARoutes(router *gin.RouterGroup, db *gorm.DB) {
     sc := controllers.NewSLAController(db)
     router.GET("/sla", sc.GetSLAReports)
     router.GET("/sla/:monitorId", sc.GetMonitorSLAReports)
-    router.POST("/sla/generate", sc.GenerateSLAReports)
+    router.POST("/sla/generate", middleware.RequirePermission(models.PermissionEdit), sc.GenerateSLAReports)
 }
 
-func CommandRoutes(router *gin.RouterGroup, db *gorm.DB, commandService *services.CommandService) {
+func CommandRoutes(router *gin.RouterGroup, db *gorm.DB, commandService *services.CommandService, limiter *middleware.RateLimiter) {
     cc := controllers.NewCommandController(db, commandService)
-    router.POST("/commands/execute", cc.ExecuteCommand)
+    router.POST("/commands/execute", middleware.RequirePermission(models.PermissionEdit), limiter.Expensive(), cc.ExecuteCommand)
     router.GET("/commands/history", cc.GetCommandHistory)
     router.GET("/commands/available", cc.GetAvailableCommands)
 }
 
-func PublicRoutes(router *gin.RouterGroup, db *gorm.DB) {}
+func StatusPageRoutes(router *gin.RouterGroup, db *gorm.DB, cfg *config.Config, mailer *services.Mailer) {
+	sc := controllers.NewStatusPageController(db, cfg.JWTSecret, newStatusNotifier(db, cfg, mailer))
+	read := middleware.RequireScope(models.ScopeMonitorsRead, models.ScopeMonitorsWrite)
+	write := middleware.RequireScope(models.ScopeMonitorsWrite)
+	edit := middleware.RequirePermission(models.PermissionEdit)
+
+	router.GET("/status-pages", read, sc.GetStatusPages)
+	router.POST("/status-page", write, edit, sc.CreateStatusPage)
+	router.GET("/status-page/:id", read, sc.GetStatusPage)
+	router.PUT("/status-page/:id", write, edit, sc.UpdateStatusPage)
+	router.DELETE("/status-page/:id", write, edit, sc.DeleteStatusPage)
+	router.POST("/status-page/:id/domain/verify", write, edit, sc.VerifyDomain)
+	router.GET("/status-page/:id/incidents", read, sc.GetStatusIncidents)
+	router.POST("/status-page/:id/incidents", write, edit, sc.CreateStatusIncident)
+	router.POST("/status-page/:id/incidents/:incidentId/updates", write, edit, sc.AddStatusIncidentUpdate)
+	router.DELETE("/status-page/:id/incidents/:incidentId", write, edit, sc.DeleteStatusIncident)
+	router.GET("/status-page/:id/maintenances", read, sc.GetMaintenances)
+	router.POST("/status-page/:id/maintenances", write, edit, sc.CreateMaintenance)
+	router.PUT("/status-page/:id/maintenances/:maintenanceId", write, edit, sc.UpdateMaintenance)
+	router.DELETE("/status-page/:id/maintenances/:maintenanceId", write, edit, sc.DeleteMaintenance)
+	router.GET("/status-page/:id/subscribers", read, sc.GetSubscribers)
+	router.DELETE("/status-page/:id/subscribers/:subscriberId", write, edit, sc.DeleteSubscriber)
+}
+
+// PublicRoutes need no authentication
+func PublicRoutes(router *gin.RouterGroup, db *gorm.DB, cfg *config.Config, mailer *services.Mailer, limiter *middleware.RateLimiter) {
+	sc := controllers.NewStatusPageController(db, cfg.JWTSecret, newStatusNotifier(db, cfg, mailer))
+	limited := limiter.Auth()
+
+	// A page is found by slug, or by the request's Host for custom domains
+	router.GET("/status", sc.GetPublicStatusByDomain)
+	router.POST("/status/unlock", limited, sc.UnlockStatusPage)
+	router.POST("/status/subscribe", limited, sc.Subscribe)
+	router.GET("/status/feed.rss", sc.GetRSSFeed)
+	router.GET("/status/feed.atom", sc.GetAtomFeed)
+	router.GET("/status/maintenance.ics", sc.GetMaintenanceCalendar)
+	router.GET("/status/:slug", sc.GetPublicStatus)
+	router.POST("/status/:slug/unlock", limited, sc.UnlockStatusPage)
+	router.POST("/status/:slug/subscribe", limited, sc.Subscribe)
+	router.GET("/status/:slug/feed.rss", sc.GetRSSFeed)
+	router.GET("/status/:slug/feed.atom", sc.GetAtomFeed)
+	router.GET("/status/:slug/maintenance.ics", sc.GetMaintenanceCalendar)
+	router.POST("/status-subscriptions/confirm", limited, sc.ConfirmSubscription)
+	router.POST("/status-subscriptions/unsubscribe", limited, sc.Unsubscribe)
+
+	badgeController := controllers.NewBadgeController(db, services.NewBadgeService(db))
+	router.GET("/badge/:token/:badge", badgeController.GetBadge)
+}
+
+func newStatusNotifier(db *gorm.DB, cfg *config.Config, mailer *services.Mailer) *services.StatusNotifier {
+	return services.NewStatusNotifier(db, mailer, cfg.AppURL, cfg.JWTSecret, cfg.AllowPrivateWebhooks)
+}
 
@@ -0,0 +1,206 @@
+// Package server serves the API over HTTPS: certificates from files, reloaded
+// when they change, or from an ACME certificate authority, and a plain HTTP
+// listener redirecting to HTTPS.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"runnerx/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// How often certificate files are checked for changes
+const certPollInterval = 30 * time.Second
+
+// TLS is the HTTPS setup of the server
+type TLS struct {
+	// Config is the server side TLS configuration of the HTTPS listener
+	Config *tls.Config
+	// HTTPHandler serves the plain HTTP listener: ACME challenges, and
+	// redirects to HTTPS for everything else
+	HTTPHandler http.Handler
+
+	certs *certFiles
+}
+
+// HostPolicy reports whether ACME may also issue a certificate for host, beyond
+// the configured domains
+type HostPolicy func(ctx context.Context, host string) bool
+
+// NewTLS prepares HTTPS for cfg, served on httpsPort. With ACME, certificates
+// are issued for the configured domains and the hosts allowed by extraHosts,
+// which may be nil. It fails if the certificate files cannot be loaded.
+func NewTLS(cfg config.TLSConfig, httpsPort string, extraHosts HostPolicy) (*TLS, error) {
+	redirect := redirectHandler(httpsPort)
+
+	if cfg.ACME.Enabled() {
+		client := &acme.Client{DirectoryURL: cfg.ACME.DirectoryURL}
+		if cfg.ACME.CAFile != "" {
+			httpClient, err := trustingClient(cfg.ACME.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			client.HTTPClient = httpClient
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: hostPolicy(cfg.ACME.Domains, extraHosts),
+			Cache:      autocert.DirCache(cfg.ACME.CacheDir),
+			Email:      cfg.ACME.Email,
+			Client:     client,
+		}
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return &TLS{Config: tlsConfig, HTTPHandler: manager.HTTPHandler(redirect)}, nil
+	}
+
+	certs := &certFiles{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+	if err := certs.load(); err != nil {
+		return nil, err
+	}
+	return &TLS{
+		Config: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.get,
+		},
+		HTTPHandler: redirect,
+		certs:       certs,
+	}, nil
+}
+
+// Reload reads the certificate files again. A pair that fails to load is
+// reported and the current certificate kept. Nothing to do with ACME, which
+// renews on its own.
+func (t *TLS) Reload() error {
+	if t == nil || t.certs == nil {
+		return nil
+	}
+	return t.certs.load()
+}
+
+// Watch reloads the certificate files whenever they change, until ctx is
+// cancelled, so renewed certificates are picked up without a restart
+func (t *TLS) Watch(ctx context.Context) {
+	if t == nil || t.certs == nil {
+		return
+	}
+	ticker := time.NewTicker(certPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if t.certs.changed() {
+			if err := t.certs.load(); err != nil {
+				log.Printf("TLS: %v", err)
+			}
+		}
+	}
+}
+
+// certFiles is a certificate and key read from files
+type certFiles struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (c *certFiles) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *certFiles) load() error {
+	modTime := c.latestModTime()
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %v", c.certFile, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reloaded := c.cert != nil
+	c.cert, c.modTime = &cert, modTime
+	if reloaded {
+		log.Printf("TLS: reloaded certificate %s", c.certFile)
+	}
+	return nil
+}
+
+// changed reports whether either file was modified since it was loaded
+func (c *certFiles) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latestModTime().After(c.modTime)
+}
+
+func (c *certFiles) latestModTime() time.Time {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(name); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// hostPolicy accepts the configured domains and the hosts extraHosts allows
+func hostPolicy(domains []string, extraHosts HostPolicy) autocert.HostPolicy {
+	whitelist := autocert.HostWhitelist(domains...)
+	return func(ctx context.Context, host string) error {
+		err := whitelist(ctx, host)
+		if err != nil && extraHosts != nil && extraHosts(ctx, host) {
+			return nil
+		}
+		return err
+	}
+}
+
+// trustingClient is an HTTP client that also trusts the certificates in caFile
+func trustingClient(caFile string) (*http.Client, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, nil
+}
+
+// redirectHandler sends requests to the same URL over HTTPS
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"runnerx/models"
+
+	"gorm.io/gorm"
+)
+
+// AccountService deletes and exports everything that belongs to a user
+type AccountService struct {
+	db *gorm.DB
+}
+
+// AccountExport is the JSON document returned by a full account export. It
+// covers the user's personal workspace; team data belongs to the team.
+type AccountExport struct {
+	ExportedAt      time.Time                   `json:"exported_at"`
+	User            *models.User                `json:"user"`
+	Preferences     *models.UserPreferences     `json:"preferences,omitempty"`
+	Monitors        []models.Monitor            `json:"monitors"`
+	MonitorGroups   []models.MonitorGroup       `json:"monitor_groups"`
+	GroupMembers    []models.MonitorGroupMember `json:"monitor_group_members"`
+	Checks          []models.Check              `json:"checks"`
+	Incidents       []models.Incident           `json:"incidents"`
+	IncidentEvents  []models.IncidentEvent      `json:"incident_events"`
+	SLAReports      []models.SLAReport          `json:"sla_reports"`
+	Notifications   []models.Notification       `json:"notifications"`
+	CommandLogs     []models.CommandLog         `json:"command_logs"`
+	LogInsights     []models.LogInsight         `json:"log_insights"`
+	Screenshots     []models.IncidentScreenshot `json:"screenshots"`
+	APITokens       []models.APIToken           `json:"api_tokens"`
+	Sessions        []models.Session            `json:"sessions"`
+	TeamMemberships []models.TeamMembership     `json:"team_memberships"`
+	BadgeTokens     []models.BadgeToken         `json:"badge_tokens"`
+	AuditEntries    []models.AuditLog           `json:"audit_entries"`
+
+	StatusPages           []models.StatusPage               `json:"status_pages"`
+	StatusPageItems       []models.StatusPageItem           `json:"status_page_items"`
+	StatusPageIncidents   []models.StatusPageIncident       `json:"status_page_incidents"`
+	StatusPageUpdates     []models.StatusPageIncidentUpdate `json:"status_page_incident_updates"`
+	StatusPageMaintenance []models.StatusPageMaintenance    `json:"status_page_maintenance"`
+	StatusPageSubscribers []models.StatusPageSubscriber     `json:"status_page_subscribers"`
+}
+
+func NewAccountService(db *gorm.DB) *AccountService {
+	return &AccountService{db: db}
+}
+
+// personalMonitorIDs returns the IDs of the user's monitors outside any team,
+// including soft-deleted ones
+func personalMonitorIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Unscoped().Model(&models.Monitor{}).Where("user_id = ? AND team_id IS NULL", userID).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// SoleOwnedTeams returns the teams in which the user is the only owner. The
+// account cannot be deleted until ownership of these is transferred.
+func (s *AccountService) SoleOwnedTeams(userID uint) ([]uint, error) {
+	var teamIDs []uint
+	err := s.db.Model(&models.TeamMembership{}).
+		Where("user_id = ? AND role = ?", userID, models.RoleOwner).
+		Where("(SELECT COUNT(*) FROM team_memberships AS owners WHERE owners.team_id = team_memberships.team_id AND owners.role = ?) = 1", models.RoleOwner).
+		Pluck("team_id", &teamIDs).Error
+	return teamIDs, err
+}
+
+// DeleteAccount removes the user and their personal workspace: monitors with
+// their checks, incidents, reports, logs and screenshots, monitor groups,
+// status pages, plus notifications, tokens and sessions. Monitors, groups and
+// pages the user created in a team stay with the team and are handed to one
+// of its remaining owners.
+func (s *AccountService) DeleteAccount(userID uint) error {
+	var screenshots []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		monitorIDs, err := personalMonitorIDs(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if len(monitorIDs) > 0 {
+			var incidentIDs []uint
+			if err := tx.Unscoped().Model(&models.Incident{}).Where("monitor_id IN ?", monitorIDs).Pluck("id", &incidentIDs).Error; err != nil {
+				return err
+			}
+			if len(incidentIDs) > 0 {
+				if err := tx.Where("incident_id IN ?", incidentIDs).Delete(&models.IncidentEvent{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Unscoped().Where("incident_id IN ?", incidentIDs).Delete(&models.IncidentAISummary{}).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Model(&models.IncidentScreenshot{}).Where("monitor_id IN ?", monitorIDs).Pluck("path", &screenshots).Error; err != nil {
+				return err
+			}
+
+			for _, model := range []interface{}{
+				&models.Check{}, &models.Incident{}, &models.IncidentScreenshot{}, &models.LogInsight{},
+				&models.SLAReport{}, &models.MonitorForecast{}, &models.PerformanceSnapshot{},
+				&models.MonitorLocationStatus{},
+			} {
+				if err := tx.Unscoped().Where("monitor_id IN ?", monitorIDs).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("kind = ? AND subject_id IN ?", models.RollupMonitor, monitorIDs).Delete(&models.UptimeRollup{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", monitorIDs).Delete(&models.Monitor{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := deletePersonalGroups(tx, userID, monitorIDs); err != nil {
+			return err
+		}
+
+		var pageIDs []uint
+		if err := tx.Unscoped().Model(&models.StatusPage{}).Where("user_id = ? AND team_id IS NULL", userID).Pluck("id", &pageIDs).Error; err != nil {
+			return err
+		}
+		if err := DeleteStatusPages(tx, pageIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND team_id IS NULL", userID).Delete(&models.BadgeToken{}).Error; err != nil {
+			return err
+		}
+
+		if err := s.transferTeamResources(tx, userID); err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.Notification{}, &models.CommandLog{}, &models.UserPreferences{}, &models.APIToken{},
+			&models.Session{}, &models.RecoveryCode{}, &models.UserToken{}, &models.TeamMembership{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// Hard delete so the email address can be registered again
+		return tx.Unscoped().Delete(&models.User{}, userID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range screenshots {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove screenshot %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// deletePersonalGroups removes the user's personal monitor groups with their
+// history and reports, and takes the deleted monitors out of any group
+func deletePersonalGroups(tx *gorm.DB, userID uint, monitorIDs []uint) error {
+	var groupIDs []uint
+	if err := tx.Unscoped().Model(&models.MonitorGroup{}).Where("user_id = ? AND team_id IS NULL", userID).Pluck("id", &groupIDs).Error; err != nil {
+		return err
+	}
+	if len(monitorIDs) > 0 {
+		if err := tx.Where("monitor_id IN ?", monitorIDs).Delete(&models.MonitorGroupMember{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(groupIDs) == 0 {
+		return nil
+	}
+
+	if err := tx.Where("group_id IN ? OR child_group_id IN ?", groupIDs, groupIDs).Delete(&models.MonitorGroupMember{}).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&models.MonitorGroupCheck{}, &models.SLAReport{}} {
+		if err := tx.Unscoped().Where("group_id IN ?", groupIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("kind = ? AND subject_id IN ?", models.RollupGroup, groupIDs).Delete(&models.UptimeRollup{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", groupIDs).Delete(&models.MonitorGroup{}).Error
+}
+
+// transferTeamResources reassigns team resources created by the user to an
+// owner of the team, so nothing references the deleted account
+func (s *AccountService) transferTeamResources(tx *gorm.DB, userID uint) error {
+	var teamIDs []uint
+	if err := tx.Model(&models.TeamMembership{}).Where("user_id = ?", userID).Pluck("team_id", &teamIDs).Error; err != nil {
+		return err
+	}
+
+	for _, teamID := range teamIDs {
+		var owner models.TeamMembership
+		err := tx.Where("team_id = ? AND role = ? AND user_id <> ?", teamID, models.RoleOwner, userID).
+			Order("created_at").First(&owner).Error
+		if err != nil {
+			return err
+		}
+
+		var monitorIDs []uint
+		if err := tx.Unscoped().Model(&models.Monitor{}).Where("user_id = ? AND team_id = ?", userID, teamID).Pluck("id", &monitorIDs).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.Monitor{}, &models.MonitorGroup{}, &models.StatusPage{}, &models.BadgeToken{}, &models.Incident{}, &models.SLAReport{}} {
+			if err := tx.Unscoped().Model(model).Where("user_id = ? AND team_id = ?", userID, teamID).Update("user_id", owner.UserID).Error; err != nil {
+				return err
+			}
+		}
+		// Logs and screenshots are looked up through the monitor's owner
+		if len(monitorIDs) > 0 {
+			for _, model := range []interface{}{&models.LogInsight{}, &models.IncidentScreenshot{}} {
+				if err := tx.Unscoped().Model(model).Where("monitor_id IN ?", monitorIDs).Update("user_id", owner.UserID).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return tx.Model(&models.TeamInvitation{}).Where("invited_by_id = ? AND accepted_at IS NULL", userID).
+		Delete(&models.TeamInvitation{}).Error
+}
+
+// ExportAccount collects the user's data into a single document
+func (s *AccountService) ExportAccount(userID uint) (*AccountExport, error) {
+	export := &AccountExport{ExportedAt: time.Now()}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	export.User = &user
+
+	var preferences models.UserPreferences
+	if err := s.db.Where("user_id = ?", userID).First(&preferences).Error; err == nil {
+		export.Preferences = &preferences
+	}
+
+	monitorIDs, err := personalMonitorIDs(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	var groupIDs, pageIDs []uint
+	if err := s.db.Model(&models.MonitorGroup{}).Where("user_id = ? AND team_id IS NULL", userID).Pluck("id", &groupIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.StatusPage{}).Where("user_id = ? AND team_id IS NULL", userID).Pluck("id", &pageIDs).Error; err != nil {
+		return nil, err
+	}
+
+	queries := []struct {
+		dest  interface{}
+		query string
+		arg   interface{}
+	}{
+		{&export.Monitors, "id IN ?", monitorIDs},
+		{&export.MonitorGroups, "id IN ?", groupIDs},
+		{&export.GroupMembers, "group_id IN ?", groupIDs},
+		{&export.Checks, "monitor_id IN ?", monitorIDs},
+		{&export.Incidents, "monitor_id IN ?", monitorIDs},
+		{&export.SLAReports, "monitor_id IN ?", monitorIDs},
+		{&export.LogInsights, "monitor_id IN ?", monitorIDs},
+		{&export.Screenshots, "monitor_id IN ?", monitorIDs},
+		{&export.Notifications, "user_id = ?", userID},
+		{&export.CommandLogs, "user_id = ?", userID},
+		{&export.APITokens, "user_id = ?", userID},
+		{&export.Sessions, "user_id = ?", userID},
+		{&export.TeamMemberships, "user_id = ?", userID},
+		{&export.BadgeTokens, "user_id = ? AND team_id IS NULL", userID},
+		{&export.AuditEntries, "user_id = ?", userID},
+		{&export.StatusPages, "id IN ?", pageIDs},
+		{&export.StatusPageItems, "status_page_id IN ?", pageIDs},
+		{&export.StatusPageIncidents, "status_page_id IN ?", pageIDs},
+		{&export.StatusPageMaintenance, "status_page_id IN ?", pageIDs},
+		{&export.StatusPageSubscribers, "status_page_id IN ?", pageIDs},
+	}
+	for _, q := range queries {
+		if err := s.db.Where(q.query, q.arg).Order("id").Find(q.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	incidentIDs := make([]uint, len(export.Incidents))
+	for i, incident := range export.Incidents {
+		incidentIDs[i] = incident.ID
+	}
+	if err := s.db.Where("incident_id IN ?", incidentIDs).Order("id").Find(&export.IncidentEvents).Error; err != nil {
+		return nil, err
+	}
+
+	pageIncidentIDs := make([]uint, len(export.StatusPageIncidents))
+	for i, incident := range export.StatusPageIncidents {
+		pageIncidentIDs[i] = incident.ID
+	}
+	if err := s.db.Where("incident_id IN ?", pageIncidentIDs).Order("id").Find(&export.StatusPageUpdates).Error; err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
@@ -1,11 +1,13 @@
 package services
 
 import (
+	"context"
 	"log"
 	"math"
     "sort"
 	"runnerx/models"
 	ws "runnerx/websocket"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,19 +17,37 @@ import (
 type AnalyticsService struct {
 	db  *gorm.DB
 	hub *ws.Hub
+
+	mu       sync.RWMutex
+	interval time.Duration
+}
+
+func NewAnalyticsService(db *gorm.DB, hub *ws.Hub, interval time.Duration) *AnalyticsService {
+	return &AnalyticsService{db: db, hub: hub, interval: interval}
 }
 
-func NewAnalyticsService(db *gorm.DB, hub *ws.Hub) *AnalyticsService {
-	return &AnalyticsService{db: db, hub: hub}
+// SetInterval changes the time until the next and later runs
+func (as *AnalyticsService) SetInterval(interval time.Duration) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	as.interval = interval
 }
 
-// StartHourly runs analysis every hour
-func (as *AnalyticsService) StartHourly() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
+// Start runs analysis on the cluster's leader every interval, hourly by
+// default, until ctx is cancelled
+func (as *AnalyticsService) Start(ctx context.Context, cluster *Cluster) {
 	for {
-		as.runOnce()
-		<-ticker.C
+		if cluster.IsLeader() {
+			as.runOnce()
+		}
+		as.mu.RLock()
+		interval := as.interval
+		as.mu.RUnlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
 	}
 }
 
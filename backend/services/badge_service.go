@@ -0,0 +1,350 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"runnerx/models"
+
+	"gorm.io/gorm"
+)
+
+// Badge kinds
+const (
+	BadgeStatus   = "status"
+	BadgeUptime   = "uptime"
+	BadgeResponse = "response"
+	BadgeCert     = "cert"
+)
+
+// BadgeCacheTTL is how long a rendered badge is served before being drawn again
+const BadgeCacheTTL = time.Minute
+
+const badgeCacheLimit = 10000
+
+// BadgePeriods are the periods accepted by the uptime and response badges
+var BadgePeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// ErrBadgeNotFound is returned for unknown tokens and deleted subjects
+var ErrBadgeNotFound = errors.New("badge not found")
+
+// BadgeRequest describes one badge
+type BadgeRequest struct {
+	Token  string
+	Kind   string
+	Period string
+	Style  string
+	Label  string
+}
+
+type cachedBadge struct {
+	svg     []byte
+	expires time.Time
+}
+
+// BadgeService draws the SVG badges of monitors and groups from the hourly
+// rollups, keeping each rendered badge for BadgeCacheTTL
+type BadgeService struct {
+	db    *gorm.DB
+	mu    sync.Mutex
+	cache map[string]cachedBadge
+}
+
+func NewBadgeService(db *gorm.DB) *BadgeService {
+	return &BadgeService{db: db, cache: make(map[string]cachedBadge)}
+}
+
+// Render returns the badge as SVG
+func (s *BadgeService) Render(req BadgeRequest) ([]byte, error) {
+	hash := models.HashToken(req.Token)
+	key := strings.Join([]string{hash, req.Kind, req.Period, req.Style, req.Label}, "|")
+
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.svg, nil
+	}
+
+	var token models.BadgeToken
+	if err := s.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, ErrBadgeNotFound
+	}
+
+	label, value, color, err := s.badgeValue(&token, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Label != "" {
+		label = req.Label
+	}
+	svg := RenderBadgeSVG(label, value, color, req.Style)
+
+	s.mu.Lock()
+	if len(s.cache) >= badgeCacheLimit {
+		s.evictExpired()
+	}
+	s.cache[key] = cachedBadge{svg: svg, expires: time.Now().Add(BadgeCacheTTL)}
+	s.mu.Unlock()
+	return svg, nil
+}
+
+// evictExpired drops stale badges, or everything if the cache is still full
+func (s *BadgeService) evictExpired() {
+	now := time.Now()
+	for key, badge := range s.cache {
+		if now.After(badge.expires) {
+			delete(s.cache, key)
+		}
+	}
+	if len(s.cache) >= badgeCacheLimit {
+		s.cache = make(map[string]cachedBadge)
+	}
+}
+
+func (s *BadgeService) badgeValue(token *models.BadgeToken, req BadgeRequest) (string, string, string, error) {
+	period := BadgePeriods[req.Period]
+
+	if token.MonitorID != nil {
+		var monitor models.Monitor
+		if err := s.db.First(&monitor, *token.MonitorID).Error; err != nil {
+			return "", "", "", ErrBadgeNotFound
+		}
+		switch req.Kind {
+		case BadgeStatus:
+			status := monitor.Status
+			if !monitor.Enabled {
+				status = "paused"
+			}
+			return "status", status, statusColor(status), nil
+		case BadgeUptime:
+			return s.uptimeBadge(models.RollupMonitor, []uint{monitor.ID}, req.Period, period)
+		case BadgeResponse:
+			return s.responseBadge([]uint{monitor.ID}, req.Period, period)
+		case BadgeCert:
+			return certBadge(monitor.CertExpiresAt)
+		}
+	}
+
+	if token.GroupID != nil {
+		var group models.MonitorGroup
+		if err := s.db.First(&group, *token.GroupID).Error; err != nil {
+			return "", "", "", ErrBadgeNotFound
+		}
+		switch req.Kind {
+		case BadgeStatus:
+			return "status", group.Status, statusColor(group.Status), nil
+		case BadgeUptime:
+			return s.uptimeBadge(models.RollupGroup, []uint{group.ID}, req.Period, period)
+		case BadgeResponse:
+			monitorIDs, err := models.GroupMonitorIDs(s.db, group.ID)
+			if err != nil {
+				return "", "", "", err
+			}
+			return s.responseBadge(monitorIDs, req.Period, period)
+		case BadgeCert:
+			return "cert", "n/a", badgeGrey, nil
+		}
+	}
+	return "", "", "", ErrBadgeNotFound
+}
+
+type rollupTotals struct {
+	Checks       int64
+	UpChecks     int64
+	LatencySumMs int64
+}
+
+func (s *BadgeService) totals(kind string, ids []uint, period time.Duration) (rollupTotals, error) {
+	var totals rollupTotals
+	if len(ids) == 0 {
+		return totals, nil
+	}
+	since := time.Now().UTC().Add(-period).Truncate(time.Hour)
+	err := s.db.Model(&models.UptimeRollup{}).
+		Select("COALESCE(SUM(checks), 0) AS checks, COALESCE(SUM(up_checks), 0) AS up_checks, COALESCE(SUM(latency_sum_ms), 0) AS latency_sum_ms").
+		Where("kind = ? AND subject_id IN ? AND hour >= ?", kind, ids, since).
+		Scan(&totals).Error
+	return totals, err
+}
+
+func (s *BadgeService) uptimeBadge(kind string, ids []uint, name string, period time.Duration) (string, string, string, error) {
+	label := "uptime " + name
+	totals, err := s.totals(kind, ids, period)
+	if err != nil {
+		return "", "", "", err
+	}
+	if totals.Checks == 0 {
+		return label, "no data", badgeGrey, nil
+	}
+
+	uptime := float64(totals.UpChecks) / float64(totals.Checks) * 100
+	value := fmt.Sprintf("%.2f%%", uptime)
+	if totals.UpChecks == totals.Checks {
+		value = "100%"
+	}
+	return label, value, uptimeColor(uptime), nil
+}
+
+func (s *BadgeService) responseBadge(monitorIDs []uint, name string, period time.Duration) (string, string, string, error) {
+	label := "response " + name
+	totals, err := s.totals(models.RollupMonitor, monitorIDs, period)
+	if err != nil {
+		return "", "", "", err
+	}
+	if totals.UpChecks == 0 {
+		return label, "no data", badgeGrey, nil
+	}
+
+	avg := float64(totals.LatencySumMs) / float64(totals.UpChecks)
+	value := fmt.Sprintf("%.0fms", avg)
+	if avg >= 1000 {
+		value = fmt.Sprintf("%.2fs", avg/1000)
+	}
+	return label, value, responseColor(avg), nil
+}
+
+func certBadge(expiresAt *time.Time) (string, string, string, error) {
+	if expiresAt == nil {
+		return "cert", "unknown", badgeGrey, nil
+	}
+	days := int(time.Until(*expiresAt).Hours() / 24)
+	switch {
+	case time.Now().After(*expiresAt):
+		return "cert", "expired", badgeRed, nil
+	case days < 7:
+		return "cert", fmt.Sprintf("%d days", days), badgeRed, nil
+	case days < 14:
+		return "cert", fmt.Sprintf("%d days", days), badgeOrange, nil
+	case days < 30:
+		return "cert", fmt.Sprintf("%d days", days), badgeYellow, nil
+	}
+	return "cert", fmt.Sprintf("%d days", days), badgeBrightGreen, nil
+}
+
+const (
+	badgeBrightGreen = "#4c1"
+	badgeGreen       = "#97ca00"
+	badgeYellow      = "#dfb317"
+	badgeOrange      = "#fe7d37"
+	badgeRed         = "#e05d44"
+	badgeGrey        = "#9f9f9f"
+)
+
+func statusColor(status string) string {
+	switch status {
+	case "up":
+		return badgeBrightGreen
+	case models.GroupStatusDegraded:
+		return badgeYellow
+	case "down":
+		return badgeRed
+	}
+	return badgeGrey
+}
+
+func uptimeColor(uptime float64) string {
+	switch {
+	case uptime >= 99.9:
+		return badgeBrightGreen
+	case uptime >= 99:
+		return badgeGreen
+	case uptime >= 97:
+		return badgeYellow
+	case uptime >= 95:
+		return badgeOrange
+	}
+	return badgeRed
+}
+
+func responseColor(ms float64) string {
+	switch {
+	case ms < 300:
+		return badgeBrightGreen
+	case ms < 800:
+		return badgeGreen
+	case ms < 1500:
+		return badgeYellow
+	case ms < 3000:
+		return badgeOrange
+	}
+	return badgeRed
+}
+
+// StartRollupBackfill fills the hourly rollups from the existing check
+// history the first time the server runs with them. Checks recorded from now
+// on update the rollups directly.
+func StartRollupBackfill(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.UptimeRollup{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	until := time.Now()
+	since := until.Add(-models.RollupRetention)
+
+	go func() {
+		type bucket struct{ checks, up, latency int64 }
+		monitors := map[uint]map[time.Time]*bucket{}
+		var batch []models.Check
+		err := db.Select("id", "monitor_id", "status", "latency_ms", "created_at").
+			Where("created_at >= ? AND created_at < ?", since, until).
+			FindInBatches(&batch, 1000, func(*gorm.DB, int) error {
+				for _, check := range batch {
+					hours := monitors[check.MonitorID]
+					if hours == nil {
+						hours = map[time.Time]*bucket{}
+						monitors[check.MonitorID] = hours
+					}
+					hour := check.CreatedAt.UTC().Truncate(time.Hour)
+					b := hours[hour]
+					if b == nil {
+						b = &bucket{}
+						hours[hour] = b
+					}
+					b.checks++
+					if check.Status == "up" {
+						b.up++
+						b.latency += check.LatencyMs
+					}
+				}
+				return nil
+			}).Error
+		if err != nil {
+			log.Printf("Failed to read checks for uptime rollups: %v", err)
+			return
+		}
+		for monitorID, hours := range monitors {
+			for hour, b := range hours {
+				if err := models.AddToRollup(db, models.RollupMonitor, monitorID, hour, b.checks, b.up, b.latency); err != nil {
+					log.Printf("Failed to backfill uptime rollups: %v", err)
+					return
+				}
+			}
+		}
+
+		var history []models.MonitorGroupCheck
+		if err := db.Where("created_at >= ? AND created_at < ?", since, until).Find(&history).Error; err != nil {
+			log.Printf("Failed to read group history for uptime rollups: %v", err)
+			return
+		}
+		for _, entry := range history {
+			var up int64
+			if entry.Status != models.GroupStatusDown {
+				up = 1
+			}
+			if err := models.AddToRollup(db, models.RollupGroup, entry.GroupID, entry.CreatedAt, 1, up, 0); err != nil {
+				log.Printf("Failed to backfill uptime rollups: %v", err)
+				return
+			}
+		}
+		log.Printf("Backfilled uptime rollups of %d monitors", len(monitors))
+	}()
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Badge styles, after the ones of shields.io
+const (
+	BadgeStyleFlat        = "flat"
+	BadgeStyleFlatSquare  = "flat-square"
+	BadgeStylePlastic     = "plastic"
+	BadgeStyleForTheBadge = "for-the-badge"
+)
+
+// IsValidBadgeStyle reports whether style is a known badge style
+func IsValidBadgeStyle(style string) bool {
+	switch style {
+	case BadgeStyleFlat, BadgeStyleFlatSquare, BadgeStylePlastic, BadgeStyleForTheBadge:
+		return true
+	}
+	return false
+}
+
+// textWidth estimates the width of text in 11px Verdana, which is close
+// enough to centre it without measuring fonts
+func textWidth(text string, perChar float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune("ijlt.,:;|!' ", r):
+			width += perChar * 0.55
+		case strings.ContainsRune("mwMW@%", r):
+			width += perChar * 1.45
+		case r >= 'A' && r <= 'Z':
+			width += perChar * 1.15
+		default:
+			width += perChar
+		}
+	}
+	return width
+}
+
+// RenderBadgeSVG draws a two-part badge: a grey label and a coloured value
+func RenderBadgeSVG(label, value, color, style string) []byte {
+	height, fontSize, perChar, padding := 20.0, 11, 6.5, 6.0
+	radius := "3"
+	if style == BadgeStyleFlatSquare {
+		radius = "0"
+	}
+	if style == BadgeStyleForTheBadge {
+		label, value = strings.ToUpper(label), strings.ToUpper(value)
+		height, fontSize, perChar, padding, radius = 28, 10, 7.5, 12, "0"
+	}
+
+	labelWidth := textWidth(label, perChar) + 2*padding
+	valueWidth := textWidth(value, perChar) + 2*padding
+	width := labelWidth + valueWidth
+	textY := height/2 + float64(fontSize)/2 - 1
+
+	l, v := html.EscapeString(label), html.EscapeString(value)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" role="img" aria-label="%s: %s">`, width, height, l, v)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, l, v)
+	switch style {
+	case BadgeStyleFlat, "":
+		b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	case BadgeStylePlastic:
+		b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#fff" stop-opacity=".7"/><stop offset=".1" stop-color="#aaa" stop-opacity=".1"/><stop offset=".9" stop-opacity=".3"/><stop offset="1" stop-opacity=".5"/></linearGradient>`)
+	}
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%.0f" height="%.0f" rx="%s" fill="#fff"/></clipPath>`, width, height, radius)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%.0f" height="%.0f" fill="#555"/><rect x="%.0f" width="%.0f" height="%.0f" fill="%s"/>`,
+		labelWidth, height, labelWidth, valueWidth, height, color)
+	if style != BadgeStyleFlatSquare && style != BadgeStyleForTheBadge {
+		fmt.Fprintf(&b, `<rect width="%.0f" height="%.0f" fill="url(#s)"/>`, width, height)
+	}
+	b.WriteString(`</g>`)
+
+	weight := ""
+	if style == BadgeStyleForTheBadge {
+		weight = ` font-weight="bold" letter-spacing="1"`
+	}
+	fmt.Fprintf(&b, `<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="%d"%s>`, fontSize, weight)
+	for _, part := range []struct {
+		x    float64
+		text string
+	}{{labelWidth / 2, l}, {labelWidth + valueWidth/2, v}} {
+		if style != BadgeStyleForTheBadge {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="#010101" fill-opacity=".3">%s</text>`, part.x, textY+1, part.text)
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f">%s</text>`, part.x, textY, part.text)
+	}
+	b.WriteString(`</g></svg>`)
+	return b.Bytes()
+}
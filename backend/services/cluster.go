@@ -0,0 +1,260 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"runnerx/config"
+	"runnerx/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LeaderLease is the lease whose holder runs the singleton jobs: reports,
+// analytics, mood broadcasts and cleanups
+const LeaderLease = "leader"
+
+// Points per member on the hash ring; more points spread monitors more evenly
+const ringReplicas = 128
+
+type ringPoint struct {
+	hash   uint64
+	member string
+}
+
+// Cluster coordinates instances sharing the database. Members heartbeat into
+// cluster_members; monitors are sharded over the live members with a
+// consistent hash ring, so a member joining or leaving only moves its own
+// share, and one member holds the leader lease for the singleton jobs.
+//
+// A nil Cluster is a single instance that owns everything and always leads.
+type Cluster struct {
+	db       *gorm.DB
+	cfg      config.ClusterConfig
+	hostname string
+	started  time.Time
+
+	mu          sync.RWMutex
+	members     []string
+	ring        []ringPoint
+	leaderUntil time.Time
+
+	stop    chan struct{}
+	running sync.WaitGroup
+	once    sync.Once
+}
+
+func NewCluster(db *gorm.DB, cfg config.ClusterConfig) *Cluster {
+	hostname, _ := os.Hostname()
+	return &Cluster{
+		db:       db,
+		cfg:      cfg,
+		hostname: hostname,
+		started:  time.Now(),
+		stop:     make(chan struct{}),
+	}
+}
+
+// ID names this instance
+func (c *Cluster) ID() string {
+	return c.cfg.InstanceID
+}
+
+// Start joins the cluster and keeps heartbeating until Leave. The first
+// heartbeat runs before it returns, so ownership is known from the start.
+func (c *Cluster) Start() {
+	c.heartbeat()
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		ticker := time.NewTicker(c.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.heartbeat()
+			}
+		}
+	}()
+}
+
+// Leave stops heartbeating, gives up the leader lease and removes the
+// instance, so the others take over its monitors on their next heartbeat
+// instead of waiting for it to expire
+func (c *Cluster) Leave() {
+	if c == nil {
+		return
+	}
+	c.once.Do(func() {
+		close(c.stop)
+		c.running.Wait()
+		c.mu.Lock()
+		c.leaderUntil = time.Time{}
+		c.mu.Unlock()
+		if err := c.db.Where("name = ? AND holder = ?", LeaderLease, c.ID()).Delete(&models.ClusterLease{}).Error; err != nil {
+			log.Printf("Cluster: failed to release the leader lease: %v", err)
+		}
+		if err := c.db.Where("id = ?", c.ID()).Delete(&models.ClusterMember{}).Error; err != nil {
+			log.Printf("Cluster: failed to leave: %v", err)
+		}
+		log.Printf("Cluster: instance %s left", c.ID())
+	})
+}
+
+// IsLeader reports whether this instance holds the leader lease. Leadership
+// lapses with the lease if it cannot be renewed, so two instances never lead
+// at once while their clocks agree.
+func (c *Cluster) IsLeader() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Now().Before(c.leaderUntil)
+}
+
+// Owns reports whether this instance checks the monitor
+func (c *Cluster) Owns(monitorID uint) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.ring) == 0 {
+		// Not joined yet or the database is unreachable: keep checking
+		return true
+	}
+	return c.ownerLocked(monitorID) == c.ID()
+}
+
+// Members returns the live instances
+func (c *Cluster) Members() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.members...)
+}
+
+// Owners returns the instance checking each of the monitors
+func (c *Cluster) Owners(monitorIDs []uint) map[uint]string {
+	owners := make(map[uint]string, len(monitorIDs))
+	if c == nil {
+		return owners
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.ring) == 0 {
+		return owners
+	}
+	for _, id := range monitorIDs {
+		owners[id] = c.ownerLocked(id)
+	}
+	return owners
+}
+
+func (c *Cluster) ownerLocked(monitorID uint) string {
+	h := ringHash(strconv.FormatUint(uint64(monitorID), 10))
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i].hash >= h })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.ring[i].member
+}
+
+// ringHash spreads keys over the ring. Monitor IDs are short and similar,
+// which simple hashes like FNV leave clustered on one arc.
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// heartbeat refreshes the instance, renews or takes the leader lease and
+// rebuilds the ring when members joined or left
+func (c *Cluster) heartbeat() {
+	now := time.Now()
+	member := models.ClusterMember{ID: c.ID(), Hostname: c.hostname, StartedAt: c.started, HeartbeatAt: now}
+	if err := c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"heartbeat_at"}),
+	}).Create(&member).Error; err != nil {
+		log.Printf("Cluster: heartbeat failed: %v", err)
+		return
+	}
+
+	c.renewLeadership(now)
+
+	var members []string
+	if err := c.db.Model(&models.ClusterMember{}).Where("heartbeat_at > ?", now.Add(-c.cfg.MemberTTL)).
+		Order("id").Pluck("id", &members).Error; err != nil {
+		log.Printf("Cluster: failed to read members: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	changed := !slices.Equal(members, c.members)
+	if changed {
+		c.members = members
+		c.ring = buildRing(members)
+	}
+	c.mu.Unlock()
+	if changed {
+		log.Printf("Cluster: %d live instance(s), monitors rebalanced: %v", len(members), members)
+	}
+
+	// Forget instances that are long gone
+	c.db.Where("heartbeat_at < ?", now.Add(-10*c.cfg.MemberTTL)).Delete(&models.ClusterMember{})
+}
+
+// renewLeadership takes the leader lease if it is free or expired, or renews
+// it if this instance holds it. The upsert only applies in those cases, so
+// concurrent attempts cannot both succeed.
+func (c *Cluster) renewLeadership(now time.Time) {
+	expires := now.Add(c.cfg.MemberTTL)
+	result := c.db.Exec(`INSERT INTO cluster_leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE cluster_leases.holder = excluded.holder OR cluster_leases.expires_at <= ?`,
+		LeaderLease, c.ID(), expires.UnixMilli(), now.UnixMilli())
+	if result.Error != nil {
+		log.Printf("Cluster: failed to renew the leader lease: %v", result.Error)
+		return
+	}
+
+	c.mu.Lock()
+	wasLeader := now.Before(c.leaderUntil)
+	if result.RowsAffected > 0 {
+		c.leaderUntil = expires
+	} else {
+		c.leaderUntil = time.Time{}
+	}
+	isLeader := result.RowsAffected > 0
+	c.mu.Unlock()
+
+	if isLeader && !wasLeader {
+		log.Printf("Cluster: instance %s is now the leader", c.ID())
+	} else if !isLeader && wasLeader {
+		log.Printf("Cluster: instance %s lost the leader lease", c.ID())
+	}
+}
+
+func buildRing(members []string) []ringPoint {
+	ring := make([]ringPoint, 0, len(members)*ringReplicas)
+	for _, member := range members {
+		for i := 0; i < ringReplicas; i++ {
+			ring = append(ring, ringPoint{hash: ringHash(member + "#" + strconv.Itoa(i)), member: member})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
@@ -2,26 +2,90 @@ package services
 
 import (
     "context"
+    "errors"
     "fmt"
+    "log"
     "net"
     "os/exec"
     "regexp"
+    "strconv"
     "strings"
+    "sync"
     "time"
     "runnerx/models"
     "gorm.io/gorm"
     ws "runnerx/websocket"
 )
 
+// ErrShuttingDown is returned for commands requested while the server stops
+var ErrShuttingDown = errors.New("server is shutting down")
+
 type CommandService struct {
     DB *gorm.DB
     Hub *ws.Hub
+
+    // ctx is cancelled to interrupt the commands still running at shutdown
+    ctx    context.Context
+    cancel context.CancelFunc
+    mu       sync.Mutex
+    stopping bool
+    running  sync.WaitGroup
+    // timeout bounds how long a command may run
+    timeout  time.Duration
 }
 
-func NewCommandService(db *gorm.DB, hub *ws.Hub) *CommandService {
-    return &CommandService{
-        DB:  db,
-        Hub: hub,
+func NewCommandService(db *gorm.DB, hub *ws.Hub, timeout time.Duration) *CommandService {
+    ctx, cancel := context.WithCancel(context.Background())
+    cs := &CommandService{
+        DB:      db,
+        Hub:     hub,
+        ctx:     ctx,
+        cancel:  cancel,
+        timeout: timeout,
+    }
+    cs.FailInterrupted()
+    return cs
+}
+
+// SetTimeout changes the time allowed for the commands that follow
+func (cs *CommandService) SetTimeout(timeout time.Duration) {
+    cs.mu.Lock()
+    defer cs.mu.Unlock()
+    cs.timeout = timeout
+}
+
+// FailInterrupted marks the commands left running by a stopped process as
+// failed. Commands of other instances still running are younger than twice
+// the timeout.
+func (cs *CommandService) FailInterrupted() {
+    cs.mu.Lock()
+    timeout := cs.timeout
+    cs.mu.Unlock()
+
+    if n, err := models.FailInterruptedCommands(cs.DB, time.Now().Add(-2*timeout)); err != nil {
+        log.Printf("Failed to fail interrupted commands: %v", err)
+    } else if n > 0 {
+        log.Printf("Marked %d interrupted command(s) as failed", n)
+    }
+}
+
+// Shutdown stops accepting commands and waits for the running ones to finish.
+// Those still running when ctx ends are interrupted and recorded as failed.
+func (cs *CommandService) Shutdown(ctx context.Context) {
+    cs.mu.Lock()
+    cs.stopping = true
+    cs.mu.Unlock()
+
+    if err := waitGroup(ctx, &cs.running); err == nil {
+        return
+    }
+    log.Printf("Interrupting running commands")
+    cs.cancel()
+    // Killed commands return at once; give their results a moment to be saved
+    saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := waitGroup(saveCtx, &cs.running); err != nil {
+        log.Printf("Commands still running after shutdown: %v", err)
     }
 }
 
@@ -56,6 +120,12 @@ func (cs *CommandService) ExecuteCommand(req CommandRequest) (*CommandResponse,
         return nil, fmt.Errorf("invalid target: %s", req.Target)
     }
 
+    cs.mu.Lock()
+    defer cs.mu.Unlock()
+    if cs.stopping {
+        return nil, ErrShuttingDown
+    }
+
     // Create command log entry
     logEntry := &models.CommandLog{
         UserID:    req.UserID,
@@ -70,7 +140,12 @@ func (cs *CommandService) ExecuteCommand(req CommandRequest) (*CommandResponse,
     }
 
     // Execute command in goroutine
-    go cs.executeCommandAsync(logEntry, req)
+    cs.running.Add(1)
+    timeout := cs.timeout
+    go func() {
+        defer cs.running.Done()
+        cs.executeCommandAsync(logEntry, req, timeout)
+    }()
 
     return &CommandResponse{
         ID:        fmt.Sprintf("%d", logEntry.ID),
@@ -81,14 +156,14 @@ func (cs *CommandService) ExecuteCommand(req CommandRequest) (*CommandResponse,
     }, nil
 }
 
-func (cs *CommandService) executeCommandAsync(logEntry *models.CommandLog, req CommandRequest) {
+func (cs *CommandService) executeCommandAsync(logEntry *models.CommandLog, req CommandRequest, timeout time.Duration) {
     startTime := time.Now()
     
     var output string
     var err error
     
     // Create context with timeout
-    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    ctx, cancel := context.WithTimeout(cs.ctx, timeout)
     defer cancel()
 
     switch req.Type {
@@ -108,7 +183,11 @@ func (cs *CommandService) executeCommandAsync(logEntry *models.CommandLog, req C
     
     // Update log entry
     logEntry.Duration = duration
-    if err != nil {
+    if cs.ctx.Err() != nil {
+        logEntry.Status = "failed"
+        logEntry.Output = output
+        logEntry.Error = "Interrupted by server shutdown"
+    } else if err != nil {
         logEntry.Status = "failed"
         logEntry.Error = err.Error()
     } else {
@@ -116,7 +195,9 @@ func (cs *CommandService) executeCommandAsync(logEntry *models.CommandLog, req C
         logEntry.Output = output
     }
     
-    cs.DB.Save(logEntry)
+    if err := cs.DB.Save(logEntry).Error; err != nil {
+        log.Printf("Failed to save command result: %v", err)
+    }
 
     // Send real-time update via WebSocket
     response := CommandResponse{
@@ -130,7 +211,7 @@ func (cs *CommandService) executeCommandAsync(logEntry *models.CommandLog, req C
         Timestamp: time.Now(),
     }
 
-    cs.Hub.BroadcastToUser(req.UserID, "command:result", response)
+    cs.Hub.BroadcastToUserTopic(req.UserID, ws.TopicCommands, "command:result", response)
 }
 
 func (cs *CommandService) executePing(ctx context.Context, target string) (string, error) {
@@ -141,8 +222,13 @@ func (cs *CommandService) executePing(ctx context.Context, target string) (strin
 }
 
 func (cs *CommandService) executeCurl(ctx context.Context, target string) (string, error) {
-    // Use curl with timeout and follow redirects
-    cmd := exec.CommandContext(ctx, "curl", "-L", "-m", "30", "-s", "-w", 
+    // Use curl with timeout and follow redirects; curl's own timeout
+    // matches the command's so it still reports what it got
+    maxTime := 30
+    if deadline, ok := ctx.Deadline(); ok {
+        maxTime = max(1, int(time.Until(deadline).Seconds()))
+    }
+    cmd := exec.CommandContext(ctx, "curl", "-L", "-m", strconv.Itoa(maxTime), "-s", "-w", 
         "HTTP Code: %{http_code}\nTotal Time: %{time_total}s\nSize: %{size_download} bytes\n", 
         target)
     output, err := cmd.CombinedOutput()
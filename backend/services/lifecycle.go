@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// waitGroup waits for wg, or for ctx to end first
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// Both may be ready once the deadline passed
+		select {
+		case <-done:
+			return nil
+		default:
+			return ctx.Err()
+		}
+	}
+}
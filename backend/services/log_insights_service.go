@@ -79,7 +79,7 @@ func (s *LogInsightsService) RecordLog(userID uint, incidentID string, monitorID
         "incident_id": incidentID,
         "insight": insight,
     }
-    s.hub.BroadcastToUser(userID, "logs:insight", payload)
+    s.hub.BroadcastToUserTopic(userID, ws.LogsTopic(incidentID), "logs:insight", payload)
     return nil
 }
 
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"runnerx/config"
+)
+
+// Mailer sends plain-text email through the configured SMTP server. Without
+// an SMTP host it logs messages instead, which is enough for development.
+type Mailer struct {
+	cfg config.MailConfig
+}
+
+func NewMailer(cfg config.MailConfig) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+// Enabled reports whether an SMTP server is configured
+func (m *Mailer) Enabled() bool {
+	return m.cfg.Host != ""
+}
+
+// Send delivers a message to a single recipient
+func (m *Mailer) Send(to, subject, body string) error {
+	if !m.Enabled() {
+		log.Printf("SMTP_HOST not set, email to %s not sent: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+	return smtp.SendMail(addr, auth, from.Address, []string{recipient.Address}, []byte(msg.String()))
+}
+
+// SendAsync sends in the background so response times do not depend on the
+// SMTP server, or reveal whether an address belongs to an account
+func (m *Mailer) SendAsync(to, subject, body string) {
+	go func() {
+		if err := m.Send(to, subject, body); err != nil {
+			log.Printf("Failed to send email to %s: %v", to, err)
+		}
+	}()
+}
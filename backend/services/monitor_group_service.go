@@ -0,0 +1,287 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"runnerx/models"
+	ws "runnerx/websocket"
+
+	"gorm.io/gorm"
+)
+
+// An unchanged group status is added to the history at most this often
+const groupHistoryInterval = time.Minute
+
+// GroupMemberStatus is a group member with the current status of the monitor
+// or nested group it refers to
+type GroupMemberStatus struct {
+	models.MonitorGroupMember
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// GroupEvaluation is the outcome of applying a group's policy to its members
+type GroupEvaluation struct {
+	Status        string  `json:"status"`
+	HealthPercent float64 `json:"health_percent"`
+	Up            int     `json:"up"`
+	Down          int     `json:"down"`
+}
+
+// EvaluateGroup applies a policy to the members' statuses. Members that are
+// paused, disabled or not yet checked are left out; degraded nested groups
+// count as up but make the group degraded at best.
+func EvaluateGroup(policy string, quorum float64, members []GroupMemberStatus) GroupEvaluation {
+	var eval GroupEvaluation
+	var upWeight, totalWeight float64
+	criticalDown, degraded := false, false
+
+	for _, m := range members {
+		switch m.Status {
+		case models.GroupStatusUp, models.GroupStatusDegraded:
+			eval.Up++
+			upWeight += m.Weight
+			totalWeight += m.Weight
+			degraded = degraded || m.Status == models.GroupStatusDegraded
+		case models.GroupStatusDown:
+			eval.Down++
+			totalWeight += m.Weight
+			criticalDown = criticalDown || m.Critical
+		}
+	}
+
+	if eval.Up+eval.Down == 0 {
+		eval.Status = models.GroupStatusPending
+		return eval
+	}
+	eval.HealthPercent = 100
+	if totalWeight > 0 {
+		eval.HealthPercent = upWeight / totalWeight * 100
+	}
+
+	var down bool
+	switch policy {
+	case models.GroupPolicyAnyUp:
+		down = eval.Up == 0
+	case models.GroupPolicyQuorum:
+		down = eval.HealthPercent < quorum
+	default:
+		down = eval.Down > 0
+	}
+
+	switch {
+	case down || criticalDown:
+		eval.Status = models.GroupStatusDown
+	case eval.Down > 0 || degraded:
+		eval.Status = models.GroupStatusDegraded
+	default:
+		eval.Status = models.GroupStatusUp
+	}
+	return eval
+}
+
+// MonitorGroupService keeps the status, history and notifications of monitor
+// groups up to date as their members are checked
+type MonitorGroupService struct {
+	db  *gorm.DB
+	hub *ws.Hub
+
+	// mu serializes evaluations; lastRecorded is when each group's history
+	// last got an entry
+	mu           sync.Mutex
+	lastRecorded map[uint]time.Time
+}
+
+func NewMonitorGroupService(db *gorm.DB, hub *ws.Hub) *MonitorGroupService {
+	return &MonitorGroupService{db: db, hub: hub, lastRecorded: make(map[uint]time.Time)}
+}
+
+// MemberStatuses returns the group's members with their current status
+func (s *MonitorGroupService) MemberStatuses(groupID uint) ([]GroupMemberStatus, error) {
+	var members []models.MonitorGroupMember
+	if err := s.db.Where("group_id = ?", groupID).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	statuses := make([]GroupMemberStatus, 0, len(members))
+	for _, member := range members {
+		status := GroupMemberStatus{MonitorGroupMember: member}
+		if member.MonitorID != nil {
+			var monitor models.Monitor
+			if err := s.db.Select("id", "name", "status", "enabled").First(&monitor, *member.MonitorID).Error; err != nil {
+				// The monitor was deleted; it no longer counts
+				continue
+			}
+			status.Name = monitor.Name
+			status.Status = monitor.Status
+			if !monitor.Enabled {
+				status.Status = "paused"
+			}
+		} else if member.ChildGroupID != nil {
+			var child models.MonitorGroup
+			if err := s.db.Select("id", "name", "status").First(&child, *member.ChildGroupID).Error; err != nil {
+				continue
+			}
+			status.Name = child.Name
+			status.Status = child.Status
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// MonitorChecked re-evaluates every group the monitor belongs to
+func (s *MonitorGroupService) MonitorChecked(monitorID uint) {
+	var groupIDs []uint
+	if err := s.db.Model(&models.MonitorGroupMember{}).Where("monitor_id = ?", monitorID).
+		Distinct().Pluck("group_id", &groupIDs).Error; err != nil {
+		log.Printf("Failed to find groups of monitor %d: %v", monitorID, err)
+		return
+	}
+	for _, groupID := range groupIDs {
+		s.Evaluate(groupID)
+	}
+}
+
+// Evaluate recomputes a group's status. When it changes, the group's members
+// are notified and the groups containing it are evaluated in turn.
+func (s *MonitorGroupService) Evaluate(groupID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evaluate(groupID, map[uint]bool{})
+}
+
+func (s *MonitorGroupService) evaluate(groupID uint, visited map[uint]bool) {
+	if visited[groupID] {
+		return
+	}
+	visited[groupID] = true
+
+	var group models.MonitorGroup
+	if err := s.db.First(&group, groupID).Error; err != nil {
+		return
+	}
+	members, err := s.MemberStatuses(groupID)
+	if err != nil {
+		log.Printf("Failed to read members of group %d: %v", groupID, err)
+		return
+	}
+
+	eval := EvaluateGroup(group.Policy, group.Quorum, members)
+	now := time.Now()
+	oldStatus := group.Status
+	changed := oldStatus != eval.Status
+
+	group.Status = eval.Status
+	group.HealthPercent = eval.HealthPercent
+	group.LastCheckAt = &now
+	if changed {
+		group.LastStatusChangeAt = &now
+	}
+
+	if eval.Status != models.GroupStatusPending && (changed || now.Sub(s.lastRecorded[groupID]) >= groupHistoryInterval) {
+		entry := models.MonitorGroupCheck{
+			GroupID:       groupID,
+			Status:        eval.Status,
+			HealthPercent: eval.HealthPercent,
+			UpMembers:     eval.Up,
+			DownMembers:   eval.Down,
+		}
+		if err := s.db.Create(&entry).Error; err != nil {
+			log.Printf("Failed to record history of group %d: %v", groupID, err)
+		} else {
+			var up int64
+			if eval.Status != models.GroupStatusDown {
+				up = 1
+			}
+			if err := models.AddToRollup(s.db, models.RollupGroup, groupID, entry.CreatedAt, 1, up, 0); err != nil {
+				log.Printf("Failed to update uptime rollup of group %d: %v", groupID, err)
+			}
+			s.lastRecorded[groupID] = now
+			group.TotalChecks++
+			if eval.Status != models.GroupStatusDown {
+				group.SuccessfulChecks++
+			}
+			group.UptimePercent = float64(group.SuccessfulChecks) / float64(group.TotalChecks) * 100
+		}
+	}
+
+	// A change is only claimed if the status is still the one read, so when
+	// several instances evaluate the group just one of them notifies
+	query := s.db.Model(&group)
+	if changed {
+		query = query.Where("status = ?", oldStatus)
+	}
+	result := query.Select("status", "health_percent", "last_check_at", "last_status_change_at",
+		"total_checks", "successful_checks", "uptime_percent").Updates(&group)
+	if result.Error != nil {
+		log.Printf("Failed to update group %d: %v", groupID, result.Error)
+		return
+	}
+
+	if !changed || result.RowsAffected == 0 {
+		return
+	}
+	if oldStatus != models.GroupStatusPending {
+		s.notify(&group, oldStatus)
+	}
+
+	parentIDs, err := models.ParentGroupIDs(s.db, groupID)
+	if err != nil {
+		log.Printf("Failed to find parents of group %d: %v", groupID, err)
+		return
+	}
+	for _, parentID := range parentIDs {
+		s.evaluate(parentID, visited)
+	}
+}
+
+// notify sends a status change to everyone who can see the group and stores
+// a notification for them
+func (s *MonitorGroupService) notify(group *models.MonitorGroup, oldStatus string) {
+	recipients := []uint{group.UserID}
+	if group.TeamID != nil {
+		if ids, err := models.TeamMemberIDs(s.db, *group.TeamID); err == nil && len(ids) > 0 {
+			recipients = ids
+		}
+	}
+
+	var notifType, message string
+	switch group.Status {
+	case models.GroupStatusDown:
+		notifType, message = "down", fmt.Sprintf("Group '%s' is now down", group.Name)
+	case models.GroupStatusDegraded:
+		notifType, message = "warning", fmt.Sprintf("Group '%s' is degraded", group.Name)
+	case models.GroupStatusUp:
+		notifType, message = "up", fmt.Sprintf("Group '%s' is back up", group.Name)
+	}
+
+	change := map[string]interface{}{
+		"group_id":   group.ID,
+		"old_status": oldStatus,
+		"new_status": group.Status,
+		"timestamp":  group.LastStatusChangeAt,
+	}
+	for _, uid := range recipients {
+		s.hub.BroadcastToUserTopic(uid, ws.GroupTopic(group.ID), "group:status_change", change)
+
+		if message == "" {
+			continue
+		}
+		notification, err := models.CreateGroupNotification(s.db, uid, group.ID, notifType, message)
+		if err != nil {
+			log.Printf("Error creating notification: %v", err)
+			continue
+		}
+		s.hub.BroadcastToUser(uid, "notification", map[string]interface{}{
+			"id":         notification.ID,
+			"group_id":   group.ID,
+			"type":       notifType,
+			"message":    message,
+			"created_at": notification.CreatedAt,
+		})
+	}
+}
@@ -0,0 +1,667 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"runnerx/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Supported import sources
+const (
+	ImportSourceUptimeKuma  = "uptime_kuma"
+	ImportSourceUptimeRobot = "uptime_robot"
+	ImportSourceBlackbox    = "blackbox"
+)
+
+// MonitorImporter translates monitors exported from other tools into RunnerX monitors
+type MonitorImporter struct {
+	DB *gorm.DB
+}
+
+func NewMonitorImporter(db *gorm.DB) *MonitorImporter {
+	return &MonitorImporter{DB: db}
+}
+
+// ImportIssue describes an entry that was skipped or translated with caveats
+type ImportIssue struct {
+	Entry  string `json:"entry"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult reports what an import created and what it could not translate
+type ImportResult struct {
+	Source   string           `json:"source"`
+	DryRun   bool             `json:"dry_run"`
+	Imported int              `json:"imported"`
+	Monitors []models.Monitor `json:"monitors"`
+	Skipped  []ImportIssue    `json:"skipped"`
+	Warnings []ImportIssue    `json:"warnings"`
+}
+
+// importBatch collects parsed monitors and issues for a single source
+type importBatch struct {
+	monitors []models.Monitor
+	skipped  []ImportIssue
+	warnings []ImportIssue
+}
+
+func newImportBatch() *importBatch {
+	return &importBatch{skipped: []ImportIssue{}, warnings: []ImportIssue{}}
+}
+
+func (b *importBatch) skip(entry, format string, args ...interface{}) {
+	b.skipped = append(b.skipped, ImportIssue{Entry: entry, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (b *importBatch) warn(entry, format string, args ...interface{}) {
+	b.warnings = append(b.warnings, ImportIssue{Entry: entry, Reason: fmt.Sprintf(format, args...)})
+}
+
+// Import parses data from the given source and creates the resulting monitors for the user,
+// owned by the team when teamID is set. With dryRun set nothing is written and the result
+// shows what would be created.
+func (mi *MonitorImporter) Import(userID uint, teamID *uint, source string, data []byte, dryRun bool) (*ImportResult, error) {
+	var batch *importBatch
+	var err error
+
+	switch source {
+	case ImportSourceUptimeKuma:
+		batch, err = parseUptimeKuma(data)
+	case ImportSourceUptimeRobot:
+		batch, err = parseUptimeRobotCSV(data)
+	case ImportSourceBlackbox:
+		batch, err = parseBlackboxConfig(data)
+	default:
+		return nil, fmt.Errorf("unsupported import source: %s", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		Source:   source,
+		DryRun:   dryRun,
+		Monitors: []models.Monitor{},
+		Skipped:  batch.skipped,
+		Warnings: batch.warnings,
+	}
+
+	// Existing monitors are matched on type and endpoint so re-running an import is safe
+	var existing []models.Monitor
+	scope := mi.DB.Where("user_id = ? AND team_id IS NULL", userID)
+	if teamID != nil {
+		scope = mi.DB.Where("team_id = ?", *teamID)
+	}
+	if err := scope.Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, m := range existing {
+		seen[m.Type+"|"+m.Endpoint] = true
+	}
+
+	configService := NewMonitoringConfigService(mi.DB)
+	accepted := make([]models.Monitor, 0, len(batch.monitors))
+	for _, monitor := range batch.monitors {
+		monitor.UserID = userID
+		monitor.TeamID = teamID
+		monitor.Status = "pending"
+		if !monitor.Enabled {
+			monitor.Status = "paused"
+		}
+		if monitor.Method == "" {
+			monitor.Method = "GET"
+		}
+		normalizeImportedTiming(configService, &monitor, batch)
+
+		if err := configService.ValidateMonitorConfig(&monitor); err != nil {
+			batch.skip(monitor.Name, "%v", err)
+			continue
+		}
+		key := monitor.Type + "|" + monitor.Endpoint
+		if seen[key] {
+			batch.skip(monitor.Name, "a %s monitor for %s already exists", monitor.Type, monitor.Endpoint)
+			continue
+		}
+		seen[key] = true
+		accepted = append(accepted, monitor)
+	}
+	result.Skipped = batch.skipped
+	result.Warnings = batch.warnings
+
+	if !dryRun && len(accepted) > 0 {
+		if err := mi.DB.Transaction(func(tx *gorm.DB) error {
+			for i := range accepted {
+				enabled := accepted[i].Enabled
+				if err := tx.Create(&accepted[i]).Error; err != nil {
+					return err
+				}
+				// Enabled has a database default of true, so paused monitors need an explicit update
+				if !enabled {
+					if err := tx.Model(&accepted[i]).Update("enabled", false).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to save imported monitors: %v", err)
+		}
+	}
+
+	result.Monitors = accepted
+	result.Imported = len(accepted)
+	return result, nil
+}
+
+// normalizeImportedTiming fills defaults and clamps interval and timeout to the ranges RunnerX accepts
+func normalizeImportedTiming(configService *MonitoringConfigService, monitor *models.Monitor, batch *importBatch) {
+	if monitor.IntervalSeconds == 0 {
+		monitor.IntervalSeconds = configService.GetOptimalInterval(monitor.Type)
+	} else if monitor.IntervalSeconds < 10 {
+		batch.warn(monitor.Name, "interval %ds raised to the 10s minimum", monitor.IntervalSeconds)
+		monitor.IntervalSeconds = 10
+	} else if monitor.IntervalSeconds > 86400 {
+		batch.warn(monitor.Name, "interval %ds lowered to the 24h maximum", monitor.IntervalSeconds)
+		monitor.IntervalSeconds = 86400
+	}
+
+	if monitor.Timeout == 0 {
+		monitor.Timeout = configService.GetOptimalTimeout(monitor.Type)
+	} else if monitor.Timeout > 60 {
+		batch.warn(monitor.Name, "timeout %ds lowered to the 60s maximum", monitor.Timeout)
+		monitor.Timeout = 60
+	}
+}
+
+// ---- Uptime Kuma ----
+
+type kumaBackup struct {
+	Version     string        `json:"version"`
+	MonitorList []kumaMonitor `json:"monitorList"`
+}
+
+type kumaMonitor struct {
+	ID               int             `json:"id"`
+	Name             string          `json:"name"`
+	Type             string          `json:"type"`
+	URL              string          `json:"url"`
+	Hostname         string          `json:"hostname"`
+	Port             json.Number     `json:"port"`
+	Method           string          `json:"method"`
+	Interval         json.Number     `json:"interval"`
+	Timeout          json.Number     `json:"timeout"`
+	Headers          json.RawMessage `json:"headers"`
+	Body             string          `json:"body"`
+	Active           json.RawMessage `json:"active"`
+	Parent           *int            `json:"parent"`
+	Keyword          string          `json:"keyword"`
+	DNSResolveType   string          `json:"dns_resolve_type"`
+	DNSResolveServer string          `json:"dns_resolve_server"`
+	Tags             []kumaTag       `json:"tags"`
+}
+
+type kumaTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func parseUptimeKuma(data []byte) (*importBatch, error) {
+	var backup kumaBackup
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&backup); err != nil {
+		return nil, fmt.Errorf("invalid Uptime Kuma backup: %v", err)
+	}
+	if backup.MonitorList == nil {
+		return nil, fmt.Errorf("invalid Uptime Kuma backup: monitorList is missing")
+	}
+
+	// Group monitors are not imported but their names are kept as tags on children
+	groups := make(map[int]string)
+	for _, km := range backup.MonitorList {
+		if km.Type == "group" {
+			groups[km.ID] = km.Name
+		}
+	}
+
+	batch := newImportBatch()
+	for _, km := range backup.MonitorList {
+		name := strings.TrimSpace(km.Name)
+		if name == "" {
+			name = fmt.Sprintf("kuma monitor #%d", km.ID)
+		}
+
+		monitor := models.Monitor{
+			Name:            name,
+			Method:          strings.ToUpper(km.Method),
+			IntervalSeconds: numberToInt(km.Interval),
+			Timeout:         numberToInt(km.Timeout),
+			Enabled:         kumaActive(km.Active),
+			Tags:            kumaTags(km.Tags),
+		}
+		if km.Parent != nil {
+			if group, ok := groups[*km.Parent]; ok {
+				monitor.Tags = append(monitor.Tags, "group:"+group)
+			}
+		}
+
+		switch km.Type {
+		case "http", "keyword", "json-query":
+			monitor.Type = "http"
+			monitor.Endpoint = km.URL
+			if km.Type != "http" {
+				batch.warn(name, "%s assertions are not supported; imported as a plain HTTP check", km.Type)
+			}
+			if km.Body != "" {
+				batch.warn(name, "request body is not supported and was dropped")
+			}
+			headers, err := normalizeHeaders(km.Headers)
+			if err != nil {
+				batch.warn(name, "headers could not be parsed and were dropped: %v", err)
+			}
+			monitor.HeadersJSON = headers
+		case "port":
+			if km.Port.String() == "" || km.Port.String() == "0" {
+				batch.skip(name, "port monitor without a port")
+				continue
+			}
+			monitor.Type = "tcp"
+			monitor.Endpoint = net.JoinHostPort(km.Hostname, km.Port.String())
+		case "ping":
+			monitor.Type = "ping"
+			monitor.Endpoint = km.Hostname
+		case "dns":
+			monitor.Type = "dns"
+			monitor.Endpoint = km.Hostname
+			if km.DNSResolveType != "" && km.DNSResolveType != "A" && km.DNSResolveType != "AAAA" {
+				batch.warn(name, "%s record checks are not supported; imported as a host lookup", km.DNSResolveType)
+			}
+			if km.DNSResolveServer != "" {
+				batch.warn(name, "custom resolver %s is not supported; the system resolver is used", km.DNSResolveServer)
+			}
+		case "push":
+			monitor.Type = "push"
+			monitor.Endpoint = name
+			batch.warn(name, "push URLs change; send heartbeats to POST /api/monitor/<id>/heartbeat")
+		case "group":
+			continue
+		default:
+			batch.skip(name, "monitor type %q has no RunnerX equivalent", km.Type)
+			continue
+		}
+
+		batch.monitors = append(batch.monitors, monitor)
+	}
+	return batch, nil
+}
+
+func kumaActive(raw json.RawMessage) bool {
+	switch strings.TrimSpace(string(raw)) {
+	case "", "null", "true", "1":
+		return true
+	default:
+		return false
+	}
+}
+
+func kumaTags(tags []kumaTag) models.StringArray {
+	out := models.StringArray{}
+	for _, t := range tags {
+		if t.Name == "" {
+			continue
+		}
+		if t.Value != "" {
+			out = append(out, t.Name+":"+t.Value)
+		} else {
+			out = append(out, t.Name)
+		}
+	}
+	return out
+}
+
+// normalizeHeaders turns a JSON object (or a JSON string containing one) into the
+// map[string]string encoding used by Monitor.HeadersJSON
+func normalizeHeaders(raw json.RawMessage) (string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" || trimmed == `""` {
+		return "", nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var inner string
+		if err := json.Unmarshal(raw, &inner); err != nil {
+			return "", err
+		}
+		trimmed = strings.TrimSpace(inner)
+		if trimmed == "" {
+			return "", nil
+		}
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &values); err != nil {
+		return "", err
+	}
+	headers := make(map[string]string, len(values))
+	for k, v := range values {
+		headers[k] = fmt.Sprint(v)
+	}
+	out, err := json.Marshal(headers)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func numberToInt(n json.Number) int {
+	if n == "" {
+		return 0
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0
+	}
+	return int(f)
+}
+
+// ---- Uptime Robot ----
+
+var parenthesizedPort = regexp.MustCompile(`\((\d+)\)`)
+
+func parseUptimeRobotCSV(data []byte) (*importBatch, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Uptime Robot export: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[normalizeColumn(h)] = i
+	}
+	col := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+	if _, ok := columns["url"]; !ok {
+		if _, ok := columns["urlip"]; !ok {
+			return nil, fmt.Errorf("invalid Uptime Robot export: no URL column found")
+		}
+	}
+	_, intervalInMinutes := columns["intervalmin"]
+
+	batch := newImportBatch()
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			batch.skip(fmt.Sprintf("row %d", row), "unreadable CSV row: %v", err)
+			continue
+		}
+
+		name := col(record, "friendlyname", "name")
+		target := col(record, "url", "urlip", "host")
+		if name == "" {
+			name = target
+		}
+		entry := fmt.Sprintf("row %d (%s)", row, name)
+		kind := strings.ToLower(col(record, "type", "monitortype"))
+		isHeartbeat := kind == "5" || strings.HasPrefix(kind, "heartbeat")
+		if isHeartbeat {
+			target = name
+		}
+		if target == "" {
+			batch.skip(entry, "no URL or host")
+			continue
+		}
+
+		monitor := models.Monitor{
+			Name:    name,
+			Method:  strings.ToUpper(col(record, "httpmethod", "method")),
+			Enabled: !strings.EqualFold(col(record, "status"), "paused"),
+			Tags:    splitTags(col(record, "tags")),
+		}
+		if v := col(record, "interval", "intervalsec", "intervalmin", "monitoringinterval"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				if intervalInMinutes {
+					n *= 60
+				}
+				monitor.IntervalSeconds = n
+			}
+		}
+		if v := col(record, "timeout"); v != "" {
+			monitor.Timeout, _ = strconv.Atoi(v)
+		}
+
+		switch {
+		case isHeartbeat:
+			monitor.Type = "push"
+			monitor.Endpoint = target
+			batch.warn(entry, "heartbeat URLs change; send heartbeats to POST /api/monitor/<id>/heartbeat")
+		case kind == "1" || strings.HasPrefix(kind, "http"):
+			monitor.Type = "http"
+			monitor.Endpoint = target
+		case kind == "2" || strings.HasPrefix(kind, "keyword"):
+			monitor.Type = "http"
+			monitor.Endpoint = target
+			batch.warn(entry, "keyword assertions are not supported; imported as a plain HTTP check")
+		case kind == "3" || strings.HasPrefix(kind, "ping"):
+			monitor.Type = "ping"
+			monitor.Endpoint = target
+		case kind == "4" || strings.HasPrefix(kind, "port"):
+			port := col(record, "port")
+			if port == "" {
+				if m := parenthesizedPort.FindStringSubmatch(col(record, "subtype")); m != nil {
+					port = m[1]
+				}
+			}
+			if port == "" {
+				batch.skip(entry, "port monitor without a port")
+				continue
+			}
+			monitor.Type = "tcp"
+			monitor.Endpoint = net.JoinHostPort(target, port)
+		default:
+			batch.skip(entry, "monitor type %q has no RunnerX equivalent", kind)
+			continue
+		}
+
+		batch.monitors = append(batch.monitors, monitor)
+	}
+	return batch, nil
+}
+
+// normalizeColumn lowercases a CSV header and strips everything but letters and digits
+func normalizeColumn(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func splitTags(s string) models.StringArray {
+	out := models.StringArray{}
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ---- Prometheus blackbox_exporter ----
+
+type promConfig struct {
+	Global struct {
+		ScrapeInterval string `yaml:"scrape_interval"`
+		ScrapeTimeout  string `yaml:"scrape_timeout"`
+	} `yaml:"global"`
+	ScrapeConfigs []promScrapeConfig `yaml:"scrape_configs"`
+}
+
+type promScrapeConfig struct {
+	JobName        string              `yaml:"job_name"`
+	ScrapeInterval string              `yaml:"scrape_interval"`
+	ScrapeTimeout  string              `yaml:"scrape_timeout"`
+	MetricsPath    string              `yaml:"metrics_path"`
+	Params         map[string][]string `yaml:"params"`
+	StaticConfigs  []struct {
+		Targets []string          `yaml:"targets"`
+		Labels  map[string]string `yaml:"labels"`
+	} `yaml:"static_configs"`
+	FileSDConfigs   []interface{} `yaml:"file_sd_configs"`
+	DNSSDConfigs    []interface{} `yaml:"dns_sd_configs"`
+	ConsulSDConfigs []interface{} `yaml:"consul_sd_configs"`
+}
+
+func parseBlackboxConfig(data []byte) (*importBatch, error) {
+	var cfg promConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid Prometheus config: %v", err)
+	}
+	if len(cfg.ScrapeConfigs) == 0 {
+		return nil, fmt.Errorf("invalid Prometheus config: no scrape_configs found")
+	}
+
+	batch := newImportBatch()
+	for _, job := range cfg.ScrapeConfigs {
+		entry := "job " + job.JobName
+		modules := job.Params["module"]
+		if len(modules) == 0 {
+			if job.MetricsPath != "/probe" {
+				batch.skip(entry, "not a blackbox_exporter job (no module parameter)")
+				continue
+			}
+			modules = []string{"http_2xx"}
+			batch.warn(entry, "no module parameter; assuming http_2xx")
+		}
+		module := modules[0]
+
+		monitorType, method := blackboxModuleType(module)
+		if monitorType == "" {
+			batch.skip(entry, "module %q cannot be mapped to a RunnerX monitor type", module)
+			continue
+		}
+		if monitorType == "dns" {
+			batch.warn(entry, "dns module targets are resolvers; RunnerX looks up the target hostname instead")
+		}
+		if len(job.FileSDConfigs)+len(job.DNSSDConfigs)+len(job.ConsulSDConfigs) > 0 {
+			batch.warn(entry, "only static_configs targets are imported; service discovery entries were ignored")
+		}
+
+		interval := firstNonEmpty(job.ScrapeInterval, cfg.Global.ScrapeInterval)
+		timeout := firstNonEmpty(job.ScrapeTimeout, cfg.Global.ScrapeTimeout)
+		intervalSeconds, err := parsePromDuration(interval)
+		if err != nil {
+			batch.warn(entry, "scrape_interval %q not understood; using the default", interval)
+		}
+		timeoutSeconds, err := parsePromDuration(timeout)
+		if err != nil {
+			batch.warn(entry, "scrape_timeout %q not understood; using the default", timeout)
+		}
+
+		for _, sc := range job.StaticConfigs {
+			tags := models.StringArray{"job:" + job.JobName}
+			for k, v := range sc.Labels {
+				tags = append(tags, k+":"+v)
+			}
+			for _, target := range sc.Targets {
+				endpoint := target
+				if monitorType != "http" && monitorType != "tcp" {
+					endpoint = stripScheme(target)
+				}
+				batch.monitors = append(batch.monitors, models.Monitor{
+					Name:            target,
+					Type:            monitorType,
+					Endpoint:        endpoint,
+					Method:          method,
+					IntervalSeconds: intervalSeconds,
+					Timeout:         timeoutSeconds,
+					Enabled:         true,
+					Tags:            append(models.StringArray{}, tags...),
+				})
+			}
+		}
+	}
+	return batch, nil
+}
+
+// blackboxModuleType guesses the prober of a module from its conventional name
+func blackboxModuleType(module string) (string, string) {
+	m := strings.ToLower(module)
+	switch {
+	case strings.Contains(m, "http"):
+		if strings.Contains(m, "post") {
+			return "http", "POST"
+		}
+		return "http", "GET"
+	case strings.Contains(m, "tcp"):
+		return "tcp", ""
+	case strings.Contains(m, "icmp"), strings.Contains(m, "ping"):
+		return "ping", ""
+	case strings.Contains(m, "dns"):
+		return "dns", ""
+	}
+	return "", ""
+}
+
+// parsePromDuration parses Prometheus durations (which also allow d, w and y units) into seconds
+func parsePromDuration(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if mult, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, err
+		}
+		return int((time.Duration(n) * mult).Seconds()), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return int(d.Seconds()), nil
+}
+
+func stripScheme(target string) string {
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	}
+	return strings.Split(target, "/")[0]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -2,50 +2,110 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net"
-	"net/http"
-	"os/exec"
+	"runnerx/config"
 	"runnerx/models"
+	"runnerx/probe"
 	ws "runnerx/websocket"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var tracer = otel.Tracer("runnerx/services")
+
 type MonitorService struct {
 	db  *gorm.DB
 	hub *ws.Hub
     li  *LogInsightsService
     ins *IncidentService
+    groups *MonitorGroupService
+    screenshots *ScreenshotService
+    // location names the checks run by the server itself
+    location string
+    // locks serializes the results of a monitor coming from several locations
+    locks sync.Map
+    // lastLocal is when monitors checked from several locations were last
+    // checked here, since LastCheckAt also moves with remote results
+    lastLocal sync.Map
+    // cluster shards the monitors over the running instances
+    cluster *Cluster
+    // checks counts the checks in flight, drained on shutdown
+    checks sync.WaitGroup
+
+    mu  sync.RWMutex
+    cfg config.MonitoringConfig
 }
 
-func NewMonitorService(db *gorm.DB, hub *ws.Hub) *MonitorService {
+func NewMonitorService(db *gorm.DB, hub *ws.Hub, groups *MonitorGroupService, screenshots *ScreenshotService, cfg config.MonitoringConfig, location string, cluster *Cluster) *MonitorService {
     return &MonitorService{
         db:  db,
         hub: hub,
         li:  NewLogInsightsService(db, hub),
         ins: NewIncidentService(db),
+        groups: groups,
+        screenshots: screenshots,
+        location: location,
+        cluster: cluster,
+        cfg: cfg,
     }
 }
 
-// Start begins monitoring all enabled monitors
-func (ms *MonitorService) Start() {
+// Reload applies new monitoring settings; a new scheduler tick takes effect
+// after the current one
+func (ms *MonitorService) Reload(cfg config.MonitoringConfig) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cfg = cfg
+}
+
+func (ms *MonitorService) settings() config.MonitoringConfig {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.cfg
+}
+
+// Location is the name of the server's own probe location
+func (ms *MonitorService) Location() string {
+	return ms.location
+}
+
+// Start checks the enabled monitors until ctx is cancelled. Checks already
+// running are left to finish; Drain waits for them.
+func (ms *MonitorService) Start(ctx context.Context) {
 	log.Println("Monitor service started")
-	ticker := time.NewTicker(10 * time.Second)
+	tick := ms.settings().SchedulerTick
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		ms.checkAllMonitors()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Monitor service stopped")
+			return
+		case <-ticker.C:
+			ms.checkAllMonitors()
+		}
+		if next := ms.settings().SchedulerTick; next != tick {
+			tick = next
+			ticker.Reset(tick)
+		}
 	}
 }
 
+// Drain waits for the checks in flight to be recorded, or for ctx to end
+func (ms *MonitorService) Drain(ctx context.Context) error {
+	return waitGroup(ctx, &ms.checks)
+}
+
 func (ms *MonitorService) checkAllMonitors() {
 	var monitors []models.Monitor
 	if err := ms.db.Where("enabled = ?", true).Find(&monitors).Error; err != nil {
@@ -54,36 +114,60 @@ func (ms *MonitorService) checkAllMonitors() {
 	}
 
 	for _, monitor := range monitors {
+		// Other instances check their share of the monitors
+		if !ms.cluster.Owns(monitor.ID) {
+			continue
+		}
+
+		// Monitors assigned to other locations are checked by agents
+		if monitor.Type != "push" && !monitor.ChecksAt(ms.location, ms.location) {
+			continue
+		}
+
 		// Check if it's time to check this monitor
-		if monitor.LastCheckAt != nil {
-			nextCheck := monitor.LastCheckAt.Add(time.Duration(monitor.IntervalSeconds) * time.Second)
+		lastCheckAt := monitor.LastCheckAt
+		if len(monitor.Locations) > 0 {
+			lastCheckAt = nil
+			if last, ok := ms.lastLocal.Load(monitor.ID); ok {
+				t := last.(time.Time)
+				lastCheckAt = &t
+			}
+		}
+		if lastCheckAt == nil && monitor.Type == "push" {
+			// Give push monitors one full interval to deliver their first heartbeat
+			lastCheckAt = &monitor.CreatedAt
+		}
+		if lastCheckAt != nil {
+			nextCheck := lastCheckAt.Add(time.Duration(monitor.IntervalSeconds) * time.Second)
 			if time.Now().Before(nextCheck) {
 				continue
 			}
 		}
 
 		// Perform check in goroutine to avoid blocking
-		go ms.checkMonitor(&monitor)
+		ms.checks.Add(1)
+		go func(monitor models.Monitor) {
+			defer ms.checks.Done()
+			ms.checkMonitor(&monitor)
+		}(monitor)
 	}
 }
 
 func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
-	startTime := time.Now()
-	var status string
-	var latencyMs int64
-	var statusCode int
-	var errorMsg string
-
-	switch monitor.Type {
-	case "http":
-		status, latencyMs, statusCode, errorMsg = ms.checkHTTP(monitor)
-	case "ping":
-		status, latencyMs, errorMsg = ms.checkPing(monitor)
-	case "tcp":
-		status, latencyMs, errorMsg = ms.checkTCP(monitor)
-	case "dns":
-		status, latencyMs, errorMsg = ms.checkDNS(monitor)
-	default:
+	// Every check starts a trace: the probe, then recording its result
+	ctx, span := tracer.Start(context.Background(), "monitor check", trace.WithAttributes(
+		attribute.Int("monitor.id", int(monitor.ID)),
+		attribute.String("monitor.name", monitor.Name),
+		attribute.String("monitor.location", ms.location),
+	))
+	defer span.End()
+
+	if monitor.Type == "push" {
+		status, errorMsg := ms.checkPush(monitor)
+		ms.recordCheck(ctx, monitor, "", probe.Result{Status: status, ErrorMsg: errorMsg})
+		return
+	}
+	if !probe.Supports(monitor.Type) {
 		logrus.WithFields(logrus.Fields{
 			"monitor_id": monitor.ID,
 			"type":       monitor.Type,
@@ -91,32 +175,92 @@ func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
 		return
 	}
 
+	if len(monitor.Locations) > 0 {
+		ms.lastLocal.Store(monitor.ID, time.Now())
+	}
+	ms.RecordResult(ctx, monitor.ID, ms.location, probe.Run(ctx, monitor))
+}
+
+// RecordHeartbeat stores a heartbeat pushed by a client for a push monitor
+func (ms *MonitorService) RecordHeartbeat(ctx context.Context, monitor *models.Monitor, status string, latencyMs int64, message string) {
+	ms.recordCheck(ctx, monitor, "", probe.Result{Status: status, LatencyMs: latencyMs, ErrorMsg: message})
+}
+
+// RecordResult stores the result of a check run at location, here or by an
+// agent. Results of the same monitor are recorded one at a time.
+func (ms *MonitorService) RecordResult(ctx context.Context, monitorID uint, location string, result probe.Result) {
+	lock, _ := ms.locks.LoadOrStore(monitorID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	var monitor models.Monitor
+	if err := ms.db.WithContext(context.WithoutCancel(ctx)).First(&monitor, monitorID).Error; err != nil || !monitor.Enabled {
+		return
+	}
+	ms.recordCheck(ctx, &monitor, location, result)
+}
+
+// recordCheck persists a check result, updates the monitor and fans out
+// events and notifications. ctx carries the trace; the result is recorded
+// even if the request that delivered it is cancelled.
+func (ms *MonitorService) recordCheck(ctx context.Context, monitor *models.Monitor, location string, result probe.Result) {
+	ctx = context.WithoutCancel(ctx)
+	db := ms.db.WithContext(ctx)
+	latencyMs, statusCode, errorMsg := result.LatencyMs, result.StatusCode, result.ErrorMsg
+	if result.CertExpiresAt != nil {
+		monitor.CertExpiresAt = result.CertExpiresAt
+	}
+
 	// Save check result
-	causeType, causeDetail := deriveRootCause(monitor.Type, status, statusCode, errorMsg)
+	causeType, causeDetail := deriveRootCause(monitor.Type, result.Status, statusCode, errorMsg, result.Timing)
 	check := models.Check{
 		MonitorID:    monitor.ID,
-		Status:       status,
+		Status:       result.Status,
 		LatencyMs:    latencyMs,
 		StatusCode:   statusCode,
 		ErrorMsg:     errorMsg,
-		ResponseTime: time.Since(startTime),
+		ResponseTime: result.ResponseTime,
+		Location:     location,
 		CauseType:    causeType,
 		CauseDetail:  causeDetail,
+		Timing:       result.Timing,
 	}
 
-	if err := ms.db.Create(&check).Error; err != nil {
+	// With several locations the monitor's status depends on all of them
+	status := ms.locationStatus(db, monitor, location, result)
+
+	if err := db.Create(&check).Error; err != nil {
 		log.Printf("Error saving check: %v", err)
+	} else {
+		var up, latency int64
+		if status == "up" {
+			up, latency = 1, latencyMs
+		}
+		if err := models.AddToRollup(db, models.RollupMonitor, monitor.ID, check.CreatedAt, 1, up, latency); err != nil {
+			log.Printf("Error updating uptime rollup: %v", err)
+		}
+		if up == 1 && result.Timing != nil {
+			if err := models.AddTimingToRollup(db, monitor.ID, check.CreatedAt, *result.Timing); err != nil {
+				log.Printf("Error updating timing rollup: %v", err)
+			}
+		}
 	}
 
 	// Get old status before update
 	oldStatus := monitor.Status
 
 	// Update monitor status
-	if err := monitor.UpdateStatus(ms.db, status, latencyMs); err != nil {
+	changed, err := monitor.UpdateStatus(db, status, latencyMs)
+	if err != nil {
 		log.Printf("Error updating monitor status: %v", err)
 		return
 	}
 
+	// Groups containing the monitor may change status too
+	if ms.groups != nil {
+		ms.groups.MonitorChecked(monitor.ID)
+	}
+
 	// Broadcast update via WebSocket
 	updateData := map[string]interface{}{
 		"monitor_id":       monitor.ID,
@@ -126,11 +270,14 @@ func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
 		"uptime_percent":   monitor.UptimePercent,
 	}
 
-	ms.hub.BroadcastToUser(monitor.UserID, "monitor:update", updateData)
+	recipients := ms.recipients(monitor)
+	for _, uid := range recipients {
+		ms.hub.BroadcastToUserTopic(uid, ws.MonitorTopic(monitor.ID), "monitor:update", updateData)
+	}
 
     // Capture screenshot on downtime (best-effort)
     if status == "down" {
-        go ms.captureDowntimeScreenshot(monitor)
+        go ms.captureDowntimeScreenshot(ctx, monitor)
     }
 
     // Record a log insight entry only when the check failed
@@ -144,12 +291,12 @@ func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
             userID := monitor.UserID
             mid := monitor.ID
             _ = ms.li.RecordLog(userID, incidentID, &mid, "error", msg)
-            if ms.ins != nil { ms.ins.RecordFailure(monitor.UserID, monitor.ID, latencyMs, statusCode, errorMsg) }
+            if ms.ins != nil { ms.ins.RecordFailure(monitor, latencyMs, statusCode, errorMsg) }
         }
     }
 
     // Handle status change
-	if oldStatus != status && oldStatus != "pending" {
+	if changed && oldStatus != "pending" {
         // Broadcast status change
 		statusChangeData := map[string]interface{}{
 			"monitor_id": monitor.ID,
@@ -157,7 +304,9 @@ func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
 			"new_status": status,
 			"timestamp":  time.Now(),
 		}
-		ms.hub.BroadcastToUser(monitor.UserID, "monitor:status_change", statusChangeData)
+		for _, uid := range recipients {
+			ms.hub.BroadcastToUserTopic(uid, ws.MonitorTopic(monitor.ID), "monitor:status_change", statusChangeData)
+		}
 
         // Automation removed
 
@@ -176,10 +325,18 @@ func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
 			}
 
 			if message != "" {
-				notification, err := models.CreateNotification(ms.db, monitor.UserID, monitor.ID, notifType, message)
-				if err != nil {
-					log.Printf("Error creating notification: %v", err)
-				} else {
+				notifyCtx, span := tracer.Start(ctx, "notify", trace.WithAttributes(
+					attribute.Int("monitor.id", int(monitor.ID)),
+					attribute.String("notification.type", notifType),
+					attribute.Int("notification.recipients", len(recipients)),
+				))
+				// Every member of the monitor's team gets their own notification
+				for _, uid := range recipients {
+					notification, err := models.CreateNotification(ms.db.WithContext(notifyCtx), uid, monitor.ID, notifType, message)
+					if err != nil {
+						log.Printf("Error creating notification: %v", err)
+						continue
+					}
 					// Broadcast notification
 					notificationData := map[string]interface{}{
 						"id":         notification.ID,
@@ -188,27 +345,100 @@ func (ms *MonitorService) checkMonitor(monitor *models.Monitor) {
 						"message":    message,
 						"created_at": notification.CreatedAt,
 					}
-					ms.hub.BroadcastToUser(monitor.UserID, "notification", notificationData)
-
-					// Update last notification time
-					ms.updateLastNotificationTime(monitor.ID)
+					ms.hub.BroadcastToUser(uid, "notification", notificationData)
 				}
+
+				// Update last notification time
+				ms.updateLastNotificationTime(monitor.ID)
+				span.End()
 			}
 		}
 	}
 
-	log.Printf("Checked %s (%s): %s - %dms", monitor.Name, monitor.Type, status, latencyMs)
+	log.Printf("Checked %s (%s) from %s: %s - %dms", monitor.Name, monitor.Type, location, result.Status, latencyMs)
+}
+
+// locationStatus records the result of a monitor checked from several
+// locations and decides its status: down once MinFailedLocations of the
+// locations with a recent result see it down, up otherwise. With fewer recent
+// locations than that the status is unknown and the previous one is kept.
+func (ms *MonitorService) locationStatus(db *gorm.DB, monitor *models.Monitor, location string, result probe.Result) string {
+	if len(monitor.Locations) == 0 {
+		return result.Status
+	}
+
+	now := time.Now()
+	latest := models.MonitorLocationStatus{
+		MonitorID: monitor.ID,
+		Location:  location,
+		Status:    result.Status,
+		LatencyMs: result.LatencyMs,
+		ErrorMsg:  result.ErrorMsg,
+		CheckedAt: now,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "monitor_id"}, {Name: "location"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "latency_ms", "error_msg", "checked_at"}),
+	}).Create(&latest).Error; err != nil {
+		log.Printf("Error saving location status: %v", err)
+		return result.Status
+	}
+
+	// Locations that stopped reporting no longer count
+	fresh := 3 * time.Duration(monitor.IntervalSeconds) * time.Second
+	if fresh < 90*time.Second {
+		fresh = 90 * time.Second
+	}
+	var statuses []models.MonitorLocationStatus
+	if err := db.Where("monitor_id = ? AND location IN ? AND checked_at >= ?", monitor.ID, []string(monitor.Locations), now.Add(-fresh)).
+		Find(&statuses).Error; err != nil {
+		log.Printf("Error reading location statuses: %v", err)
+		return result.Status
+	}
+
+	needed := monitor.MinFailedLocations
+	if needed < 1 {
+		needed = 1
+	}
+	if len(statuses) < needed {
+		if monitor.Status == "" {
+			return "pending"
+		}
+		return monitor.Status
+	}
+	failing := 0
+	for _, s := range statuses {
+		if s.Status == "down" {
+			failing++
+		}
+	}
+	if failing >= needed {
+		return "down"
+	}
+	return "up"
+}
+
+// recipients returns the users who should receive live updates for a monitor:
+// its owner, or every member when it belongs to a team
+func (ms *MonitorService) recipients(monitor *models.Monitor) []uint {
+	if monitor.TeamID == nil {
+		return []uint{monitor.UserID}
+	}
+	ids, err := models.TeamMemberIDs(ms.db, *monitor.TeamID)
+	if err != nil || len(ids) == 0 {
+		return []uint{monitor.UserID}
+	}
+	return ids
 }
 
 // deriveIncidentID produces a stable incident scope per monitor
 func deriveIncidentID(monitorID uint) string { return fmt.Sprintf("monitor-%d", monitorID) }
 
-func (ms *MonitorService) captureDowntimeScreenshot(m *models.Monitor) {
+func (ms *MonitorService) captureDowntimeScreenshot(ctx context.Context, m *models.Monitor) {
     if m.Type != "http" {
         return
     }
-    ss := NewScreenshotService(ms.db, ms.hub)
-    if err := ss.CaptureAndStore(m.UserID, deriveIncidentID(m.ID), m.Endpoint, m.ID); err != nil {
+    if err := ms.screenshots.CaptureAndStore(ctx, m.UserID, deriveIncidentID(m.ID), m.Endpoint, m.ID); err != nil {
         log.Printf("Screenshot capture failed: %v", err)
     }
 }
@@ -222,8 +452,8 @@ func (ms *MonitorService) shouldCreateNotification(monitorID uint, status string
 	lastTime, exists := lastNotificationTime[monitorID]
 	notificationMutex.RUnlock()
 
-	// Don't spam - minimum 5 minutes between notifications for same monitor
-	if exists && time.Since(lastTime) < 5*time.Minute {
+	// Don't spam - a minimum time between notifications for same monitor
+	if exists && time.Since(lastTime) < ms.settings().NotificationSuppression {
 		return false
 	}
 
@@ -236,254 +466,10 @@ func (ms *MonitorService) updateLastNotificationTime(monitorID uint) {
 	notificationMutex.Unlock()
 }
 
-func (ms *MonitorService) checkHTTP(monitor *models.Monitor) (string, int64, int, string) {
-	// Create context with timeout
-	timeout := time.Duration(monitor.Timeout) * time.Second
-	if timeout == 0 {
-		timeout = 10 * time.Second
-	}
-	
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Create HTTP client with proper configuration
-	client := &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout:   5 * time.Second,
-			ResponseHeaderTimeout: 5 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Allow up to 10 redirects
-			if len(via) >= 10 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
-	}
-
-	method := monitor.Method
-	if method == "" {
-		method = "GET"
-	}
-
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, method, monitor.Endpoint, nil)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"monitor_id": monitor.ID,
-			"endpoint":   monitor.Endpoint,
-			"error":      err.Error(),
-		}).Error("Failed to create HTTP request")
-		return "down", 0, 0, fmt.Sprintf("Invalid endpoint: %v", err)
-	}
-
-	// Add headers
-	req.Header.Set("User-Agent", "RunnerX-Monitor/1.0")
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Connection", "close")
-
-	// Add custom headers if provided
-	if monitor.HeadersJSON != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(monitor.HeadersJSON), &headers); err == nil {
-			for key, value := range headers {
-				req.Header.Set(key, value)
-			}
-		}
-	}
-
-	startTime := time.Now()
-	resp, err := client.Do(req)
-	latencyMs := time.Since(startTime).Milliseconds()
-
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"monitor_id": monitor.ID,
-			"endpoint":   monitor.Endpoint,
-			"latency_ms": latencyMs,
-			"error":      err.Error(),
-		}).Warn("HTTP check failed")
-		return "down", latencyMs, 0, err.Error()
-	}
-	defer resp.Body.Close()
-
-	// Read response body (limited) for better error reporting
-	var bodyPreview string
-	if resp.Body != nil {
-		bodyBytes := make([]byte, 512) // Read first 512 bytes
-		n, _ := io.ReadFull(resp.Body, bodyBytes)
-		if n > 0 {
-			bodyPreview = string(bodyBytes[:n])
-		}
-	}
-
-	// Determine status based on response code
-	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		logrus.WithFields(logrus.Fields{
-			"monitor_id":  monitor.ID,
-			"endpoint":    monitor.Endpoint,
-			"status_code": resp.StatusCode,
-			"latency_ms":  latencyMs,
-		}).Info("HTTP check successful")
-		return "up", latencyMs, resp.StatusCode, bodyPreview
-	}
-
-	errorMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	if bodyPreview != "" {
-		errorMsg += fmt.Sprintf(" - %s", strings.TrimSpace(bodyPreview))
-	}
-
-	logrus.WithFields(logrus.Fields{
-		"monitor_id":  monitor.ID,
-		"endpoint":    monitor.Endpoint,
-		"status_code": resp.StatusCode,
-		"latency_ms":  latencyMs,
-		"error":       errorMsg,
-	}).Warn("HTTP check failed with error status")
-
-	return "down", latencyMs, resp.StatusCode, errorMsg
-}
-
-func (ms *MonitorService) checkPing(monitor *models.Monitor) (string, int64, string) {
-	startTime := time.Now()
-	
-	// Extract hostname/IP from endpoint
-	endpoint := strings.TrimPrefix(monitor.Endpoint, "http://")
-	endpoint = strings.TrimPrefix(endpoint, "https://")
-	endpoint = strings.Split(endpoint, "/")[0]
-	endpoint = strings.Split(endpoint, ":")[0]
-
-	// Create context with timeout
-	timeout := time.Duration(monitor.Timeout) * time.Second
-	if timeout == 0 {
-		timeout = 5 * time.Second
-	}
-	
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Use ping command with proper timeout
-	cmd := exec.CommandContext(ctx, "ping", "-c", "1", "-W", "5", endpoint)
-	err := cmd.Run()
-	latencyMs := time.Since(startTime).Milliseconds()
-
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"monitor_id": monitor.ID,
-			"endpoint":   endpoint,
-			"latency_ms": latencyMs,
-			"error":      err.Error(),
-		}).Warn("Ping check failed")
-		return "down", latencyMs, err.Error()
-	}
-
-	logrus.WithFields(logrus.Fields{
-		"monitor_id": monitor.ID,
-		"endpoint":   endpoint,
-		"latency_ms": latencyMs,
-	}).Info("Ping check successful")
-
-	return "up", latencyMs, ""
-}
-
-func (ms *MonitorService) checkTCP(monitor *models.Monitor) (string, int64, string) {
-	startTime := time.Now()
-	
-	// Extract host and port from endpoint
-	endpoint := strings.TrimPrefix(monitor.Endpoint, "http://")
-	endpoint = strings.TrimPrefix(endpoint, "https://")
-	endpoint = strings.Split(endpoint, "/")[0]
-	
-	// Default port if not specified
-	if !strings.Contains(endpoint, ":") {
-		endpoint += ":80"
-	}
-
-	// Create context with timeout
-	timeout := time.Duration(monitor.Timeout) * time.Second
-	if timeout == 0 {
-		timeout = 5 * time.Second
-	}
-	
-	// Attempt TCP connection
-	conn, err := net.DialTimeout("tcp", endpoint, timeout)
-	latencyMs := time.Since(startTime).Milliseconds()
-
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"monitor_id": monitor.ID,
-			"endpoint":   endpoint,
-			"latency_ms": latencyMs,
-			"error":      err.Error(),
-		}).Warn("TCP check failed")
-		return "down", latencyMs, err.Error()
-	}
-	defer conn.Close()
-
-	logrus.WithFields(logrus.Fields{
-		"monitor_id": monitor.ID,
-		"endpoint":   endpoint,
-		"latency_ms": latencyMs,
-	}).Info("TCP check successful")
-
-	return "up", latencyMs, ""
-}
-
-func (ms *MonitorService) checkDNS(monitor *models.Monitor) (string, int64, string) {
-	startTime := time.Now()
-	
-	// Extract hostname from endpoint
-	hostname := strings.TrimPrefix(monitor.Endpoint, "http://")
-	hostname = strings.TrimPrefix(hostname, "https://")
-	hostname = strings.Split(hostname, "/")[0]
-	hostname = strings.Split(hostname, ":")[0]
-
-	// Create context with timeout
-	timeout := time.Duration(monitor.Timeout) * time.Second
-	if timeout == 0 {
-		timeout = 5 * time.Second
-	}
-	
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Perform DNS lookup
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: timeout,
-			}
-			return d.DialContext(ctx, network, address)
-		},
-	}
-
-	_, err := resolver.LookupHost(ctx, hostname)
-	latencyMs := time.Since(startTime).Milliseconds()
-
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"monitor_id": monitor.ID,
-			"hostname":   hostname,
-			"latency_ms": latencyMs,
-			"error":      err.Error(),
-		}).Warn("DNS check failed")
-		return "down", latencyMs, err.Error()
-	}
-
-	logrus.WithFields(logrus.Fields{
-		"monitor_id": monitor.ID,
-		"hostname":   hostname,
-		"latency_ms": latencyMs,
-	}).Info("DNS check successful")
-
-	return "up", latencyMs, ""
+// checkPush runs only when a push monitor's interval elapsed without a heartbeat,
+// since every heartbeat resets LastCheckAt
+func (ms *MonitorService) checkPush(monitor *models.Monitor) (string, string) {
+	return "down", fmt.Sprintf("No heartbeat received in the last %ds", monitor.IntervalSeconds)
 }
 
 // sanitizeSnapshot removes scripts and inline events from HTML/text
@@ -502,14 +488,39 @@ func sanitizeSnapshot(s string) string {
 }
 
 // deriveRootCause infers a coarse cause classification for failed checks
-func deriveRootCause(monitorType, status string, statusCode int, errMsg string) (string, string) {
+func deriveRootCause(monitorType, status string, statusCode int, errMsg string, timing *models.CheckTiming) (string, string) {
     if status == "up" {
         return "", ""
     }
     // HTTP codes
     if monitorType == "http" && statusCode > 0 {
+        if timing != nil && timing.TTFBMs > 0 {
+            return "http_error", fmt.Sprintf("HTTP %d after waiting %dms for the response", statusCode, timing.TTFBMs)
+        }
         return "http_error", fmt.Sprintf("HTTP %d", statusCode)
     }
+    // The phase an HTTP check failed in tells where the problem is
+    if timing != nil && timing.FailedPhase != "" {
+        switch timing.FailedPhase {
+        case models.PhaseDNS:
+            return "dns_error", fmt.Sprintf("DNS lookup failed after %dms: %s", timing.DNSMs, errMsg)
+        case models.PhaseConnect:
+            lower := strings.ToLower(errMsg)
+            switch {
+            case strings.Contains(lower, "refused"):
+                return "tcp_error", "Connection refused"
+            case strings.Contains(lower, "timeout") || strings.Contains(lower, "deadline"):
+                return "connection_timeout", fmt.Sprintf("No connection after %dms", timing.ConnectMs)
+            }
+            return "tcp_error", errMsg
+        case models.PhaseTLS:
+            return "ssl_error", fmt.Sprintf("TLS handshake failed after %dms: %s", timing.TLSMs, errMsg)
+        case models.PhaseTTFB:
+            return "response_timeout", fmt.Sprintf("Connected to %s, no response after %dms", timing.RemoteIP, timing.TTFBMs)
+        case models.PhaseTransfer:
+            return "transfer_error", fmt.Sprintf("Response interrupted after %dms: %s", timing.TransferMs, errMsg)
+        }
+    }
     // Error message heuristics
     lower := strings.ToLower(errMsg)
     switch {
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"runnerx/models"
+	"runnerx/probe"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -70,6 +73,8 @@ func (mcs *MonitoringConfigService) validateEndpoint(endpoint, monitorType strin
 		if !mcs.isValidDNSEndpoint(endpoint) {
 			return fmt.Errorf("invalid DNS endpoint format")
 		}
+	case "push":
+		// Push monitors receive heartbeats; the endpoint is only a label
 	default:
 		return fmt.Errorf("unsupported monitor type: %s", monitorType)
 	}
@@ -78,7 +83,7 @@ func (mcs *MonitoringConfigService) validateEndpoint(endpoint, monitorType strin
 
 // isValidHTTPEndpoint validates HTTP/HTTPS URLs
 func (mcs *MonitoringConfigService) isValidHTTPEndpoint(endpoint string) bool {
-	return len(endpoint) > 0 && (endpoint[:4] == "http" || endpoint[:5] == "https")
+	return strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")
 }
 
 // isValidPingEndpoint validates ping targets (hostname or IP)
@@ -143,6 +148,8 @@ func (mcs *MonitoringConfigService) GetOptimalInterval(monitorType string) int {
 		return 60 // 1 minute for TCP
 	case "dns":
 		return 300 // 5 minutes for DNS
+	case "push":
+		return 300 // 5 minutes between heartbeats
 	default:
 		return 60
 	}
@@ -165,31 +172,17 @@ func (mcs *MonitoringConfigService) GetOptimalTimeout(monitorType string) int {
 }
 
 // TestMonitorConnection tests the monitor connection before saving
-func (mcs *MonitoringConfigService) TestMonitorConnection(monitor *models.Monitor) (bool, string, int64, error) {
-	// Create a temporary monitor service for testing
-	monitorService := &MonitorService{db: mcs.DB}
-	
-	var status string
-	var latencyMs int64
-	var errorMsg string
-
-	switch monitor.Type {
-	case "http":
-		var statusCode int
-		status, latencyMs, statusCode, errorMsg = monitorService.checkHTTP(monitor)
-		_ = statusCode // Use statusCode to avoid unused variable warning
-	case "ping":
-		status, latencyMs, errorMsg = monitorService.checkPing(monitor)
-	case "tcp":
-		status, latencyMs, errorMsg = monitorService.checkTCP(monitor)
-	case "dns":
-		status, latencyMs, errorMsg = monitorService.checkDNS(monitor)
-	default:
+func (mcs *MonitoringConfigService) TestMonitorConnection(ctx context.Context, monitor *models.Monitor) (bool, string, int64, error) {
+	switch {
+	case monitor.Type == "push":
+		return false, "Waiting for the first heartbeat", 0, nil
+	case !probe.Supports(monitor.Type):
 		return false, "", 0, fmt.Errorf("unsupported monitor type: %s", monitor.Type)
 	}
+	result := probe.Run(ctx, monitor)
 
-	isOnline := status == "up"
-	return isOnline, errorMsg, latencyMs, nil
+	isOnline := result.Status == "up"
+	return isOnline, result.ErrorMsg, result.LatencyMs, nil
 }
 
 // GetMonitorHealthStatus provides detailed health status for a monitor
@@ -267,12 +260,19 @@ type MonitorHealthStatus struct {
 	LastLatencyMs    *int64    `json:"last_latency_ms"`
 }
 
-// LogMonitorEvent logs important monitor events
-func (mcs *MonitoringConfigService) LogMonitorEvent(monitorID uint, eventType, message string) {
+// LogMonitorEvent logs important monitor events and records them in the audit
+// trail as "monitor.<eventType>"
+func (mcs *MonitoringConfigService) LogMonitorEvent(actor models.AuditActor, monitorID uint, eventType, message string) {
 	logrus.WithFields(logrus.Fields{
 		"monitor_id": monitorID,
 		"event_type": eventType,
 		"message":    message,
 		"timestamp":  time.Now(),
 	}).Info("Monitor event")
+
+	var resourceID interface{}
+	if monitorID != 0 {
+		resourceID = monitorID
+	}
+	models.RecordAudit(mcs.DB, actor, "monitor."+eventType, "monitor", resourceID, true, map[string]string{"message": message})
 }
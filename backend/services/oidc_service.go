@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"runnerx/config"
+	"runnerx/models"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCDisabled     = errors.New("OIDC login is not configured")
+	ErrOIDCInvalidState = errors.New("login attempt expired or is unknown")
+	ErrOIDCUnverified   = errors.New("the identity provider did not verify the email address")
+)
+
+// OIDCService runs the authorization code flow with PKCE against the
+// configured identity provider and provisions users on first login
+type OIDCService struct {
+	db  *gorm.DB
+	cfg config.OIDCConfig
+
+	// Discovery happens on first use so the server starts even while the
+	// identity provider is unreachable
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	client   *http.Client
+}
+
+// OIDCClaims are the ID token claims RunnerX uses
+type OIDCClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+}
+
+func NewOIDCService(db *gorm.DB, cfg config.OIDCConfig) *OIDCService {
+	return &OIDCService{
+		db:     db,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled reports whether OIDC login is configured
+func (s *OIDCService) Enabled() bool {
+	return s.cfg.Enabled()
+}
+
+func (s *OIDCService) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrOIDCDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oauth != nil {
+		return s.oauth, s.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, s.client), s.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+
+	s.oauth = &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       s.cfg.Scopes,
+	}
+	s.verifier = provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID})
+	return s.oauth, s.verifier, nil
+}
+
+// CallbackURL is the redirect URL registered with the identity provider
+func (s *OIDCService) CallbackURL() string {
+	return s.cfg.RedirectURL
+}
+
+// LoginURL starts a login attempt and returns the identity provider URL to
+// send the browser to, along with the binding secret the browser must present
+// at the callback. responseMode is "redirect" or "json" and decides how the
+// callback hands over the tokens.
+func (s *OIDCService) LoginURL(ctx context.Context, responseMode string) (string, string, error) {
+	oauth, _, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	binding, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	login := models.OIDCLogin{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		BindingHash:  models.HashToken(binding),
+		ResponseMode: responseMode,
+		ExpiresAt:    time.Now().Add(models.OIDCLoginTTL),
+	}
+	if err := s.db.Create(&login).Error; err != nil {
+		return "", "", err
+	}
+
+	// Opportunistically drop abandoned attempts
+	s.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLogin{})
+
+	return oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)), binding, nil
+}
+
+// Callback completes a login attempt: it exchanges the code, verifies the ID
+// token and returns the matching user, creating or linking it when needed. The
+// attempt must be completed by the browser that started it, which proves it
+// with the binding returned by LoginURL. The consumed attempt is returned
+// whenever it was found, even alongside an error.
+func (s *OIDCService) Callback(ctx context.Context, state, code, binding string) (*models.User, *models.OIDCLogin, error) {
+	oauth, verifier, err := s.discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Each attempt can only be used once, and only by the browser that
+	// started it; a callback URL replayed in another browser leaves the
+	// attempt untouched
+	var login models.OIDCLogin
+	if err := s.db.Where("state = ? AND expires_at > ?", state, time.Now()).First(&login).Error; err != nil {
+		return nil, nil, ErrOIDCInvalidState
+	}
+	if binding == "" || subtle.ConstantTimeCompare([]byte(models.HashToken(binding)), []byte(login.BindingHash)) != 1 {
+		return nil, nil, ErrOIDCInvalidState
+	}
+	if result := s.db.Delete(&login); result.Error != nil || result.RowsAffected == 0 {
+		return nil, nil, ErrOIDCInvalidState
+	}
+
+	ctx = oidc.ClientContext(ctx, s.client)
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		return nil, &login, fmt.Errorf("code exchange failed: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, &login, errors.New("token response did not include an ID token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, &login, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	var claims OIDCClaims
+	var allClaims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, &login, err
+	}
+	if err := idToken.Claims(&allClaims); err != nil {
+		return nil, &login, err
+	}
+	if claims.Nonce != login.Nonce {
+		return nil, &login, errors.New("ID token nonce mismatch")
+	}
+
+	user, err := s.provisionUser(claims, s.mapRole(allClaims))
+	if err != nil {
+		return nil, &login, err
+	}
+	return user, &login, nil
+}
+
+// provisionUser finds the user linked to the subject, links an existing
+// account with the same email, or creates a new account. Linking and creating
+// both require the provider to have verified the email.
+func (s *OIDCService) provisionUser(claims OIDCClaims, role string) (*models.User, error) {
+	var user models.User
+	err := s.db.Where("oidc_subject = ?", claims.Subject).First(&user).Error
+	if err == nil {
+		updates := map[string]interface{}{}
+		if role != "" && role != user.Role {
+			updates["role"] = role
+		}
+		if len(updates) > 0 {
+			if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+				return nil, err
+			}
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		return nil, errors.New("the identity provider did not return an email address")
+	}
+	if !claims.EmailVerified {
+		return nil, ErrOIDCUnverified
+	}
+
+	subject := claims.Subject
+	if err := s.db.Where("LOWER(email) = ?", email).First(&user).Error; err == nil {
+		updates := map[string]interface{}{"oidc_subject": subject}
+		if !user.EmailVerified() {
+			updates["email_verified_at"] = time.Now()
+		}
+		if role != "" {
+			updates["role"] = role
+		}
+		if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+
+	// SSO users never use the password, so give them a random one
+	password, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	if name == "" {
+		name = email
+	}
+	if role == "" {
+		role = s.cfg.DefaultRole
+	}
+
+	now := time.Now()
+	user = models.User{
+		Name:            name,
+		Email:           email,
+		Password:        password,
+		Role:            role,
+		OIDCSubject:     &subject,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// mapRole translates the configured role claim into a RunnerX role. It returns
+// "" when no mapping is configured so existing roles are left alone. When the
+// claim holds several mapped values, admin wins.
+func (s *OIDCService) mapRole(claims map[string]interface{}) string {
+	if s.cfg.RoleClaim == "" || len(s.cfg.RoleMapping) == 0 {
+		return ""
+	}
+
+	var values []string
+	switch v := claims[s.cfg.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+
+	role := s.cfg.DefaultRole
+	for _, value := range values {
+		mapped, ok := s.cfg.RoleMapping[value]
+		if !ok {
+			continue
+		}
+		if mapped == models.UserRoleAdmin {
+			return mapped
+		}
+		role = mapped
+	}
+	return role
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -5,9 +5,13 @@ import (
     "fmt"
     "os"
     "path/filepath"
+    "sync"
     "time"
 
     "github.com/chromedp/chromedp"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/trace"
     "gorm.io/gorm"
     "runnerx/models"
     ws "runnerx/websocket"
@@ -16,13 +20,36 @@ import (
 type ScreenshotService struct {
     db  *gorm.DB
     hub *ws.Hub
+
+    mu      sync.RWMutex
+    timeout time.Duration
+}
+
+func NewScreenshotService(db *gorm.DB, hub *ws.Hub, timeout time.Duration) *ScreenshotService {
+    return &ScreenshotService{db: db, hub: hub, timeout: timeout}
 }
 
-func NewScreenshotService(db *gorm.DB, hub *ws.Hub) *ScreenshotService {
-    return &ScreenshotService{db: db, hub: hub}
+// SetTimeout changes the time allowed for the captures that follow
+func (s *ScreenshotService) SetTimeout(timeout time.Duration) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.timeout = timeout
 }
 
-func (s *ScreenshotService) CaptureAndStore(userID uint, incidentID string, url string, monitorID uint) error {
+// CaptureAndStore screenshots url for an incident, as a span of the trace in ctx
+func (s *ScreenshotService) CaptureAndStore(ctx context.Context, userID uint, incidentID string, url string, monitorID uint) (err error) {
+    ctx, span := tracer.Start(ctx, "screenshot", trace.WithAttributes(
+        attribute.Int("monitor.id", int(monitorID)),
+        attribute.String("url.full", url),
+    ))
+    defer func() {
+        if err != nil {
+            span.RecordError(err)
+            span.SetStatus(codes.Error, err.Error())
+        }
+        span.End()
+    }()
+
     // Headless browser screenshot using chromedp
     // Allow custom Chrome executable via CHROME_PATH
     execPath := os.Getenv("CHROME_PATH")
@@ -32,12 +59,14 @@ func (s *ScreenshotService) CaptureAndStore(userID uint, incidentID string, url
     }
     allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
     defer allocCancel()
-    ctx, cancel := chromedp.NewContext(allocCtx)
+    browserCtx, cancel := chromedp.NewContext(allocCtx)
     defer cancel()
 
     var buf []byte
-    // 10s timeout
-    cctx, ccancel := context.WithTimeout(ctx, 10*time.Second)
+    s.mu.RLock()
+    timeout := s.timeout
+    s.mu.RUnlock()
+    cctx, ccancel := context.WithTimeout(browserCtx, timeout)
     defer ccancel()
 
     if err := chromedp.Run(cctx,
@@ -65,9 +94,9 @@ func (s *ScreenshotService) CaptureAndStore(userID uint, incidentID string, url
         MonitorID: monitorID,
         Path: path,
     }
-    if err := s.db.Create(&rec).Error; err != nil { return err }
+    if err := s.db.WithContext(ctx).Create(&rec).Error; err != nil { return err }
 
-    s.hub.BroadcastToUser(userID, "incident:screenshot", map[string]interface{}{
+    s.hub.BroadcastToUserTopic(userID, ws.IncidentTopic(incidentID), "incident:screenshot", map[string]interface{}{
         "incident_id": incidentID,
         "path": path,
     })
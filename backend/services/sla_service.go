@@ -1,6 +1,7 @@
 package services
 
 import (
+    "errors"
     "time"
     "runnerx/models"
     "gorm.io/gorm"
@@ -66,6 +67,7 @@ func (s *SLAService) CalculateSLAForMonitor(userID, monitorID uint, reportDate t
 
     slaReport := &models.SLAReport{
         UserID:          userID,
+        TeamID:          monitor.TeamID,
         MonitorID:       monitorID,
         ReportDate:      startDate,
         UptimePercent:   uptimePercent,
@@ -78,6 +80,84 @@ func (s *SLAService) CalculateSLAForMonitor(userID, monitorID uint, reportDate t
     return slaReport, nil
 }
 
+// errNoGroupHistory means a group had no status at all during a report period
+var errNoGroupHistory = errors.New("no group history for the period")
+
+// CalculateSLAForGroup calculates SLA metrics for a monitor group from its
+// status history. Downtime is the time the group spent down; the period
+// starts at the group's first recorded status if that is later.
+func (s *SLAService) CalculateSLAForGroup(groupID uint, reportDate time.Time) (*models.SLAReport, error) {
+    var group models.MonitorGroup
+    if err := s.DB.First(&group, groupID).Error; err != nil {
+        return nil, err
+    }
+
+    startDate := reportDate.Truncate(24 * time.Hour)
+    endDate := startDate.Add(24 * time.Hour)
+    if now := time.Now(); endDate.After(now) {
+        endDate = now
+    }
+
+    // The status in force when the period starts
+    var history []models.MonitorGroupCheck
+    var previous models.MonitorGroupCheck
+    if err := s.DB.Where("group_id = ? AND created_at < ?", groupID, startDate).
+        Order("created_at DESC").First(&previous).Error; err == nil {
+        previous.CreatedAt = startDate
+        history = append(history, previous)
+    }
+    var checks []models.MonitorGroupCheck
+    if err := s.DB.Where("group_id = ? AND created_at >= ? AND created_at < ?", groupID, startDate, endDate).
+        Order("created_at ASC").Find(&checks).Error; err != nil {
+        return nil, err
+    }
+    history = append(history, checks...)
+    if len(history) == 0 {
+        return nil, errNoGroupHistory
+    }
+
+    var downtime time.Duration
+    slaViolations := 0
+    for i, entry := range history {
+        until := endDate
+        if i+1 < len(history) {
+            until = history[i+1].CreatedAt
+        }
+        if entry.Status != models.GroupStatusDown {
+            continue
+        }
+        downtime += until.Sub(entry.CreatedAt)
+        if i == 0 || history[i-1].Status != models.GroupStatusDown {
+            slaViolations++
+        }
+    }
+
+    slaThreshold := 99.9
+    uptimePercent := 100.0
+    if period := endDate.Sub(history[0].CreatedAt); period > 0 {
+        uptimePercent = float64(period-downtime) / float64(period) * 100
+    }
+
+    status := "compliant"
+    if uptimePercent < slaThreshold {
+        status = "violation"
+    } else if uptimePercent < slaThreshold+0.5 {
+        status = "warning"
+    }
+
+    return &models.SLAReport{
+        UserID:          group.UserID,
+        TeamID:          group.TeamID,
+        GroupID:         &group.ID,
+        ReportDate:      startDate,
+        UptimePercent:   uptimePercent,
+        DowntimeMinutes: int64(downtime.Minutes()),
+        SLAViolations:   slaViolations,
+        SLAThreshold:    slaThreshold,
+        Status:          status,
+    }, nil
+}
+
 // calculateIncidentDuration calculates the duration of an incident in minutes
 func (s *SLAService) calculateIncidentDuration(incidentID uint) int64 {
     var events []models.IncidentEvent
@@ -134,16 +214,34 @@ func (s *SLAService) GenerateDailySLAReports() error {
         }
     }
 
+    // Monitor groups
+    var groups []models.MonitorGroup
+    if err := s.DB.Find(&groups).Error; err != nil {
+        return err
+    }
+    for _, group := range groups {
+        var count int64
+        s.DB.Model(&models.SLAReport{}).Where("group_id = ? AND report_date = ?", group.ID, reportDate).Count(&count)
+        if count > 0 {
+            continue
+        }
+        report, err := s.CalculateSLAForGroup(group.ID, reportDate)
+        if err != nil {
+            continue
+        }
+        s.DB.Create(report)
+    }
+
     return nil
 }
 
-// GetSLAReportsForUser gets SLA reports for a specific user
-func (s *SLAService) GetSLAReportsForUser(userID uint, days int) ([]models.SLAReport, error) {
+// GetSLAReports gets SLA reports within a workspace scope (a user or a team)
+func (s *SLAService) GetSLAReports(scope func(*gorm.DB) *gorm.DB, days int) ([]models.SLAReport, error) {
     var reports []models.SLAReport
     
     startDate := time.Now().AddDate(0, 0, -days)
     
-    if err := s.DB.Where("user_id = ? AND report_date >= ?", userID, startDate).
+    if err := s.DB.Scopes(scope).Where("group_id IS NULL AND report_date >= ?", startDate).
         Order("report_date DESC").Find(&reports).Error; err != nil {
         return nil, err
     }
@@ -151,14 +249,28 @@ func (s *SLAService) GetSLAReportsForUser(userID uint, days int) ([]models.SLARe
     return reports, nil
 }
 
-// GetSLAReportsForMonitor gets SLA reports for a specific monitor
-func (s *SLAService) GetSLAReportsForMonitor(userID, monitorID uint, days int) ([]models.SLAReport, error) {
+// GetSLAReportsForMonitor gets SLA reports for a specific monitor within a workspace scope
+func (s *SLAService) GetSLAReportsForMonitor(scope func(*gorm.DB) *gorm.DB, monitorID uint, days int) ([]models.SLAReport, error) {
     var reports []models.SLAReport
     
     startDate := time.Now().AddDate(0, 0, -days)
     
-    if err := s.DB.Where("user_id = ? AND monitor_id = ? AND report_date >= ?", 
-        userID, monitorID, startDate).Order("report_date DESC").Find(&reports).Error; err != nil {
+    if err := s.DB.Scopes(scope).Where("monitor_id = ? AND report_date >= ?", 
+        monitorID, startDate).Order("report_date DESC").Find(&reports).Error; err != nil {
+        return nil, err
+    }
+
+    return reports, nil
+}
+
+// GetSLAReportsForGroup gets SLA reports for a monitor group within a workspace scope
+func (s *SLAService) GetSLAReportsForGroup(scope func(*gorm.DB) *gorm.DB, groupID uint, days int) ([]models.SLAReport, error) {
+    var reports []models.SLAReport
+
+    startDate := time.Now().AddDate(0, 0, -days)
+
+    if err := s.DB.Scopes(scope).Where("group_id = ? AND report_date >= ?",
+        groupID, startDate).Order("report_date DESC").Find(&reports).Error; err != nil {
         return nil, err
     }
 
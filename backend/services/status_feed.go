@@ -0,0 +1,217 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"runnerx/models"
+)
+
+// feedUpdates is the number of incident updates included in a feed
+const feedUpdates = 50
+
+// FeedEntry is one incident update as published in the feeds
+type FeedEntry struct {
+	ID        uint
+	Title     string
+	Message   string
+	Published time.Time
+}
+
+// FeedEntries returns the latest incident updates of a page, newest first
+func (s *StatusPageService) FeedEntries(pageID uint) ([]FeedEntry, error) {
+	var rows []struct {
+		ID        uint
+		Title     string
+		Status    string
+		Message   string
+		CreatedAt time.Time
+	}
+	err := s.db.Model(&models.StatusPageIncidentUpdate{}).
+		Select("status_page_incident_updates.id, status_page_incidents.title, status_page_incident_updates.status, "+
+			"status_page_incident_updates.message, status_page_incident_updates.created_at").
+		Joins("JOIN status_page_incidents ON status_page_incidents.id = status_page_incident_updates.incident_id").
+		Where("status_page_incidents.status_page_id = ?", pageID).
+		Order("status_page_incident_updates.created_at DESC").Limit(feedUpdates).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]FeedEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = FeedEntry{
+			ID:        row.ID,
+			Title:     fmt.Sprintf("%s (%s)", row.Title, row.Status),
+			Message:   row.Message,
+			Published: row.CreatedAt,
+		}
+	}
+	return entries, nil
+}
+
+// MaintenanceCalendar returns the maintenance windows worth putting in a
+// calendar: those that ended in the last 30 days or are still to come
+func (s *StatusPageService) MaintenanceCalendar(pageID uint) ([]models.StatusPageMaintenance, error) {
+	var maintenances []models.StatusPageMaintenance
+	err := s.db.Where("status_page_id = ? AND ends_at >= ?", pageID, time.Now().AddDate(0, 0, -30)).
+		Order("starts_at").Find(&maintenances).Error
+	return maintenances, err
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// RenderRSS writes the entries as an RSS 2.0 feed
+func RenderRSS(page *models.StatusPage, pageURL string, entries []FeedEntry) ([]byte, error) {
+	feed := rssFeed{Version: "2.0", Channel: rssChannel{
+		Title:       page.Name + " status",
+		Link:        pageURL,
+		Description: "Incident updates for " + page.Name,
+	}}
+	for _, e := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        pageURL,
+			Description: e.Message,
+			GUID:        rssGUID{Value: entryID(page, e)},
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshalFeed(feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Content string   `xml:"content"`
+}
+
+// RenderAtom writes the entries as an Atom feed
+func RenderAtom(page *models.StatusPage, pageURL string, entries []FeedEntry) ([]byte, error) {
+	updated := page.UpdatedAt
+	if len(entries) > 0 {
+		updated = entries[0].Published
+	}
+	feed := atomFeed{
+		ID:      pageURL,
+		Title:   page.Name + " status",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: pageURL},
+	}
+	for _, e := range entries {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      entryID(page, e),
+			Title:   e.Title,
+			Updated: e.Published.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: pageURL},
+			Content: e.Message,
+		})
+	}
+	return marshalFeed(feed)
+}
+
+func entryID(page *models.StatusPage, e FeedEntry) string {
+	return fmt.Sprintf("tag:runnerx,%s:status-page/%d/update/%d", page.CreatedAt.UTC().Format("2006-01-02"), page.ID, e.ID)
+}
+
+func marshalFeed(feed interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// RenderICal writes the maintenance windows as an iCalendar (RFC 5545) feed
+func RenderICal(page *models.StatusPage, pageURL string, maintenances []models.StatusPageMaintenance) []byte {
+	const stamp = "20060102T150405Z"
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(foldICalLine(fmt.Sprintf(format, args...)))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//RunnerX//Status Page//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:%s", escapeICal(page.Name+" maintenance"))
+	for _, m := range maintenances {
+		line("BEGIN:VEVENT")
+		line("UID:status-page-%d-maintenance-%d@runnerx", page.ID, m.ID)
+		line("DTSTAMP:%s", m.UpdatedAt.UTC().Format(stamp))
+		line("DTSTART:%s", m.StartsAt.UTC().Format(stamp))
+		line("DTEND:%s", m.EndsAt.UTC().Format(stamp))
+		line("SUMMARY:%s", escapeICal(m.Title))
+		if m.Description != "" {
+			line("DESCRIPTION:%s", escapeICal(m.Description))
+		}
+		line("URL:%s", pageURL)
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICal(text string) string {
+	return icalEscaper.Replace(text)
+}
+
+// foldICalLine splits lines longer than 75 octets, continuing them with a
+// leading space, without cutting a UTF-8 sequence
+func foldICalLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"runnerx/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Incident events sent to status page subscribers
+const (
+	IncidentOpened   = "incident.opened"
+	IncidentUpdated  = "incident.updated"
+	IncidentResolved = "incident.resolved"
+)
+
+const webhookTimeout = 10 * time.Second
+
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// StatusNotifier tells the subscribers of status pages about incidents, and
+// sends the confirmation emails of new subscriptions
+type StatusNotifier struct {
+	db     *gorm.DB
+	mailer *Mailer
+	appURL string
+	secret string
+	client *http.Client
+}
+
+// NewStatusNotifier creates a notifier. Unless allowPrivate is set, webhooks
+// can only reach public addresses, since anyone can subscribe one.
+func NewStatusNotifier(db *gorm.DB, mailer *Mailer, appURL, secret string, allowPrivate bool) *StatusNotifier {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip, err := netip.ParseAddr(host); err != nil || !isPublicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: webhookTimeout}
+
+	return &StatusNotifier{
+		db:     db,
+		mailer: mailer,
+		appURL: appURL,
+		secret: secret,
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: transport,
+			// Redirects could lead to an address the dialer would not check
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// nonPublicPrefixes are ranges that are not private by the standard library's
+// definition but still do not reach the public internet, or may be translated
+// into addresses that do not
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may embed a private IPv4 address
+}
+
+func isPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// PageURL is the address of a status page in the frontend
+func (n *StatusNotifier) PageURL(page *models.StatusPage) string {
+	return fmt.Sprintf("%s/status/%s", n.appURL, page.Slug)
+}
+
+// UnsubscribeToken authorizes removing a subscription; it is included in
+// every message sent to the subscriber
+func (n *StatusNotifier) UnsubscribeToken(sub *models.StatusPageSubscriber) string {
+	mac := hmac.New(sha256.New, []byte(n.secret))
+	fmt.Fprintf(mac, "status-unsubscribe:%d", sub.ID)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (n *StatusNotifier) unsubscribeURL(page *models.StatusPage, sub *models.StatusPageSubscriber) string {
+	return fmt.Sprintf("%s?unsubscribe=%d&token=%s", n.PageURL(page), sub.ID, n.UnsubscribeToken(sub))
+}
+
+// SendConfirmation emails the link that confirms a subscription
+func (n *StatusNotifier) SendConfirmation(page *models.StatusPage, sub *models.StatusPageSubscriber, token string) {
+	link := fmt.Sprintf("%s?confirm=%s", n.PageURL(page), url.QueryEscape(token))
+	body := fmt.Sprintf("Someone asked to receive incident updates from the %s status page at this address.\n\n"+
+		"Confirm the subscription within 7 days by opening this link:\n%s\n\n"+
+		"If it was not you, ignore this email.", page.Name, link)
+	n.mailer.SendAsync(sub.Email, fmt.Sprintf("Confirm your subscription to %s status updates", page.Name), body)
+}
+
+// webhookPayload is the JSON body posted to webhook subscribers
+type webhookPayload struct {
+	Event    string                           `json:"event"`
+	Page     map[string]string                `json:"page"`
+	Incident *models.StatusPageIncident       `json:"incident"`
+	Update   *models.StatusPageIncidentUpdate `json:"update"`
+	SentAt   time.Time                        `json:"sent_at"`
+}
+
+// NotifyIncident sends an incident event to every confirmed subscriber
+// interested in the incident's components, in the background. Deliveries are
+// recorded as spans of the trace in ctx.
+func (n *StatusNotifier) NotifyIncident(ctx context.Context, page *models.StatusPage, incident *models.StatusPageIncident, update *models.StatusPageIncidentUpdate, event string) {
+	ctx, span := tracer.Start(ctx, "notify subscribers", trace.WithAttributes(
+		attribute.Int("status_page.id", int(page.ID)),
+		attribute.String("notification.event", event),
+	))
+	defer span.End()
+
+	var subscribers []models.StatusPageSubscriber
+	if err := n.db.WithContext(ctx).Where("status_page_id = ? AND confirmed_at IS NOT NULL", page.ID).Find(&subscribers).Error; err != nil {
+		log.Printf("Failed to read subscribers of status page %d: %v", page.ID, err)
+		return
+	}
+
+	for i := range subscribers {
+		sub := &subscribers[i]
+		if !sub.Wants(incident.Components) {
+			continue
+		}
+		switch sub.Type {
+		case models.SubscriberEmail:
+			n.emailIncident(page, sub, incident, update, event)
+		case models.SubscriberWebhook:
+			go n.postWebhook(ctx, page, sub, incident, update, event)
+		}
+	}
+}
+
+func (n *StatusNotifier) emailIncident(page *models.StatusPage, sub *models.StatusPageSubscriber, incident *models.StatusPageIncident, update *models.StatusPageIncidentUpdate, event string) {
+	var prefix string
+	switch event {
+	case IncidentOpened:
+		prefix = "New incident"
+	case IncidentResolved:
+		prefix = "Resolved"
+	default:
+		prefix = "Update"
+	}
+
+	subject := fmt.Sprintf("[%s] %s: %s", page.Name, prefix, incident.Title)
+	body := fmt.Sprintf("%s\n\n[%s] %s\n\nFollow the incident at %s\n\nUnsubscribe: %s",
+		incident.Title, update.Status, update.Message, n.PageURL(page), n.unsubscribeURL(page, sub))
+	n.mailer.SendAsync(sub.Email, subject, body)
+}
+
+// postWebhook delivers one event. The body is signed with the subscriber's
+// secret in the X-RunnerX-Signature header ("sha256=<hex HMAC>").
+func (n *StatusNotifier) postWebhook(ctx context.Context, page *models.StatusPage, sub *models.StatusPageSubscriber, incident *models.StatusPageIncident, update *models.StatusPageIncidentUpdate, event string) {
+	body, err := json.Marshal(webhookPayload{
+		Event:    event,
+		Page:     map[string]string{"name": page.Name, "slug": page.Slug, "url": n.PageURL(page)},
+		Incident: incident,
+		Update:   update,
+		SentAt:   time.Now(),
+	})
+	if err != nil {
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(sub.WebhookSecret))
+	mac.Write(body)
+
+	ctx, span := tracer.Start(ctx, "POST webhook", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.Int("subscriber.id", int(sub.ID)),
+		attribute.String("url.full", sub.WebhookURL),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Invalid webhook URL for subscriber %d: %v", sub.ID, err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RunnerX-Status/1.0")
+	req.Header.Set("X-RunnerX-Event", event)
+	req.Header.Set("X-RunnerX-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		log.Printf("Webhook delivery to subscriber %d failed: %v", sub.ID, err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 300 {
+		log.Printf("Webhook delivery to subscriber %d failed: HTTP %d", sub.ID, resp.StatusCode)
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
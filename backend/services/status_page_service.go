@@ -0,0 +1,258 @@
+package services
+
+import (
+	"time"
+
+	"runnerx/models"
+
+	"gorm.io/gorm"
+)
+
+// uptimeBarDays is the number of daily bars shown for each component
+const uptimeBarDays = 90
+
+// Overall page statuses
+const (
+	PageStatusOperational = "operational"
+	PageStatusDegraded    = "degraded"
+	PageStatusOutage      = "major_outage"
+	PageStatusMaintenance = "maintenance"
+)
+
+// UptimeDay is one bar of a component's uptime history. UptimePercent is nil
+// for days without any check.
+type UptimeDay struct {
+	Date          string   `json:"date"`
+	UptimePercent *float64 `json:"uptime_percent"`
+}
+
+// StatusComponent is a monitor or group as shown on a public page. It holds
+// no endpoint or other configuration.
+type StatusComponent struct {
+	// Key identifies the component in incidents and subscriptions
+	Key           string      `json:"key"`
+	ID            uint        `json:"id"`
+	Type          string      `json:"type"` // monitor, group
+	Name          string      `json:"name"`
+	Status        string      `json:"status"`
+	UptimePercent float64     `json:"uptime_percent"`
+	LastLatencyMs *int64      `json:"last_latency_ms,omitempty"`
+	UptimeDays    []UptimeDay `json:"uptime_days,omitempty"`
+}
+
+// PublicStatus is everything a visitor of a status page sees
+type PublicStatus struct {
+	Page         *models.StatusPage             `json:"page"`
+	Status       string                         `json:"status"`
+	Components   []StatusComponent              `json:"components"`
+	Incidents    []models.StatusPageIncident    `json:"incidents"`
+	Maintenances []models.StatusPageMaintenance `json:"maintenances"`
+	GeneratedAt  time.Time                      `json:"generated_at"`
+}
+
+type StatusPageService struct {
+	db *gorm.DB
+}
+
+func NewStatusPageService(db *gorm.DB) *StatusPageService {
+	return &StatusPageService{db: db}
+}
+
+// BuildPublicStatus assembles the public view of a page: its components with
+// their current status and daily uptime, unresolved incidents and those
+// resolved in the last week, and maintenance that is ongoing or upcoming
+func (s *StatusPageService) BuildPublicStatus(page *models.StatusPage) (*PublicStatus, error) {
+	now := time.Now()
+	result := &PublicStatus{
+		Page:         page,
+		Components:   []StatusComponent{},
+		Incidents:    []models.StatusPageIncident{},
+		Maintenances: []models.StatusPageMaintenance{},
+		GeneratedAt:  now,
+	}
+
+	var items []models.StatusPageItem
+	if err := s.db.Where("status_page_id = ?", page.ID).Order("position, id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	// Days are UTC, as in the database
+	since := now.UTC().AddDate(0, 0, -uptimeBarDays+1).Truncate(24 * time.Hour)
+
+	for _, item := range items {
+		var component StatusComponent
+		var days map[string]float64
+		var err error
+
+		if item.MonitorID != nil {
+			var monitor models.Monitor
+			if s.db.First(&monitor, *item.MonitorID).Error != nil {
+				continue
+			}
+			component = StatusComponent{
+				ID:            monitor.ID,
+				Type:          "monitor",
+				Name:          monitor.Name,
+				Status:        monitor.Status,
+				UptimePercent: monitor.UptimePercent,
+			}
+			if page.ShowLatency {
+				component.LastLatencyMs = monitor.LastLatencyMs
+			}
+			if page.ShowUptime {
+				days, err = s.monitorUptimeDays(monitor.ID, since)
+			}
+		} else if item.GroupID != nil {
+			var group models.MonitorGroup
+			if s.db.First(&group, *item.GroupID).Error != nil {
+				continue
+			}
+			component = StatusComponent{
+				ID:            group.ID,
+				Type:          "group",
+				Name:          group.Name,
+				Status:        group.Status,
+				UptimePercent: group.UptimePercent,
+			}
+			if page.ShowUptime {
+				days, err = s.groupUptimeDays(group.ID, since)
+			}
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		component.Key = models.ComponentKey(component.Type, component.ID)
+		if item.DisplayName != "" {
+			component.Name = item.DisplayName
+		}
+		if !page.ShowUptime {
+			component.UptimePercent = 0
+		} else {
+			component.UptimeDays = uptimeBars(days, since)
+		}
+		result.Components = append(result.Components, component)
+	}
+
+	if err := s.db.Preload("Updates", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		Where("status_page_id = ? AND (resolved_at IS NULL OR resolved_at >= ?)", page.ID, now.AddDate(0, 0, -7)).
+		Order("created_at DESC").Find(&result.Incidents).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("status_page_id = ? AND ends_at >= ?", page.ID, now).
+		Order("starts_at").Find(&result.Maintenances).Error; err != nil {
+		return nil, err
+	}
+
+	result.Status = overallStatus(result.Components, result.Maintenances, now)
+	return result, nil
+}
+
+// DeleteStatusPages removes pages with everything shown on them
+func DeleteStatusPages(db *gorm.DB, pageIDs []uint) error {
+	if len(pageIDs) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var incidentIDs []uint
+		if err := tx.Model(&models.StatusPageIncident{}).Where("status_page_id IN ?", pageIDs).Pluck("id", &incidentIDs).Error; err != nil {
+			return err
+		}
+		if len(incidentIDs) > 0 {
+			if err := tx.Where("incident_id IN ?", incidentIDs).Delete(&models.StatusPageIncidentUpdate{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{
+			&models.StatusPageIncident{}, &models.StatusPageMaintenance{}, &models.StatusPageItem{}, &models.StatusPageSubscriber{},
+		} {
+			if err := tx.Where("status_page_id IN ?", pageIDs).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("id IN ?", pageIDs).Delete(&models.StatusPage{}).Error
+	})
+}
+
+// overallStatus is a major outage when every component is down, degraded
+// when some are, and maintenance while a window is open and nothing is down
+func overallStatus(components []StatusComponent, maintenances []models.StatusPageMaintenance, now time.Time) string {
+	down, degraded, known := 0, 0, 0
+	for _, c := range components {
+		switch c.Status {
+		case "down":
+			down++
+			known++
+		case models.GroupStatusDegraded:
+			degraded++
+			known++
+		case "up":
+			known++
+		}
+	}
+
+	switch {
+	case down > 0 && down == known:
+		return PageStatusOutage
+	case down > 0 || degraded > 0:
+		return PageStatusDegraded
+	}
+	for _, m := range maintenances {
+		if !m.StartsAt.After(now) && m.EndsAt.After(now) {
+			return PageStatusMaintenance
+		}
+	}
+	return PageStatusOperational
+}
+
+type dailyUptime struct {
+	Day   string
+	Total int64
+	Up    int64
+}
+
+// monitorUptimeDays returns the share of successful checks per day
+func (s *StatusPageService) monitorUptimeDays(monitorID uint, since time.Time) (map[string]float64, error) {
+	var rows []dailyUptime
+	err := s.db.Model(&models.Check{}).
+		Select("date(created_at) AS day, COUNT(*) AS total, SUM(CASE WHEN status = 'up' THEN 1 ELSE 0 END) AS up").
+		Where("monitor_id = ? AND created_at >= ?", monitorID, since).
+		Group("day").Scan(&rows).Error
+	return uptimeByDay(rows), err
+}
+
+// groupUptimeDays returns the share of history entries per day in which the
+// group was not down
+func (s *StatusPageService) groupUptimeDays(groupID uint, since time.Time) (map[string]float64, error) {
+	var rows []dailyUptime
+	err := s.db.Model(&models.MonitorGroupCheck{}).
+		Select("date(created_at) AS day, COUNT(*) AS total, SUM(CASE WHEN status <> ? THEN 1 ELSE 0 END) AS up", models.GroupStatusDown).
+		Where("group_id = ? AND created_at >= ?", groupID, since).
+		Group("day").Scan(&rows).Error
+	return uptimeByDay(rows), err
+}
+
+func uptimeByDay(rows []dailyUptime) map[string]float64 {
+	days := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		if row.Total > 0 {
+			days[row.Day] = float64(row.Up) / float64(row.Total) * 100
+		}
+	}
+	return days
+}
+
+// uptimeBars lays the daily uptime out as one bar per day, oldest first
+func uptimeBars(days map[string]float64, since time.Time) []UptimeDay {
+	bars := make([]UptimeDay, 0, uptimeBarDays)
+	for i := 0; i < uptimeBarDays; i++ {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		bar := UptimeDay{Date: date}
+		if uptime, ok := days[date]; ok {
+			bar.UptimePercent = &uptime
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
@@ -1,64 +1,196 @@
 package services
 
 import (
-    "log"
-    "time"
-    "runnerx/models"
-    ws "runnerx/websocket"
-    "gorm.io/gorm"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"runnerx/models"
+	ws "runnerx/websocket"
+
+	"gorm.io/gorm"
+)
+
+// Mood levels shown by the dashboard background
+const (
+	MoodCalm   = 0
+	MoodSlight = 1
+	MoodWaves  = 2
+	MoodStormy = 3
 )
 
-// SystemMoodService computes global system uptime and broadcasts mood
+// Mood summarizes the current health of a set of monitors. Health is the
+// share of monitors that are up, each weighted by its criticality; paused,
+// disabled and not yet checked monitors are left out.
+type Mood struct {
+	Scope         string  `json:"scope"` // user, team or tag
+	TeamID        *uint   `json:"team_id,omitempty"`
+	Tag           string  `json:"tag,omitempty"`
+	Mood          int     `json:"mood"`
+	HealthPercent float64 `json:"health_percent"`
+	Monitors      int     `json:"monitors"`
+	Up            int     `json:"up"`
+	Down          int     `json:"down"`
+	CriticalDown  int     `json:"critical_down"`
+}
+
+// ComputeMood rates the monitors by their current status. A critical monitor
+// that is down makes the mood at least "waves" whatever the others do.
+func ComputeMood(monitors []models.Monitor) Mood {
+	var mood Mood
+	var healthy, total float64
+	for _, m := range monitors {
+		if !m.Enabled || (m.Status != "up" && m.Status != "down") {
+			continue
+		}
+		weight := models.CriticalityWeight(m.Criticality)
+		total += weight
+		mood.Monitors++
+		if m.Status == "up" {
+			healthy += weight
+			mood.Up++
+		} else {
+			mood.Down++
+			if m.Criticality == models.CriticalityCritical {
+				mood.CriticalDown++
+			}
+		}
+	}
+
+	mood.HealthPercent = 100
+	if total > 0 {
+		mood.HealthPercent = healthy / total * 100
+	}
+
+	switch {
+	case mood.HealthPercent >= 99.5:
+		mood.Mood = MoodCalm
+	case mood.HealthPercent >= 90:
+		mood.Mood = MoodSlight
+	case mood.HealthPercent >= 70:
+		mood.Mood = MoodWaves
+	default:
+		mood.Mood = MoodStormy
+	}
+	if mood.CriticalDown > 0 && mood.Mood < MoodWaves {
+		mood.Mood = MoodWaves
+	}
+	return mood
+}
+
+// ComputeTagMoods returns the mood of each tag used by the monitors, sorted
+// by tag
+func ComputeTagMoods(monitors []models.Monitor) []Mood {
+	byTag := make(map[string][]models.Monitor)
+	for _, m := range monitors {
+		for _, tag := range m.Tags {
+			byTag[tag] = append(byTag[tag], m)
+		}
+	}
+
+	moods := make([]Mood, 0, len(byTag))
+	for tag, tagged := range byTag {
+		mood := ComputeMood(tagged)
+		mood.Scope = "tag"
+		mood.Tag = tag
+		moods = append(moods, mood)
+	}
+	sort.Slice(moods, func(i, j int) bool { return moods[i].Tag < moods[j].Tag })
+	return moods
+}
+
+// SystemMoodService computes the mood of every personal and team workspace
+// and sends it to the people who can see that workspace
 type SystemMoodService struct {
-    db  *gorm.DB
-    hub *ws.Hub
+	db  *gorm.DB
+	hub *ws.Hub
+
+	// last mood sent per workspace, so only changes are broadcast
+	last map[string]int
+
+	mu       sync.Mutex
+	interval time.Duration
 }
 
-func NewSystemMoodService(db *gorm.DB, hub *ws.Hub) *SystemMoodService {
-    return &SystemMoodService{db: db, hub: hub}
+func NewSystemMoodService(db *gorm.DB, hub *ws.Hub, interval time.Duration) *SystemMoodService {
+	return &SystemMoodService{db: db, hub: hub, last: make(map[string]int), interval: interval}
 }
 
-// Start runs every minute
-func (s *SystemMoodService) Start() {
-    ticker := time.NewTicker(1 * time.Minute)
-    defer ticker.Stop()
-    for {
-        s.computeAndBroadcast()
-        <-ticker.C
-    }
+// SetInterval changes the time until the next and later runs
+func (s *SystemMoodService) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
 }
 
-func (s *SystemMoodService) computeAndBroadcast() {
-    var monitors []models.Monitor
-    if err := s.db.Find(&monitors).Error; err != nil {
-        log.Printf("SystemMood: failed to read monitors: %v", err)
-        return
-    }
-    if len(monitors) == 0 { return }
-
-    var sum float64
-    for _, m := range monitors {
-        sum += m.UptimePercent
-    }
-    avg := sum / float64(len(monitors))
-
-    mood := 0 // 0 calm, 1 slight, 2 waves, 3 stormy
-    switch {
-    case avg >= 99:
-        mood = 0
-    case avg >= 97:
-        mood = 1
-    case avg >= 90:
-        mood = 2
-    default:
-        mood = 3
-    }
-
-    payload := map[string]interface{}{
-        "system_uptime_percent": avg,
-        "mood": mood,
-    }
-    s.hub.Broadcast("system_mood_update", payload)
+// Start runs every interval, a minute by default, until ctx is cancelled
+func (s *SystemMoodService) Start(ctx context.Context, cluster *Cluster) {
+	for {
+		if cluster.IsLeader() {
+			s.computeAndBroadcast()
+		}
+		s.mu.Lock()
+		interval := s.interval
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
 }
 
+func (s *SystemMoodService) computeAndBroadcast() {
+	var monitors []models.Monitor
+	if err := s.db.Select("id", "user_id", "team_id", "enabled", "status", "criticality").Find(&monitors).Error; err != nil {
+		log.Printf("SystemMood: failed to read monitors: %v", err)
+		return
+	}
 
+	personal := make(map[uint][]models.Monitor)
+	teams := make(map[uint][]models.Monitor)
+	for _, m := range monitors {
+		if m.TeamID == nil {
+			personal[m.UserID] = append(personal[m.UserID], m)
+		} else {
+			teams[*m.TeamID] = append(teams[*m.TeamID], m)
+		}
+	}
+
+	for userID, owned := range personal {
+		mood := ComputeMood(owned)
+		mood.Scope = "user"
+		if s.changed(fmt.Sprintf("user:%d", userID), mood) {
+			s.hub.BroadcastToUser(userID, "system_mood_update", mood)
+		}
+	}
+
+	for teamID, owned := range teams {
+		mood := ComputeMood(owned)
+		mood.Scope = "team"
+		id := teamID
+		mood.TeamID = &id
+		if !s.changed(fmt.Sprintf("team:%d", teamID), mood) {
+			continue
+		}
+		memberIDs, err := models.TeamMemberIDs(s.db, teamID)
+		if err != nil {
+			log.Printf("SystemMood: failed to read members of team %d: %v", teamID, err)
+			continue
+		}
+		for _, userID := range memberIDs {
+			s.hub.BroadcastToUser(userID, "system_mood_update", mood)
+		}
+	}
+}
+
+func (s *SystemMoodService) changed(key string, mood Mood) bool {
+	if last, ok := s.last[key]; ok && last == mood.Mood {
+		return false
+	}
+	s.last[key] = mood.Mood
+	return true
+}
@@ -0,0 +1,94 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "telemetry:span"
+
+// GormPlugin records a span for every query run with a context that is part
+// of a trace, e.g. db.WithContext(c.Request.Context()). Queries run outside
+// of a trace, such as the scheduler's polling, are not recorded.
+func GormPlugin() gorm.Plugin {
+	return gormPlugin{}
+}
+
+type gormPlugin struct{}
+
+func (gormPlugin) Name() string {
+	return "telemetry"
+}
+
+func (gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("telemetry:before_create", startQuery("INSERT")),
+		cb.Create().After("gorm:create").Register("telemetry:after_create", endQuery),
+		cb.Query().Before("gorm:query").Register("telemetry:before_query", startQuery("SELECT")),
+		cb.Query().After("gorm:query").Register("telemetry:after_query", endQuery),
+		cb.Update().Before("gorm:update").Register("telemetry:before_update", startQuery("UPDATE")),
+		cb.Update().After("gorm:update").Register("telemetry:after_update", endQuery),
+		cb.Delete().Before("gorm:delete").Register("telemetry:before_delete", startQuery("DELETE")),
+		cb.Delete().After("gorm:delete").Register("telemetry:after_delete", endQuery),
+		cb.Row().Before("gorm:row").Register("telemetry:before_row", startQuery("SELECT")),
+		cb.Row().After("gorm:row").Register("telemetry:after_row", endQuery),
+		cb.Raw().Before("gorm:raw").Register("telemetry:before_raw", startQuery("EXEC")),
+		cb.Raw().After("gorm:raw").Register("telemetry:after_raw", endQuery),
+	)
+}
+
+// querySpan is the span of a running query and the context it replaced
+type querySpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+func startQuery(operation string) func(*gorm.DB) {
+	tracer := otel.Tracer("runnerx/database")
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil || !trace.SpanContextFromContext(parent).IsValid() {
+			return
+		}
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := tracer.Start(parent, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system.name", db.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+				attribute.String("db.collection.name", db.Statement.Table),
+			))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, querySpan{span: span, parent: parent})
+	}
+}
+
+func endQuery(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	q := value.(querySpan)
+	db.Statement.Context = q.parent
+
+	// Only the statement's placeholders are recorded, never its values
+	q.span.SetAttributes(
+		attribute.String("db.query.text", db.Statement.SQL.String()),
+		attribute.Int64("db.response.affected_rows", db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		q.span.RecordError(db.Error)
+		q.span.SetStatus(codes.Error, db.Error.Error())
+	}
+	q.span.End()
+}
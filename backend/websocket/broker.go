@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Kinds of envelopes passed through a Broker
+const (
+	envelopeUser              = "user"
+	envelopeAll               = "all"
+	envelopeDisconnectSession = "disconnect_session"
+)
+
+// Envelope is a hub event on its way to every backend instance
+type Envelope struct {
+	Kind      string          `json:"kind"`
+	UserID    uint            `json:"user_id,omitempty"`
+	SessionID uint            `json:"session_id,omitempty"`
+	Topic     string          `json:"topic,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Broker fans hub events out to every backend instance, including the one
+// that published them. Each instance delivers the events to its own
+// connections.
+type Broker interface {
+	Publish(envelope Envelope) error
+	// Subscribe registers the handler that receives every published envelope
+	Subscribe(handler func(Envelope)) error
+	Close() error
+}
+
+// BrokerConfig selects the pub/sub bus. Kind is "memory" (a single instance)
+// or "redis".
+type BrokerConfig struct {
+	Kind     string
+	RedisURL string
+	Channel  string
+}
+
+// NewBroker returns the broker selected by the configuration
+func NewBroker(cfg BrokerConfig) (Broker, error) {
+	switch cfg.Kind {
+	case "memory", "":
+		return NewMemoryBroker(), nil
+	case "redis":
+		return NewRedisBroker(cfg.RedisURL, cfg.Channel)
+	default:
+		return nil, fmt.Errorf("unknown pub/sub broker %q", cfg.Kind)
+	}
+}
+
+// MemoryBroker delivers envelopes within the process
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers []func(Envelope)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(envelope Envelope) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(envelope)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(Envelope)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
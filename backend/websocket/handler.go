@@ -1,20 +1,25 @@
 package websocket
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"runnerx/middleware"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 4096
 )
 
 var upgrader = websocket.Upgrader{
@@ -26,37 +31,32 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	jwt.RegisteredClaims
-}
+// CloseSessionRevoked is sent to clients whose login session was revoked
+const CloseSessionRevoked = 4001
 
-func HandleWebSocket(hub *Hub, jwtSecret string) gin.HandlerFunc {
+// CloseSlowConsumer is sent to clients that fell too far behind
+const CloseSlowConsumer = 4002
+
+func HandleWebSocket(hub *Hub, db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from query parameter
-		tokenString := c.Query("token")
+		// Browsers cannot set headers on WebSocket requests, so the access
+		// token may also be passed as a query parameter
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			tokenString = c.Query("token")
+		}
 		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token required"})
 			return
 		}
 
-		// Validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		// Validate token and its session
+		claims, err := middleware.ParseAccessToken(db, jwtSecret, tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
 
-		claims, ok := token.Claims.(*Claims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			return
-		}
-
 		// Upgrade connection
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
@@ -65,12 +65,34 @@ func HandleWebSocket(hub *Hub, jwtSecret string) gin.HandlerFunc {
 		}
 
 		client := &Client{
-			UserID: claims.UserID,
-			Send:   make(chan []byte, 256),
-			Hub:    hub,
+			UserID:    claims.UserID,
+			SessionID: claims.SessionID,
+			Send:      make(chan []byte, sendBufferSize),
+			Hub:       hub,
+			writer:    true,
+			db:        db,
 		}
 
-		client.Hub.register <- client
+		// Topics may be passed up front so a resumed connection only replays
+		// what it subscribes to
+		var subscribed []string
+		var rejected []errorData
+		if topics := c.Query("topics"); topics != "" {
+			subscribed, rejected = client.addTopics(strings.Split(topics, ","))
+		}
+
+		var resume *Resume
+		if lastSeq, err := strconv.ParseUint(c.Query("last_seq"), 10, 64); err == nil {
+			resume = &Resume{Epoch: c.Query("epoch"), LastSeq: lastSeq}
+		}
+		hub.Attach(client, resume)
+
+		for _, e := range rejected {
+			client.reply(Message{Type: "error", Data: e})
+		}
+		if subscribed != nil {
+			client.reply(Message{Type: "ack", Data: ackData{Action: "subscribe", Topics: subscribed}})
+		}
 
 		// Start goroutines
 		go client.writePump(conn)
@@ -80,7 +102,7 @@ func HandleWebSocket(hub *Hub, jwtSecret string) gin.HandlerFunc {
 
 func (c *Client) readPump(conn *websocket.Conn) {
 	defer func() {
-		c.Hub.unregister <- c
+		c.Hub.unregister(c)
 		conn.Close()
 	}()
 
@@ -99,8 +121,139 @@ func (c *Client) readPump(conn *websocket.Conn) {
 			break
 		}
 
-		log.Printf("Received message: %s", message)
-		// Handle ping/pong and other client messages here if needed
+		c.handleMessage(message)
+	}
+}
+
+// clientMessage is a request sent by the client. ID is optional and echoed
+// back in the matching ack or error.
+type clientMessage struct {
+	Type   string   `json:"type"`
+	ID     string   `json:"id,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+}
+
+type ackData struct {
+	ID     string   `json:"id,omitempty"`
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+type errorData struct {
+	ID      string `json:"id,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Topic   string `json:"topic,omitempty"`
+}
+
+func (c *Client) handleMessage(raw []byte) {
+	var msg clientMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		c.replyError("", ErrCodeInvalidMessage, "Message must be a JSON object", "")
+		return
+	}
+
+	switch msg.Type {
+	case "ping":
+		c.reply(Message{Type: "pong"})
+	case "subscribe":
+		c.subscribe(msg)
+	case "unsubscribe":
+		c.unsubscribe(msg)
+	default:
+		c.replyError(msg.ID, ErrCodeUnknownType, "Unknown message type", "")
+	}
+}
+
+// subscribe adds every topic the user may see and reports the others
+func (c *Client) subscribe(msg clientMessage) {
+	subscribed, rejected := c.addTopics(msg.Topics)
+	for _, e := range rejected {
+		e.ID = msg.ID
+		c.reply(Message{Type: "error", Data: e})
+	}
+	c.reply(Message{Type: "ack", Data: ackData{ID: msg.ID, Action: "subscribe", Topics: subscribed}})
+}
+
+// addTopics subscribes to the topics the user may see and returns the errors
+// for the others. Subscribing to nothing still opts the connection into
+// topic routing.
+func (c *Client) addTopics(topics []string) ([]string, []errorData) {
+	subscribed := []string{}
+	var rejected []errorData
+	for _, topic := range topics {
+		if err := authorizeTopic(c.db, c.UserID, !c.hideIncidents, topic); err != nil {
+			code := ErrCodeForbidden
+			if err == errInvalidTopic {
+				code = ErrCodeInvalidTopic
+			}
+			rejected = append(rejected, errorData{Code: code, Message: err.Error(), Topic: topic})
+			continue
+		}
+
+		c.mu.Lock()
+		if c.topics == nil {
+			c.topics = make(map[string]bool)
+		}
+		full := !c.topics[topic] && len(c.topics) >= maxTopicsPerClient
+		if !full {
+			c.topics[topic] = true
+		}
+		c.mu.Unlock()
+
+		if full {
+			rejected = append(rejected, errorData{Code: ErrCodeTooManyTopics, Message: "Too many subscriptions on this connection", Topic: topic})
+			continue
+		}
+		subscribed = append(subscribed, topic)
+	}
+
+	c.mu.Lock()
+	if c.topics == nil {
+		c.topics = make(map[string]bool)
+	}
+	c.mu.Unlock()
+	return subscribed, rejected
+}
+
+func (c *Client) unsubscribe(msg clientMessage) {
+	c.mu.Lock()
+	if c.topics == nil {
+		c.topics = make(map[string]bool)
+	}
+	for _, topic := range msg.Topics {
+		delete(c.topics, topic)
+	}
+	c.mu.Unlock()
+
+	topics := msg.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	c.reply(Message{Type: "ack", Data: ackData{ID: msg.ID, Action: "unsubscribe", Topics: topics}})
+}
+
+func (c *Client) replyError(id, code, message, topic string) {
+	c.reply(Message{Type: "error", Data: errorData{ID: id, Code: code, Message: message, Topic: topic}})
+}
+
+// reply queues a message for this connection only, unless it was closed
+func (c *Client) reply(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	c.Hub.mu.RLock()
+	defer c.Hub.mu.RUnlock()
+	if !c.Hub.clients[c] {
+		return
+	}
+	select {
+	case c.Send <- data:
+	default:
+		log.Printf("Failed to send to client")
 	}
 }
 
@@ -109,6 +262,9 @@ func (c *Client) writePump(conn *websocket.Conn) {
 	defer func() {
 		ticker.Stop()
 		conn.Close()
+		if c.writer {
+			c.Hub.writers.Done()
+		}
 	}()
 
 	for {
@@ -116,7 +272,11 @@ func (c *Client) writePump(conn *websocket.Conn) {
 		case message, ok := <-c.Send:
 			conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeFrame := c.closeFrame
+				if closeFrame == nil {
+					closeFrame = []byte{}
+				}
+				conn.WriteMessage(websocket.CloseMessage, closeFrame)
 				return
 			}
 
@@ -1,77 +1,400 @@
 package websocket
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// Buffer sizes of the per-user replay log and of each connection's queue.
+// A connection's queue must hold a full replay.
+const (
+	replayBufferSize = 1000
+	sendBufferSize   = 1024
 )
 
+// replayRetention is how long a user's events stay buffered after their last
+// connection on this instance went away, so a reconnecting client can resume
+const replayRetention = 5 * time.Minute
+
 type Message struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data,omitempty"`
+	Type  string      `json:"type"`
+	Topic string      `json:"topic,omitempty"`
+	Seq   uint64      `json:"seq,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// Resume is where a reconnecting client left off
+type Resume struct {
+	Epoch   string
+	LastSeq uint64
+}
+
+// helloData is sent once a connection is ready. Seq is the user's latest
+// event; clients resume from it after a reconnect.
+type helloData struct {
+	Epoch    string `json:"epoch"`
+	Seq      uint64 `json:"seq"`
+	Replayed int    `json:"replayed"`
+}
+
+type resyncData struct {
+	LastSeq uint64 `json:"last_seq"`
+	Seq     uint64 `json:"seq"`
+}
+
+type streamEvent struct {
+	seq   uint64
+	topic string
+	data  []byte
+}
+
+// userStream numbers a user's events and keeps the latest ones for replay.
+// Events are only buffered while the user has a connection on this instance,
+// and for replayRetention after the last one left.
+type userStream struct {
+	seq    uint64
+	events []streamEvent
+
+	clients   int
+	idleSince time.Time
+}
+
+// buffering reports whether new events should be kept for replay
+func (s *userStream) buffering() bool {
+	return s.clients > 0 || (!s.idleSince.IsZero() && time.Since(s.idleSince) < replayRetention)
+}
+
+func (s *userStream) append(event streamEvent) {
+	s.events = append(s.events, event)
+	// Trim in batches so appends stay cheap
+	if len(s.events) >= 2*replayBufferSize {
+		s.events = append([]streamEvent(nil), s.events[len(s.events)-replayBufferSize:]...)
+	}
+}
+
+// since returns the buffered events after seq, or false when some of them are
+// no longer buffered
+func (s *userStream) since(seq uint64) ([]streamEvent, bool) {
+	if seq >= s.seq {
+		return nil, seq == s.seq
+	}
+	start := len(s.events) - replayBufferSize
+	if start < 0 {
+		start = 0
+	}
+	buffered := s.events[start:]
+	if len(buffered) == 0 || buffered[0].seq > seq+1 {
+		return nil, false
+	}
+	return buffered[seq+1-buffered[0].seq:], true
 }
 
 type Client struct {
-	UserID uint
-	Send   chan []byte
-	Hub    *Hub
+	UserID    uint
+	SessionID uint
+	Send      chan []byte
+	Hub       *Hub
+
+	// closeFrame is written when Send is closed; set before closing
+	closeFrame []byte
+	// writer is set for WebSocket connections, whose write pump the hub
+	// waits for when shutting down; Attach clears it if the hub is closed
+	writer bool
+
+	// hideIncidents is set for API tokens without an incident scope, which
+	// neither subscribe to nor receive incident and log topics
+	hideIncidents bool
+
+	db *gorm.DB
+	mu sync.Mutex
+	// topics is nil until the client first subscribes; until then it
+	// receives every message, as clients that predate topics expect
+	topics map[string]bool
 }
 
+// wants reports whether a message on topic should be delivered. Messages
+// without a topic go to every connection of the user.
+func (c *Client) wants(topic string) bool {
+	if topic == "" {
+		return true
+	}
+	if c.hideIncidents && isIncidentTopic(topic) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.topics == nil {
+		return true
+	}
+	for subscription := range c.topics {
+		if topicMatches(subscription, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub delivers events to the WebSocket connections of this instance. Events
+// pass through the broker first so every instance sees them.
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
-    // optional: future per-topic channels
+	clients   map[*Client]bool
+	broadcast chan []byte
+	mu        sync.RWMutex
+	broker    Broker
+
+	// closed is set once the hub shut down; done is closed at the same time
+	closed bool
+	done   chan struct{}
+	// writers counts the WebSocket write pumps, which send the close frames
+	writers sync.WaitGroup
+
+	// epoch identifies this hub's sequence numbers, which are per instance and
+	// restart with the server
+	epoch   string
+	streams map[uint]*userStream
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan []byte, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+func NewHub(broker Broker) (*Hub, error) {
+	epoch := make([]byte, 8)
+	rand.Read(epoch)
+
+	h := &Hub{
+		broadcast: make(chan []byte, 256),
+		clients:   make(map[*Client]bool),
+		broker:    broker,
+		done:      make(chan struct{}),
+		epoch:     hex.EncodeToString(epoch),
+		streams:   make(map[uint]*userStream),
+	}
+	if err := broker.Subscribe(h.dispatch); err != nil {
+		return nil, err
 	}
+	return h, nil
 }
 
-func (h *Hub) Run() {
-	for {
+// publish hands an event to the broker. If the broker is unavailable the
+// event still reaches this instance's connections.
+func (h *Hub) publish(envelope Envelope) {
+	if err := h.broker.Publish(envelope); err != nil {
+		log.Printf("Pub/sub publish failed, delivering locally only: %v", err)
+		h.dispatch(envelope)
+	}
+}
+
+// dispatch delivers an event received from the broker
+func (h *Hub) dispatch(envelope Envelope) {
+	var data interface{}
+	if len(envelope.Data) > 0 {
+		data = envelope.Data
+	}
+
+	switch envelope.Kind {
+	case envelopeUser:
+		h.sendToUser(envelope.UserID, envelope.Topic, envelope.Type, data)
+	case envelopeAll:
+		jsonData, err := json.Marshal(Message{Type: envelope.Type, Data: data})
+		if err != nil {
+			log.Printf("Error marshaling message: %v", err)
+			return
+		}
 		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			log.Printf("Client registered, total: %d", len(h.clients))
+		case h.broadcast <- jsonData:
+		case <-h.done:
+		}
+	case envelopeDisconnectSession:
+		h.disconnectSession(envelope.SessionID)
+	}
+}
 
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.Send)
+// Attach registers a connection. A resuming client first gets the events it
+// missed, filtered by its topics, or resync_required when they are no longer
+// buffered or were numbered by another instance or before a restart. Either
+// way a hello with the latest sequence number follows.
+func (h *Hub) Attach(client *Client, resume *Resume) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Connections opened while shutting down are told to come back later
+	if h.closed {
+		client.closeFrame = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server shutting down")
+		client.writer = false
+		close(client.Send)
+		return
+	}
+	if client.writer {
+		h.writers.Add(1)
+	}
+
+	stream := h.streams[client.UserID]
+	if stream == nil {
+		stream = &userStream{}
+		h.streams[client.UserID] = stream
+	}
+
+	replayed := 0
+	if resume != nil {
+		events, ok := stream.since(resume.LastSeq)
+		if resume.Epoch != "" && resume.Epoch != h.epoch {
+			ok = false
+		}
+		if ok {
+			for _, event := range events {
+				if client.wants(event.topic) {
+					client.Send <- event.data
+					replayed++
+				}
 			}
-			h.mu.Unlock()
-			log.Printf("Client unregistered, total: %d", len(h.clients))
+		} else {
+			client.Send <- mustMarshal(Message{Type: "resync_required", Data: resyncData{LastSeq: resume.LastSeq, Seq: stream.seq}})
+		}
+	}
+	client.Send <- mustMarshal(Message{Type: "hello", Data: helloData{Epoch: h.epoch, Seq: stream.seq, Replayed: replayed}})
+
+	h.clients[client] = true
+	stream.clients++
+	log.Printf("Client registered, total: %d", len(h.clients))
+}
+
+// detach removes a connection and closes its queue. Once the user's last
+// connection is gone, their buffered events are dropped after
+// replayRetention unless a client came back. The caller must hold the write
+// lock.
+func (h *Hub) detach(client *Client) {
+	delete(h.clients, client)
+	close(client.Send)
+
+	stream := h.streams[client.UserID]
+	if stream == nil {
+		return
+	}
+	stream.clients--
+	if stream.clients > 0 {
+		return
+	}
+	stream.idleSince = time.Now()
+	time.AfterFunc(replayRetention, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if !stream.buffering() {
+			stream.events = nil
+		}
+	})
+}
+
+// deliver queues a message for a client. A client that cannot keep up is
+// disconnected rather than silently missing events; it can resume from the
+// last sequence number it saw. The caller must hold the write lock.
+func (h *Hub) deliver(client *Client, data []byte) {
+	select {
+	case client.Send <- data:
+	default:
+		log.Printf("Disconnecting slow WebSocket client of user %d", client.UserID)
+		client.closeFrame = websocket.FormatCloseMessage(CloseSlowConsumer, "too slow, resume with last_seq")
+		h.detach(client)
+	}
+}
+
+func mustMarshal(msg Message) []byte {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Run delivers broadcasts until ctx is cancelled, then closes every
+// connection with a close frame telling clients to reconnect, and returns
+// once the WebSocket close frames are written
+func (h *Hub) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			h.shutdown()
+			h.writers.Wait()
+			return
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			h.mu.Lock()
 			for client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.clients, client)
-				}
+				h.deliver(client, message)
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
 		}
 	}
 }
 
+func (h *Hub) shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	close(h.done)
+	for client := range h.clients {
+		client.closeFrame = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server shutting down")
+		h.detach(client)
+	}
+	log.Printf("Closed all WebSocket and SSE connections")
+}
+
+// unregister forgets a connection that went away
+func (h *Hub) unregister(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[client]; ok {
+		h.detach(client)
+		log.Printf("Client unregistered, total: %d", len(h.clients))
+	}
+}
+
 func (h *Hub) BroadcastToUser(userID uint, messageType string, data interface{}) {
+	h.BroadcastToUserTopic(userID, "", messageType, data)
+}
+
+// BroadcastToUserTopic sends a message to the user's connections that are
+// subscribed to topic, on every instance
+func (h *Hub) BroadcastToUserTopic(userID uint, topic, messageType string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	h.publish(Envelope{Kind: envelopeUser, UserID: userID, Topic: topic, Type: messageType, Data: raw})
+}
+
+// sendToUser delivers a message to the user's local connections. The message
+// gets the user's next sequence number on this instance and is kept for replay
+// while the user is, or recently was, connected here.
+func (h *Hub) sendToUser(userID uint, topic, messageType string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := h.streams[userID]
+	if stream == nil {
+		stream = &userStream{}
+		h.streams[userID] = stream
+	}
+
+	// Without a connection here there is nobody to deliver to, and no
+	// client that could resume
+	if !stream.buffering() {
+		stream.seq++
+		return
+	}
+
 	msg := Message{
-		Type: messageType,
-		Data: data,
+		Type:  messageType,
+		Topic: topic,
+		Seq:   stream.seq + 1,
+		Data:  data,
 	}
 
 	jsonData, err := json.Marshal(msg)
@@ -79,33 +402,39 @@ func (h *Hub) BroadcastToUser(userID uint, messageType string, data interface{})
 		log.Printf("Error marshaling message: %v", err)
 		return
 	}
-
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	stream.seq++
+	stream.append(streamEvent{seq: stream.seq, topic: topic, data: jsonData})
 
 	for client := range h.clients {
-		if client.UserID == userID {
-			select {
-			case client.Send <- jsonData:
-			default:
-				log.Printf("Failed to send to client")
-			}
+		if client.UserID == userID && client.wants(topic) {
+			h.deliver(client, jsonData)
 		}
 	}
 }
 
 func (h *Hub) Broadcast(messageType string, data interface{}) {
-	msg := Message{
-		Type: messageType,
-		Data: data,
-	}
-
-	jsonData, err := json.Marshal(msg)
+	raw, err := json.Marshal(data)
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
 		return
 	}
+	h.publish(Envelope{Kind: envelopeAll, Type: messageType, Data: raw})
+}
 
-	h.broadcast <- jsonData
+// DisconnectSession closes every connection opened with the given login
+// session, on every instance
+func (h *Hub) DisconnectSession(sessionID uint) {
+	h.publish(Envelope{Kind: envelopeDisconnectSession, SessionID: sessionID})
 }
 
+func (h *Hub) disconnectSession(sessionID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if client.SessionID == sessionID {
+			client.closeFrame = websocket.FormatCloseMessage(CloseSessionRevoked, "session revoked")
+			h.detach(client)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultBrokerChannel is the Redis channel used when none is configured
+const DefaultBrokerChannel = "runnerx:events"
+
+const redisTimeout = 5 * time.Second
+
+// RedisBroker fans events out through Redis pub/sub. Events published while
+// an instance is reconnecting to Redis are missed by that instance.
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+	pubsub  *redis.PubSub
+}
+
+// NewRedisBroker connects to the server at url, e.g. redis://localhost:6379/0
+func NewRedisBroker(url, channel string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		channel = DefaultBrokerChannel
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &RedisBroker{client: client, channel: channel}, nil
+}
+
+func (b *RedisBroker) Publish(envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *RedisBroker) Subscribe(handler func(Envelope)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	pubsub := b.client.Subscribe(context.Background(), b.channel)
+	// Wait for the confirmation so nothing published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	b.pubsub = pubsub
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+				log.Printf("Ignoring malformed pub/sub message: %v", err)
+				continue
+			}
+			handler(envelope)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBroker) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	return b.client.Close()
+}
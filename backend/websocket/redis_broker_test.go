@@ -0,0 +1,86 @@
+//go:build integration
+
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// Run against a Redis server with
+//
+//	REDIS_URL=redis://localhost:6379/0 go test -tags integration ./websocket
+
+func newRedisHub(t *testing.T, url, channel string) *Hub {
+	t.Helper()
+	broker, err := NewRedisBroker(url, channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	hub, err := NewHub(broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hub
+}
+
+func receive(t *testing.T, client *Client) (Message, bool) {
+	t.Helper()
+	select {
+	case data, ok := <-client.Send:
+		if !ok {
+			return Message{}, false
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg, true
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return Message{}, false
+	}
+}
+
+func TestRedisBrokerFansOutBetweenHubs(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL is not set")
+	}
+	// A channel of its own keeps the test away from running servers
+	channel := fmt.Sprintf("runnerx:test:%d", time.Now().UnixNano())
+	a := newRedisHub(t, url, channel)
+	b := newRedisHub(t, url, channel)
+
+	client := &Client{UserID: 7, SessionID: 3, Send: make(chan []byte, sendBufferSize)}
+	b.Attach(client, nil)
+	if msg, _ := receive(t, client); msg.Type != "hello" {
+		t.Fatalf("got %q, want hello", msg.Type)
+	}
+
+	// An event published on one hub reaches the user's connection on the other
+	a.BroadcastToUserTopic(7, "monitor:1", "monitor:update", map[string]int{"monitor_id": 1})
+	msg, ok := receive(t, client)
+	if !ok || msg.Type != "monitor:update" || msg.Topic != "monitor:1" || msg.Seq != 1 {
+		t.Fatalf("got %+v", msg)
+	}
+
+	// Other users' events are not delivered
+	a.BroadcastToUser(8, "notification", map[string]string{"message": "not for user 7"})
+	a.BroadcastToUser(7, "notification", map[string]string{"message": "for user 7"})
+	if msg, _ := receive(t, client); msg.Type != "notification" || msg.Seq != 2 {
+		t.Fatalf("got %+v", msg)
+	}
+
+	// Ending a session on one hub closes its connections on the other
+	a.DisconnectSession(3)
+	for {
+		if _, ok := receive(t, client); !ok {
+			break
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"runnerx/middleware"
+	"runnerx/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sseRetry tells EventSource clients how long to wait before reconnecting
+const sseRetry = 3 * time.Second
+
+// HandleEvents streams the hub's events as Server-Sent Events, for clients
+// behind proxies that break WebSocket upgrades and for scripts. It must run
+// after AuthMiddleware. Each event is named after the message type and its
+// data is the same JSON message a WebSocket client receives. Events with a
+// sequence number get the ID "<epoch>:<seq>", so a reconnect with
+// Last-Event-ID replays what was missed.
+func HandleEvents(hub *Hub, db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+		sessionID, _ := middleware.GetSessionID(c)
+
+		client := &Client{
+			UserID:        userID,
+			SessionID:     sessionID,
+			Send:          make(chan []byte, sendBufferSize),
+			Hub:           hub,
+			hideIncidents: !middleware.HasScope(c, models.ScopeIncidentsRead, models.ScopeIncidentsManage),
+			db:            db,
+		}
+
+		var rejected []errorData
+		if topics := c.Query("topics"); topics != "" {
+			_, rejected = client.addTopics(strings.Split(topics, ","))
+		}
+
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+		var resume *Resume
+		if lastEventID != "" {
+			epoch, seq, _ := strings.Cut(lastEventID, ":")
+			lastSeq, err := strconv.ParseUint(seq, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Last-Event-ID must be <epoch>:<seq>"})
+				return
+			}
+			resume = &Resume{Epoch: epoch, LastSeq: lastSeq}
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// Keep reverse proxies such as nginx from buffering the stream
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", sseRetry.Milliseconds())
+
+		hub.Attach(client, resume)
+		defer hub.unregister(client)
+
+		for _, e := range rejected {
+			client.reply(Message{Type: "error", Data: e})
+		}
+
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case message, ok := <-client.Send:
+				// Closed when the session is revoked, the client fell behind or
+				// the server is shutting down
+				if !ok {
+					return
+				}
+				if err := writeEvent(c.Writer, hub.epoch, message); err != nil {
+					return
+				}
+				c.Writer.Flush()
+
+			case <-ticker.C:
+				if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes one message in the text/event-stream format
+func writeEvent(w gin.ResponseWriter, epoch string, message []byte) error {
+	var head struct {
+		Type string          `json:"type"`
+		Seq  uint64          `json:"seq"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &head); err != nil {
+		return err
+	}
+
+	// The hello carries the current position, so a client subscribed to a
+	// few topics does not resume from an old event
+	seq, hasID := head.Seq, head.Seq > 0
+	if head.Type == "hello" {
+		var hello helloData
+		json.Unmarshal(head.Data, &hello)
+		seq, hasID = hello.Seq, true
+	}
+	if hasID {
+		if _, err := fmt.Fprintf(w, "id: %s:%d\n", epoch, seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", head.Type, message)
+	return err
+}
@@ -0,0 +1,156 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"runnerx/models"
+
+	"gorm.io/gorm"
+)
+
+// TopicCommands carries the results of the user's diagnostic commands
+const TopicCommands = "commands"
+
+// TopicAllMonitors matches the topic of every monitor the user can see
+const TopicAllMonitors = "monitor:*"
+
+// TopicAllGroups matches the topic of every monitor group the user can see
+const TopicAllGroups = "group:*"
+
+// maxTopicsPerClient caps the subscriptions of one connection
+const maxTopicsPerClient = 200
+
+// Subscription error codes
+const (
+	ErrCodeInvalidMessage = "invalid_message"
+	ErrCodeUnknownType    = "unknown_type"
+	ErrCodeInvalidTopic   = "invalid_topic"
+	ErrCodeForbidden      = "forbidden"
+	ErrCodeTooManyTopics  = "too_many_topics"
+)
+
+var (
+	errInvalidTopic = errors.New("unknown topic")
+	errForbidden    = errors.New("not allowed to subscribe to this topic")
+)
+
+// MonitorTopic carries updates and status changes of one monitor
+func MonitorTopic(monitorID uint) string {
+	return fmt.Sprintf("monitor:%d", monitorID)
+}
+
+// IncidentTopic carries screenshots and other updates of one incident
+func IncidentTopic(incidentID string) string {
+	return "incident:" + incidentID
+}
+
+// LogsTopic carries the log insights of one incident
+func LogsTopic(incidentID string) string {
+	return "logs:" + incidentID
+}
+
+// GroupTopic carries status changes of one monitor group
+func GroupTopic(groupID uint) string {
+	return fmt.Sprintf("group:%d", groupID)
+}
+
+// topicMatches reports whether a subscription covers a message topic.
+// "monitor:*" covers every monitor topic.
+func topicMatches(subscription, topic string) bool {
+	if subscription == topic {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(subscription, "*"); ok {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return false
+}
+
+// isIncidentTopic reports whether topic carries incident details, which API
+// tokens only see with an incident scope
+func isIncidentTopic(topic string) bool {
+	return strings.HasPrefix(topic, "incident:") || strings.HasPrefix(topic, "logs:")
+}
+
+// authorizeTopic checks that the topic exists and that the user may see it.
+// Incident topics also need incidents to be true.
+func authorizeTopic(db *gorm.DB, userID uint, incidents bool, topic string) error {
+	kind, id, _ := strings.Cut(topic, ":")
+	switch {
+	case topic == TopicCommands, topic == TopicAllMonitors, topic == TopicAllGroups:
+		return nil
+	case kind == "monitor":
+		monitorID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return errInvalidTopic
+		}
+		return authorizeMonitor(db, userID, uint(monitorID))
+	case kind == "group":
+		groupID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return errInvalidTopic
+		}
+		return authorizeGroup(db, userID, uint(groupID))
+	case (kind == "incident" || kind == "logs") && id != "":
+		if !incidents {
+			return errForbidden
+		}
+		return authorizeIncident(db, userID, id)
+	default:
+		return errInvalidTopic
+	}
+}
+
+// authorizeIncident accepts both incident IDs and the "monitor-<id>" scopes
+// used for a monitor's screenshots and log insights
+func authorizeIncident(db *gorm.DB, userID uint, incidentID string) error {
+	if rest, ok := strings.CutPrefix(incidentID, "monitor-"); ok {
+		monitorID, err := strconv.ParseUint(rest, 10, 64)
+		if err != nil {
+			return errInvalidTopic
+		}
+		return authorizeMonitor(db, userID, uint(monitorID))
+	}
+
+	id, err := strconv.ParseUint(incidentID, 10, 64)
+	if err != nil {
+		return errInvalidTopic
+	}
+	var monitorID uint
+	if err := db.Model(&models.Incident{}).Where("id = ?", id).Pluck("monitor_id", &monitorID).Error; err != nil || monitorID == 0 {
+		return errForbidden
+	}
+	return authorizeMonitor(db, userID, monitorID)
+}
+
+// authorizeMonitor allows the owner of a personal monitor and every member of
+// a monitor's team
+func authorizeMonitor(db *gorm.DB, userID, monitorID uint) error {
+	var count int64
+	err := db.Model(&models.Monitor{}).
+		Where("id = ?", monitorID).
+		Where("(user_id = ? AND team_id IS NULL) OR team_id IN (?)", userID,
+			db.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", userID)).
+		Count(&count).Error
+	if err != nil || count == 0 {
+		return errForbidden
+	}
+	return nil
+}
+
+// authorizeGroup applies the monitor rules to monitor groups
+func authorizeGroup(db *gorm.DB, userID, groupID uint) error {
+	var count int64
+	err := db.Model(&models.MonitorGroup{}).
+		Where("id = ?", groupID).
+		Where("(user_id = ? AND team_id IS NULL) OR team_id IN (?)", userID,
+			db.Model(&models.TeamMembership{}).Select("team_id").Where("user_id = ?", userID)).
+		Count(&count).Error
+	if err != nil || count == 0 {
+		return errForbidden
+	}
+	return nil
+}
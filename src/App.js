@@ -9,6 +9,10 @@ import { ThemeProvider } from './contexts/ThemeContext';
 import ProtectedRoute from './components/common/ProtectedRoute';
 import Login from './components/auth/Login';
 import Register from './components/auth/Register';
+import SSOCallback from './components/auth/SSOCallback';
+import ForgotPassword from './components/auth/ForgotPassword';
+import ResetPassword from './components/auth/ResetPassword';
+import VerifyEmail from './components/auth/VerifyEmail';
 import Dashboard from './components/dashboard/Dashboard';
 import StatusPublicPage from './components/status/StatusPublicPage';
 import AutomationPage from './components/automation/AutomationPage';
@@ -33,6 +37,11 @@ function App() {
             <Routes>
               <Route path="/login" element={<Login />} />
               <Route path="/register" element={<Register />} />
+              <Route path="/auth/callback" element={<SSOCallback />} />
+              <Route path="/forgot-password" element={<ForgotPassword />} />
+              <Route path="/reset-password" element={<ResetPassword />} />
+              <Route path="/verify-email" element={<VerifyEmail />} />
+              <Route path="/status" element={<StatusPublicPage />} />
               <Route path="/status/:slug" element={<StatusPublicPage />} />
               <Route
                 path="/automation"
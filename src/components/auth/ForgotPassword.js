@@ -0,0 +1,75 @@
+import React, { useState } from "react";
+import { Link } from "react-router-dom";
+import { Mail } from "lucide-react";
+import { authService } from "../../services/authService";
+import { toast } from "react-toastify";
+
+const ForgotPassword = () => {
+  const [email, setEmail] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [sent, setSent] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setLoading(true);
+    try {
+      await authService.forgotPassword(email);
+      setSent(true);
+    } catch (error) {
+      toast.error(error.response?.data?.error || "Failed to send reset email");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-primary-50 to-primary-100 dark:from-neutral-950 dark:to-neutral-900 flex items-center justify-center p-4">
+      <div className="w-full max-w-md bg-white dark:bg-neutral-800 rounded-2xl shadow-xl p-8">
+        <h2 className="text-2xl font-semibold text-neutral-900 dark:text-white mb-6">
+          Reset password
+        </h2>
+
+        {sent ? (
+          <p className="text-neutral-600 dark:text-neutral-400">
+            If {email} belongs to an account, we sent it a link to choose a new password. The link is valid for one hour.
+          </p>
+        ) : (
+          <form onSubmit={handleSubmit} className="space-y-6">
+            <div>
+              <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
+                Email
+              </label>
+              <div className="relative">
+                <Mail className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-neutral-400" />
+                <input
+                  type="email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  className="w-full pl-11 pr-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
+                  placeholder="you@example.com"
+                  required
+                />
+              </div>
+            </div>
+
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full bg-primary-600 hover:bg-primary-700 text-white font-medium py-3 rounded-lg transition disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? "Sending..." : "Send reset link"}
+            </button>
+          </form>
+        )}
+
+        <div className="mt-6 text-center">
+          <Link to="/login" className="text-primary-600 dark:text-primary-400 hover:underline font-medium">
+            Back to sign in
+          </Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ForgotPassword;
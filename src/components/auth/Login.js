@@ -1,17 +1,30 @@
-import React, { useState } from "react";
-import { Link, useNavigate } from "react-router-dom";
+import React, { useEffect, useState } from "react";
+import { Link, useLocation, useNavigate } from "react-router-dom";
 import { motion } from "framer-motion";
-import { Mail, Lock } from "lucide-react";
+import { Mail, Lock, ShieldCheck } from "lucide-react";
 import { useAuth } from "../../contexts/AuthContext";
+import { authService } from "../../services/authService";
 import { toast } from "react-toastify";
 
 const Login = () => {
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
   const [loading, setLoading] = useState(false);
-  const { login } = useAuth();
+  const location = useLocation();
+  // Set when single sign-on still needs the second factor
+  const [mfaToken, setMfaToken] = useState(location.state?.mfaToken || null);
+  const [code, setCode] = useState("");
+  const [ssoEnabled, setSsoEnabled] = useState(false);
+  const { login, verifyLogin } = useAuth();
   const navigate = useNavigate();
 
+  useEffect(() => {
+    authService
+      .getSSOStatus()
+      .then((status) => setSsoEnabled(status.enabled))
+      .catch(() => setSsoEnabled(false));
+  }, []);
+
   const handleSubmit = async (e) => {
     e.preventDefault();
     if (!email || !password) {
@@ -21,16 +34,49 @@ const Login = () => {
 
     setLoading(true);
     try {
-      await login(email, password);
+      const data = await login(email, password);
+      if (data.mfa_required) {
+        setMfaToken(data.mfa_token);
+        return;
+      }
       toast.success("Welcome back!");
       navigate("/dashboard");
     } catch (error) {
+      if (error.response?.data?.verification_required) {
+        await authService.resendVerification(email).catch(() => {});
+        toast.info("Verify your email address first; we sent you a new link");
+        return;
+      }
       toast.error(error.response?.data?.message || "Invalid credentials");
     } finally {
       setLoading(false);
     }
   };
 
+  const handleVerify = async (e) => {
+    e.preventDefault();
+    if (!code) {
+      toast.error("Enter your authentication code");
+      return;
+    }
+
+    setLoading(true);
+    try {
+      await verifyLogin(mfaToken, code);
+      toast.success("Welcome back!");
+      navigate("/dashboard");
+    } catch (error) {
+      if (error.response?.status === 401 && error.response?.data?.error !== "Invalid verification code") {
+        // The challenge expired; start over with the password
+        setMfaToken(null);
+        setCode("");
+      }
+      toast.error(error.response?.data?.error || "Invalid verification code");
+    } finally {
+      setLoading(false);
+    }
+  };
+
   return (
     <div className="min-h-screen bg-gradient-to-br from-primary-50 to-primary-100 dark:from-neutral-950 dark:to-neutral-900 flex items-center justify-center p-4">
       <motion.div
@@ -70,51 +116,105 @@ const Login = () => {
             Sign In
           </h2>
 
-          <form onSubmit={handleSubmit} className="space-y-6">
-            <div>
-              <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
-                Email
-              </label>
-              <div className="relative">
-                <Mail className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-neutral-400" />
-                <input
-                  type="email"
-                  value={email}
-                  onChange={(e) => setEmail(e.target.value)}
-                  className="w-full pl-11 pr-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
-                  placeholder="you@example.com"
-                  required
-                />
+          {mfaToken ? (
+            <form onSubmit={handleVerify} className="space-y-6">
+              <div>
+                <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
+                  Authentication code
+                </label>
+                <div className="relative">
+                  <ShieldCheck className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-neutral-400" />
+                  <input
+                    type="text"
+                    inputMode="numeric"
+                    autoComplete="one-time-code"
+                    autoFocus
+                    value={code}
+                    onChange={(e) => setCode(e.target.value)}
+                    className="w-full pl-11 pr-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
+                    placeholder="123456"
+                    required
+                  />
+                </div>
+                <p className="mt-2 text-sm text-neutral-500 dark:text-neutral-400">
+                  Enter the code from your authenticator app, or one of your recovery codes.
+                </p>
               </div>
-            </div>
 
-            <div>
-              <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
-                Password
-              </label>
-              <div className="relative">
-                <Lock className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-neutral-400" />
-                <input
-                  type="password"
-                  value={password}
-                  onChange={(e) => setPassword(e.target.value)}
-                  className="w-full pl-11 pr-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
-                  placeholder="••••••••"
-                  required
-                />
+              <motion.button
+                whileHover={{ scale: 1.02 }}
+                whileTap={{ scale: 0.98 }}
+                type="submit"
+                disabled={loading}
+                className="w-full bg-primary-600 hover:bg-primary-700 text-white font-medium py-3 rounded-lg transition disabled:opacity-50 disabled:cursor-not-allowed shadow-lg shadow-primary-500/30"
+              >
+                {loading ? "Verifying..." : "Verify"}
+              </motion.button>
+            </form>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-6">
+              <div>
+                <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
+                  Email
+                </label>
+                <div className="relative">
+                  <Mail className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-neutral-400" />
+                  <input
+                    type="email"
+                    value={email}
+                    onChange={(e) => setEmail(e.target.value)}
+                    className="w-full pl-11 pr-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
+                    placeholder="you@example.com"
+                    required
+                  />
+                </div>
+              </div>
+
+              <div>
+                <div className="flex items-center justify-between mb-2">
+                  <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300">
+                    Password
+                  </label>
+                  <Link
+                    to="/forgot-password"
+                    className="text-sm text-primary-600 dark:text-primary-400 hover:underline"
+                  >
+                    Forgot password?
+                  </Link>
+                </div>
+                <div className="relative">
+                  <Lock className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-neutral-400" />
+                  <input
+                    type="password"
+                    value={password}
+                    onChange={(e) => setPassword(e.target.value)}
+                    className="w-full pl-11 pr-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
+                    placeholder="••••••••"
+                    required
+                  />
+                </div>
               </div>
-            </div>
 
-            <motion.button
-              whileHover={{ scale: 1.02 }}
-              whileTap={{ scale: 0.98 }}
-              type="submit"
-              disabled={loading}
-              className="w-full bg-primary-600 hover:bg-primary-700 text-white font-medium py-3 rounded-lg transition disabled:opacity-50 disabled:cursor-not-allowed shadow-lg shadow-primary-500/30"
+              <motion.button
+                whileHover={{ scale: 1.02 }}
+                whileTap={{ scale: 0.98 }}
+                type="submit"
+                disabled={loading}
+                className="w-full bg-primary-600 hover:bg-primary-700 text-white font-medium py-3 rounded-lg transition disabled:opacity-50 disabled:cursor-not-allowed shadow-lg shadow-primary-500/30"
+              >
+                {loading ? "Signing in..." : "Sign In"}
+              </motion.button>
+            </form>
+          )}
+
+          {ssoEnabled && !mfaToken && (
+            <a
+              href={authService.getSSOLoginUrl()}
+              className="mt-4 block w-full text-center border border-neutral-300 dark:border-neutral-700 text-neutral-700 dark:text-neutral-200 hover:bg-neutral-50 dark:hover:bg-neutral-900 font-medium py-3 rounded-lg transition"
             >
-              {loading ? "Signing in..." : "Sign In"}
-            </motion.button>
-          </form>
+              Sign in with SSO
+            </a>
+          )}
 
           <div className="mt-6 text-center">
             <p className="text-neutral-600 dark:text-neutral-400">
@@ -34,7 +34,12 @@ const Register = () => {
 
     setLoading(true);
     try {
-      await register(email, password, name);
+      const data = await register(email, password, name);
+      if (data.verification_required) {
+        toast.info("Check your email to verify your address, then sign in");
+        navigate("/login");
+        return;
+      }
       toast.success("Account created successfully!");
       navigate("/dashboard");
     } catch (error) {
@@ -0,0 +1,50 @@
+import React, { useEffect, useRef } from "react";
+import { useNavigate } from "react-router-dom";
+import { toast } from "react-toastify";
+import { useAuth } from "../../contexts/AuthContext";
+
+// Landing page for single sign-on. The backend hands over the session tokens
+// in the URL fragment so they never appear in server logs.
+const SSOCallback = () => {
+  const { completeSSOLogin } = useAuth();
+  const navigate = useNavigate();
+  const handled = useRef(false);
+
+  useEffect(() => {
+    if (handled.current) return;
+    handled.current = true;
+
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    window.history.replaceState(null, "", window.location.pathname);
+
+    if (params.get("error")) {
+      toast.error(params.get("error"));
+      navigate("/login", { replace: true });
+      return;
+    }
+
+    // Accounts with 2FA finish on the login page with their code
+    if (params.get("mfa_token")) {
+      navigate("/login", { replace: true, state: { mfaToken: params.get("mfa_token") } });
+      return;
+    }
+
+    completeSSOLogin(params.get("token"), params.get("refresh_token"))
+      .then(() => {
+        toast.success("Welcome back!");
+        navigate("/dashboard", { replace: true });
+      })
+      .catch(() => {
+        toast.error("Single sign-on failed");
+        navigate("/login", { replace: true });
+      });
+  }, [completeSSOLogin, navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-neutral-50 dark:bg-neutral-950">
+      <div className="animate-spin rounded-full h-12 w-12 border-4 border-primary-500 border-t-transparent"></div>
+    </div>
+  );
+};
+
+export default SSOCallback;
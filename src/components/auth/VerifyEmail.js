@@ -0,0 +1,46 @@
+import React, { useEffect, useRef, useState } from "react";
+import { Link, useSearchParams } from "react-router-dom";
+import { authService } from "../../services/authService";
+
+const VerifyEmail = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState("verifying");
+  const [message, setMessage] = useState("");
+  const requested = useRef(false);
+
+  useEffect(() => {
+    // Tokens are single-use, so only submit once even in strict mode
+    if (requested.current) return;
+    requested.current = true;
+
+    authService
+      .verifyEmail(searchParams.get("token"))
+      .then(() => setStatus("verified"))
+      .catch((error) => {
+        setStatus("failed");
+        setMessage(error.response?.data?.error || "Verification failed");
+      });
+  }, [searchParams]);
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-primary-50 to-primary-100 dark:from-neutral-950 dark:to-neutral-900 flex items-center justify-center p-4">
+      <div className="w-full max-w-md bg-white dark:bg-neutral-800 rounded-2xl shadow-xl p-8 text-center">
+        <h2 className="text-2xl font-semibold text-neutral-900 dark:text-white mb-4">
+          {status === "verifying" && "Verifying your email..."}
+          {status === "verified" && "Email verified"}
+          {status === "failed" && "Verification failed"}
+        </h2>
+        {status === "failed" && (
+          <p className="text-neutral-600 dark:text-neutral-400 mb-4">{message}</p>
+        )}
+        {status !== "verifying" && (
+          <Link to="/login" className="text-primary-600 dark:text-primary-400 hover:underline font-medium">
+            Continue to sign in
+          </Link>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default VerifyEmail;
@@ -14,6 +14,7 @@ import {
 } from 'lucide-react';
 import { commandService } from '../../services/commandService';
 import { useWebSocket } from '../../hooks/useWebSocket';
+import { wsService } from '../../services/websocketService';
 
 const CommandConsole = ({ isMobile = false }) => {
   const [isOpen, setIsOpen] = useState(false);
@@ -38,19 +39,19 @@ const CommandConsole = ({ isMobile = false }) => {
 
   useEffect(() => {
     // Listen for command results via WebSocket
-    const handleCommandResult = (event) => {
-      const data = event.detail;
-      if (data.type === 'command:result') {
-        setCurrentExecution(data);
-        setIsExecuting(false);
-        
-        // Update history
-        loadCommandHistory();
-      }
-    };
+    const releaseTopic = wsService.subscribeTopic('commands');
+    const unsubscribe = wsService.subscribe('command:result', (data) => {
+      setCurrentExecution(data);
+      setIsExecuting(false);
 
-    window.addEventListener('command:result', handleCommandResult);
-    return () => window.removeEventListener('command:result', handleCommandResult);
+      // Update history
+      loadCommandHistory();
+    });
+
+    return () => {
+      unsubscribe();
+      releaseTopic();
+    };
   }, []);
 
   const loadAvailableCommands = async () => {
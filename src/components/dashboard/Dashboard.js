@@ -8,6 +8,7 @@ import MonitorDetailDrawer from "./MonitorDetailDrawer";
 import StatsOverview from "./StatsOverview";
 import NotificationCenter from "../notifications/NotificationCenter";
 import { useMonitors } from "../../hooks/useMonitors";
+import { monitorService } from "../../services/monitorService";
 import { useWebSocket } from "../../hooks/useWebSocket";
 import {
   useUserPreferences,
@@ -44,8 +45,17 @@ const Dashboard = () => {
   }, [preferences]);
 
   useEffect(() => {
+    monitorService
+      .getMood()
+      .then((data) => {
+        if (typeof data?.mood?.mood === "number") setSystemMood(data.mood.mood);
+      })
+      .catch(() => {});
+
     const handler = (e) => {
-      const { mood } = e.detail || {};
+      // Team moods are sent too; the dashboard shows the personal workspace
+      const { mood, scope } = e.detail || {};
+      if (scope && scope !== "user") return;
       if (typeof mood === "number") setSystemMood(mood);
     };
     window.addEventListener("system_mood_update", handler);
@@ -23,7 +23,11 @@ import {
   Legend,
   Filler,
 } from "chart.js";
-import { useMonitorHistory, useMonitorStats } from "../../hooks/useMonitors";
+import {
+  useMonitorHistory,
+  useMonitorStats,
+  useMonitorTimings,
+} from "../../hooks/useMonitors";
 import { monitorService } from "../../services/monitorService";
 import {
   formatLatency,
@@ -38,6 +42,7 @@ import { snapshotsService } from "../../services/snapshotsService";
 import { incidentsService } from "../../services/incidentsService";
 import AISummary from '../common/AISummary';
 import SLACard from '../common/SLACard';
+import TimingBreakdown, { phaseLabel } from "./TimingBreakdown";
 
 ChartJS.register(
   CategoryScale,
@@ -52,9 +57,14 @@ ChartJS.register(
 
 const MonitorDetailDrawer = ({ monitor, isOpen, onClose }) => {
   const [timeRange, setTimeRange] = useState("24h");
+  const historyDays = timeRange === "24h" ? 1 : timeRange === "7d" ? 7 : 30;
   const { data: history, isLoading: historyLoading } = useMonitorHistory(
     monitor?.id,
-    timeRange === "24h" ? 1 : timeRange === "7d" ? 7 : 30,
+    historyDays,
+  );
+  const { data: timings } = useMonitorTimings(
+    monitor?.type === "http" ? monitor?.id : null,
+    historyDays,
   );
   const { data: stats } = useMonitorStats(monitor?.id);
   const [activeTab, setActiveTab] = useState("performance");
@@ -113,6 +123,17 @@ const MonitorDetailDrawer = ({ monitor, isOpen, onClose }) => {
     setIncidentId(`monitor-${monitor.id}`);
   }, [monitor]);
 
+  // Subscribe to this monitor's live topics while the drawer is open
+  useEffect(() => {
+    if (!monitor || !incidentId) return;
+    const releases = [
+      wsService.subscribeTopic(`monitor:${monitor.id}`),
+      wsService.subscribeTopic(`incident:${incidentId}`),
+      wsService.subscribeTopic(`logs:${incidentId}`),
+    ];
+    return () => releases.forEach((release) => release());
+  }, [monitor, incidentId]);
+
   // Fetch latest incident for AI summary
   useEffect(() => {
     const fetchLatestIncident = async () => {
@@ -451,6 +472,29 @@ const MonitorDetailDrawer = ({ monitor, isOpen, onClose }) => {
                     </div>
                   </div>
 
+                  {/* Response Time Breakdown */}
+                  {timings?.overall?.timed_checks > 0 && (
+                    <div className="bg-neutral-50 dark:bg-neutral-800 rounded-xl p-4">
+                      <div className="flex items-center justify-between mb-3">
+                        <h3 className="text-lg font-semibold text-neutral-900 dark:text-white">
+                          Response Time Breakdown
+                        </h3>
+                        <span className="text-xs text-neutral-500 dark:text-neutral-400">
+                          Average of {timings.overall.timed_checks} successful checks
+                        </span>
+                      </div>
+                      <TimingBreakdown timing={timings.overall} />
+                      {timings.overall.slowest_phase && (
+                        <p className="mt-2 text-xs text-neutral-500 dark:text-neutral-400">
+                          Most time goes to{" "}
+                          <span className="font-medium text-neutral-700 dark:text-neutral-200">
+                            {phaseLabel(timings.overall.slowest_phase)}
+                          </span>
+                        </p>
+                      )}
+                    </div>
+                  )}
+
                   {/* Recent Checks */}
                   <div>
                     <h3 className="text-lg font-semibold text-neutral-900 dark:text-white mb-4">
@@ -489,6 +533,9 @@ const MonitorDetailDrawer = ({ monitor, isOpen, onClose }) => {
                                 <p className="text-xs text-neutral-500 dark:text-neutral-400 truncate">
                                   {formatDateTime(check.created_at)}
                                 </p>
+                                {check.timing && (
+                                  <TimingBreakdown timing={check.timing} compact />
+                                )}
                               </div>
                               <div className="text-right">
                                 <p className="text-sm font-medium text-neutral-900 dark:text-white">
@@ -690,6 +737,9 @@ const MonitorDetailDrawer = ({ monitor, isOpen, onClose }) => {
                               {it.detail}
                             </p>
                           )}
+                          {it.timing && (
+                            <TimingBreakdown timing={it.timing} compact />
+                          )}
                         </div>
                       </div>
                     ))
@@ -702,6 +752,12 @@ const MonitorDetailDrawer = ({ monitor, isOpen, onClose }) => {
                 </div>
                 <div className="mt-2 text-xs text-neutral-500 dark:text-neutral-400">
                   Trend: {rootCause?.summary?.trend || "inconclusive"}
+                  {rootCause?.summary?.timing?.slowest_phase && (
+                    <>
+                      {" "}· Slowest phase of successful checks:{" "}
+                      {phaseLabel(rootCause.summary.timing.slowest_phase)}
+                    </>
+                  )}
                 </div>
               </div>
 
@@ -5,7 +5,7 @@ import {
   CheckCircle, XCircle, Loader, Zap, Palette 
 } from 'lucide-react';
 import { MONITOR_TYPES, INTERVALS, HTTP_METHODS } from '../../utils/constants';
-import { useCreateMonitor, useUpdateMonitor } from '../../hooks/useMonitors';
+import { useCreateMonitor, useUpdateMonitor, useLocations } from '../../hooks/useMonitors';
 import { monitorService } from '../../services/monitorService';
 import { toast } from 'react-toastify';
 
@@ -21,6 +21,9 @@ const MonitorModal = ({ isOpen, onClose, monitor = null }) => {
     timeout: 10,
     enabled: true,
     tags: '',
+    criticality: 'normal',
+    locations: [],
+    min_failed_locations: 1,
   });
   const [errors, setErrors] = useState({});
   const [testing, setTesting] = useState(false);
@@ -28,6 +31,7 @@ const MonitorModal = ({ isOpen, onClose, monitor = null }) => {
 
   const createMonitor = useCreateMonitor();
   const updateMonitor = useUpdateMonitor();
+  const { data: locations = [] } = useLocations(isOpen);
   const isEdit = !!monitor;
 
   useEffect(() => {
@@ -42,6 +46,9 @@ const MonitorModal = ({ isOpen, onClose, monitor = null }) => {
         timeout: monitor.timeout || 10,
         enabled: monitor.enabled !== false,
         tags: monitor.tags?.join(', ') || '',
+        criticality: monitor.criticality || 'normal',
+        locations: monitor.locations || [],
+        min_failed_locations: monitor.min_failed_locations || 1,
       });
       monitorService.clearDraft();
     } else {
@@ -151,6 +158,12 @@ const MonitorModal = ({ isOpen, onClose, monitor = null }) => {
       timeout: parseInt(formData.timeout),
       enabled: formData.enabled,
       tags: formData.tags.split(',').map(t => t.trim()).filter(t => t),
+      criticality: formData.criticality || 'normal',
+      locations: formData.locations || [],
+      min_failed_locations: Math.min(
+        parseInt(formData.min_failed_locations) || 1,
+        Math.max((formData.locations || []).length, 1)
+      ),
     };
 
     try {
@@ -506,6 +519,77 @@ const MonitorModal = ({ isOpen, onClose, monitor = null }) => {
                       </p>
                     </div>
 
+                    {/* Criticality */}
+                    <div>
+                      <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
+                        Criticality
+                      </label>
+                      <select
+                        value={formData.criticality}
+                        onChange={(e) => setFormData({ ...formData, criticality: e.target.value })}
+                        className="w-full px-4 py-3 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent outline-none transition text-neutral-900 dark:text-white"
+                      >
+                        <option value="low">Low</option>
+                        <option value="normal">Normal</option>
+                        <option value="high">High</option>
+                        <option value="critical">Critical</option>
+                      </select>
+                      <p className="mt-1 text-xs text-neutral-500 dark:text-neutral-400">
+                        How much this monitor affects the dashboard mood
+                      </p>
+                    </div>
+
+                    {/* Locations */}
+                    {locations.length > 1 && (
+                      <div>
+                        <label className="block text-sm font-medium text-neutral-700 dark:text-neutral-300 mb-2">
+                          Check from
+                        </label>
+                        <div className="flex flex-wrap gap-2">
+                          {locations.map((location) => {
+                            const selected = (formData.locations || []).includes(location.name);
+                            return (
+                              <button
+                                key={location.name}
+                                type="button"
+                                onClick={() => setFormData({
+                                  ...formData,
+                                  locations: selected
+                                    ? formData.locations.filter((l) => l !== location.name)
+                                    : [...(formData.locations || []), location.name],
+                                })}
+                                className={`px-3 py-1.5 rounded-lg text-sm border transition ${
+                                  selected
+                                    ? 'bg-primary-500 border-primary-500 text-white'
+                                    : 'bg-neutral-50 dark:bg-neutral-900 border-neutral-300 dark:border-neutral-700 text-neutral-700 dark:text-neutral-300'
+                                }`}
+                              >
+                                {location.name}
+                                {!location.local && location.online_agents === 0 && ' (offline)'}
+                              </button>
+                            );
+                          })}
+                        </div>
+                        <p className="mt-1 text-xs text-neutral-500 dark:text-neutral-400">
+                          None selected checks from the server only
+                        </p>
+                        {(formData.locations || []).length > 1 && (
+                          <div className="mt-3 flex items-center gap-2 text-sm text-neutral-700 dark:text-neutral-300">
+                            <span>Down when</span>
+                            <input
+                              type="number"
+                              min="1"
+                              max={formData.locations.length}
+                              value={formData.min_failed_locations}
+                              onChange={(e) => setFormData({ ...formData, min_failed_locations: e.target.value })}
+                              className="w-16 px-2 py-1 bg-neutral-50 dark:bg-neutral-900 border border-neutral-300 dark:border-neutral-700 rounded-lg outline-none text-neutral-900 dark:text-white"
+                            />
+                            <span>of {formData.locations.length} locations fail</span>
+                          </div>
+                        )}
+                      </div>
+                    )}
+
                     {/* Enabled Toggle */}
                     <div className="flex items-center justify-between p-4 bg-neutral-50 dark:bg-neutral-900 rounded-lg">
                       <div>
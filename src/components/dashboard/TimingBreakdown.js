@@ -0,0 +1,59 @@
+import React from "react";
+import { formatLatency } from "../../utils/formatters";
+
+// Phases of an HTTP check, in the order they happen
+export const TIMING_PHASES = [
+  { key: "dns_ms", phase: "dns", label: "DNS", color: "bg-sky-400" },
+  { key: "connect_ms", phase: "connect", label: "Connect", color: "bg-indigo-400" },
+  { key: "tls_ms", phase: "tls", label: "TLS", color: "bg-violet-400" },
+  { key: "ttfb_ms", phase: "ttfb", label: "TTFB", color: "bg-amber-400" },
+  { key: "transfer_ms", phase: "transfer", label: "Transfer", color: "bg-emerald-400" },
+];
+
+export const phaseLabel = (phase) =>
+  TIMING_PHASES.find((p) => p.phase === phase)?.label || phase;
+
+// TimingBreakdown shows where the time of an HTTP check went as a stacked
+// bar, with the duration of each phase below it
+const TimingBreakdown = ({ timing, compact = false }) => {
+  if (!timing) return null;
+  const total = TIMING_PHASES.reduce((sum, p) => sum + (timing[p.key] || 0), 0);
+
+  return (
+    <div className={compact ? "mt-1" : ""}>
+      <div
+        className={`flex w-full overflow-hidden rounded-full bg-neutral-200 dark:bg-neutral-700 ${compact ? "h-1.5" : "h-3"}`}
+      >
+        {total > 0 &&
+          TIMING_PHASES.map((p) =>
+            timing[p.key] > 0 ? (
+              <div
+                key={p.key}
+                className={p.color}
+                style={{ width: `${(timing[p.key] / total) * 100}%` }}
+                title={`${p.label} ${formatLatency(timing[p.key])}`}
+              />
+            ) : null,
+          )}
+      </div>
+      <div
+        className={`flex flex-wrap gap-x-3 gap-y-1 text-neutral-500 dark:text-neutral-400 ${compact ? "mt-1 text-[11px]" : "mt-2 text-xs"}`}
+      >
+        {TIMING_PHASES.map((p) => (
+          <span
+            key={p.key}
+            className={`inline-flex items-center gap-1 ${timing.failed_phase === p.phase ? "text-danger-500 font-medium" : ""}`}
+          >
+            <span className={`w-2 h-2 rounded-full ${p.color}`} />
+            {p.label} {formatLatency(timing[p.key] || 0)}
+          </span>
+        ))}
+        {timing.remote_ip && <span>{timing.remote_ip}</span>}
+        {timing.protocol && <span>{timing.protocol}</span>}
+        {timing.conn_reused && <span>reused connection</span>}
+      </div>
+    </div>
+  );
+};
+
+export default TimingBreakdown;
@@ -1,36 +1,186 @@
-import React, { useEffect, useState } from 'react';
+import React, { useCallback, useEffect, useState } from 'react';
+import { useParams } from 'react-router-dom';
+import { formatDateTime, formatLatency, formatUptime } from '../../utils/formatters';
 
+const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8080/api';
+const REFRESH_INTERVAL = 60000;
+
+const PAGE_STATUS = {
+  operational: { label: 'All systems operational', className: 'bg-success-600' },
+  degraded: { label: 'Some systems are experiencing issues', className: 'bg-warning-500' },
+  major_outage: { label: 'Major outage', className: 'bg-danger-600' },
+  maintenance: { label: 'Scheduled maintenance in progress', className: 'bg-primary-600' },
+};
+
+const statusBadgeClass = (status) => {
+  if (status === 'up') return 'bg-success-100 text-success-700 dark:bg-success-900/30 dark:text-success-400';
+  if (status === 'down') return 'bg-danger-100 text-danger-700 dark:bg-danger-900/30 dark:text-danger-400';
+  if (status === 'degraded') return 'bg-warning-100 text-warning-700 dark:bg-warning-900/30 dark:text-warning-400';
+  return 'bg-neutral-100 text-neutral-700 dark:bg-neutral-800 dark:text-neutral-300';
+};
+
+const barClass = (uptime) => {
+  if (uptime === null || uptime === undefined) return 'bg-neutral-200 dark:bg-neutral-800';
+  if (uptime >= 99.5) return 'bg-success-500';
+  if (uptime >= 95) return 'bg-warning-500';
+  return 'bg-danger-500';
+};
+
+// A page is loaded by slug (/status/:slug) or, on a custom domain, from the
+// same origin's /api/status so the backend can match the Host header
 const StatusPublicPage = () => {
+  const { slug } = useParams();
+  const endpoint = slug ? `${API_BASE_URL}/status/${encodeURIComponent(slug)}` : '/api/status';
+  const tokenKey = `status-page-token:${slug || window.location.host}`;
+
   const [data, setData] = useState(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
+  const [locked, setLocked] = useState(null);
+  const [password, setPassword] = useState('');
+  const [unlockError, setUnlockError] = useState(null);
+  const [notice, setNotice] = useState(null);
+  const [subscribe, setSubscribe] = useState({ type: 'email', email: '', url: '', components: [] });
+  const [subscribeResult, setSubscribeResult] = useState(null);
 
-  useEffect(() => {
-    const slug = window.location.pathname.split('/').pop();
-    const load = async () => {
-      try {
-        const res = await fetch(`/status/${slug}`);
-        if (!res.ok) throw new Error('Failed to load status page');
-        const json = await res.json();
-        setData(json);
-      } catch (e) {
-        setError(e.message);
-      } finally {
-        setLoading(false);
+  const load = useCallback(async () => {
+    try {
+      const token = sessionStorage.getItem(tokenKey);
+      const res = await fetch(endpoint, { headers: token ? { 'X-Status-Page-Token': token } : {} });
+      const json = await res.json();
+      if (res.status === 401 && json.password_required) {
+        sessionStorage.removeItem(tokenKey);
+        setLocked({ name: json.name });
+        return;
       }
-    };
+      if (!res.ok) throw new Error(json.error || 'Failed to load status page');
+      setLocked(null);
+      setData(json);
+      setError(null);
+    } catch (e) {
+      setError(e.message);
+    } finally {
+      setLoading(false);
+    }
+  }, [endpoint, tokenKey]);
+
+  useEffect(() => {
     load();
-  }, []);
+    const timer = setInterval(load, REFRESH_INTERVAL);
+    return () => clearInterval(timer);
+  }, [load]);
+
+  // Links in subscription emails come back here with ?confirm= or
+  // ?unsubscribe=&token=
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    const confirmToken = params.get('confirm');
+    const unsubscribeId = params.get('unsubscribe');
+    let request = null;
+    if (confirmToken) {
+      request = { path: 'confirm', body: { token: confirmToken } };
+    } else if (unsubscribeId) {
+      request = { path: 'unsubscribe', body: { id: Number(unsubscribeId), token: params.get('token') || '' } };
+    }
+    if (!request) return;
+
+    window.history.replaceState(null, '', window.location.pathname);
+    const base = slug ? API_BASE_URL : '/api';
+    fetch(`${base}/status-subscriptions/${request.path}`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(request.body),
+    })
+      .then((res) => res.json().then((json) => setNotice({ ok: res.ok, text: json.message || json.error })))
+      .catch(() => setNotice({ ok: false, text: 'Request failed' }));
+  }, [slug]);
+
+  const feedURL = (name) => {
+    const token = sessionStorage.getItem(tokenKey);
+    return `${endpoint}/${name}${token ? `?token=${encodeURIComponent(token)}` : ''}`;
+  };
+
+  const toggleComponent = (key) => {
+    setSubscribe((s) => ({
+      ...s,
+      components: s.components.includes(key) ? s.components.filter((k) => k !== key) : [...s.components, key],
+    }));
+  };
+
+  const submitSubscription = async (e) => {
+    e.preventDefault();
+    setSubscribeResult(null);
+    const token = sessionStorage.getItem(tokenKey);
+    const body = { type: subscribe.type, components: subscribe.components };
+    if (subscribe.type === 'email') body.email = subscribe.email;
+    else body.url = subscribe.url;
+    try {
+      const res = await fetch(`${endpoint}/subscribe`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', ...(token ? { 'X-Status-Page-Token': token } : {}) },
+        body: JSON.stringify(body),
+      });
+      const json = await res.json();
+      if (!res.ok) throw new Error(json.error || 'Failed to subscribe');
+      setSubscribeResult({ ok: true, message: json.message, webhook: json.secret ? json : null });
+      setSubscribe({ type: subscribe.type, email: '', url: '', components: [] });
+    } catch (err) {
+      setSubscribeResult({ ok: false, message: err.message });
+    }
+  };
+
+  const unlock = async (e) => {
+    e.preventDefault();
+    setUnlockError(null);
+    try {
+      const res = await fetch(`${endpoint}/unlock`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ password }),
+      });
+      const json = await res.json();
+      if (!res.ok) throw new Error(json.error || 'Failed to unlock');
+      sessionStorage.setItem(tokenKey, json.token);
+      setPassword('');
+      load();
+    } catch (err) {
+      setUnlockError(err.message);
+    }
+  };
 
   if (loading) {
     return <div className="min-h-screen bg-neutral-50 dark:bg-neutral-950 flex items-center justify-center text-neutral-600 dark:text-neutral-300">Loading...</div>;
   }
+  if (locked) {
+    return (
+      <div className="min-h-screen bg-neutral-50 dark:bg-neutral-950 flex items-center justify-center px-4">
+        <form onSubmit={unlock} className="w-full max-w-sm bg-white dark:bg-neutral-900 rounded-xl border border-neutral-200 dark:border-neutral-800 p-6 space-y-4">
+          <h1 className="text-lg font-bold text-neutral-900 dark:text-white">{locked.name || 'Status'}</h1>
+          <p className="text-sm text-neutral-600 dark:text-neutral-300">This status page is password protected.</p>
+          <input
+            type="password"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            placeholder="Password"
+            className="w-full px-3 py-2 rounded-lg border border-neutral-300 dark:border-neutral-700 bg-white dark:bg-neutral-800 text-neutral-900 dark:text-white"
+          />
+          {unlockError && <div className="text-sm text-danger-600">{unlockError}</div>}
+          <button type="submit" className="w-full px-4 py-2 rounded-lg bg-primary-600 hover:bg-primary-700 text-white font-medium">
+            View status
+          </button>
+        </form>
+      </div>
+    );
+  }
   if (error || !data) {
     return <div className="min-h-screen bg-neutral-50 dark:bg-neutral-950 flex items-center justify-center text-danger-600">{error || 'Not found'}</div>;
   }
 
   const page = data.page || {};
-  const monitors = data.monitors || [];
+  const components = data.components || [];
+  const incidents = data.incidents || [];
+  const maintenances = data.maintenances || [];
+  const overall = PAGE_STATUS[data.status] || PAGE_STATUS.operational;
 
   return (
     <div className="min-h-screen bg-neutral-50 dark:bg-neutral-950">
@@ -44,28 +194,143 @@ const StatusPublicPage = () => {
         </div>
       </div>
 
-      <main className="max-w-6xl mx-auto px-4 py-6">
+      <main className="max-w-6xl mx-auto px-4 py-6 space-y-6">
+        {notice && (
+          <div className={`rounded-lg px-4 py-2 text-sm ${notice.ok ? 'bg-success-100 text-success-700 dark:bg-success-900/30 dark:text-success-400' : 'bg-danger-100 text-danger-700 dark:bg-danger-900/30 dark:text-danger-400'}`}>
+            {notice.text}
+          </div>
+        )}
+
+        {page.description && <p className="text-neutral-600 dark:text-neutral-300">{page.description}</p>}
+
+        <div className={`rounded-xl px-4 py-3 text-white font-semibold ${overall.className}`}>{overall.label}</div>
+
+        {incidents.length > 0 && (
+          <section className="space-y-3">
+            <h2 className="text-base font-semibold text-neutral-900 dark:text-white">Incidents</h2>
+            {incidents.map((incident) => (
+              <div key={incident.id} className="bg-white dark:bg-neutral-900 rounded-xl border border-neutral-200 dark:border-neutral-800 p-4">
+                <div className="flex items-center justify-between mb-2">
+                  <div className="font-semibold text-neutral-900 dark:text-white">{incident.title}</div>
+                  <span className="px-2 py-0.5 rounded text-xs bg-neutral-100 text-neutral-700 dark:bg-neutral-800 dark:text-neutral-300">{incident.status}</span>
+                </div>
+                <ul className="space-y-2">
+                  {(incident.updates || []).map((update) => (
+                    <li key={update.id} className="text-sm">
+                      <span className="font-medium text-neutral-900 dark:text-white capitalize">{update.status}</span>
+                      <span className="text-neutral-600 dark:text-neutral-300"> - {update.message}</span>
+                      <div className="text-xs text-neutral-500 dark:text-neutral-400">{formatDateTime(update.created_at)}</div>
+                    </li>
+                  ))}
+                </ul>
+              </div>
+            ))}
+          </section>
+        )}
+
+        {maintenances.length > 0 && (
+          <section className="space-y-3">
+            <h2 className="text-base font-semibold text-neutral-900 dark:text-white">Scheduled maintenance</h2>
+            {maintenances.map((m) => (
+              <div key={m.id} className="bg-white dark:bg-neutral-900 rounded-xl border border-neutral-200 dark:border-neutral-800 p-4">
+                <div className="font-semibold text-neutral-900 dark:text-white">{m.title}</div>
+                {m.description && <div className="text-sm text-neutral-600 dark:text-neutral-300">{m.description}</div>}
+                <div className="text-xs text-neutral-500 dark:text-neutral-400 mt-1">
+                  {formatDateTime(m.starts_at)} - {formatDateTime(m.ends_at)}
+                </div>
+              </div>
+            ))}
+          </section>
+        )}
+
         <div className={page.layout === 'list' ? 'grid grid-cols-1 gap-4' : 'grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-3 gap-6'}>
-          {monitors.map((m) => (
-            <div key={m.id} className="bg-white dark:bg-neutral-900 rounded-xl border border-neutral-200 dark:border-neutral-800 p-4">
+          {components.map((c) => (
+            <div key={`${c.type}-${c.id}`} className="bg-white dark:bg-neutral-900 rounded-xl border border-neutral-200 dark:border-neutral-800 p-4">
               <div className="flex items-center justify-between mb-2">
-                <div className="font-semibold text-neutral-900 dark:text-white truncate">{m.name}</div>
-                <span className={`px-2 py-0.5 rounded text-xs ${m.status === 'up' ? 'bg-success-100 text-success-700 dark:bg-success-900/30 dark:text-success-400' : m.status === 'down' ? 'bg-danger-100 text-danger-700 dark:bg-danger-900/30 dark:text-danger-400' : 'bg-neutral-100 text-neutral-700 dark:bg-neutral-800 dark:text-neutral-300'}`}>{m.status}</span>
+                <div className="font-semibold text-neutral-900 dark:text-white truncate">{c.name}</div>
+                <span className={`px-2 py-0.5 rounded text-xs ${statusBadgeClass(c.status)}`}>{c.status}</span>
               </div>
-              {page.show_uptime !== false && (
-                <div className="text-sm text-neutral-600 dark:text-neutral-300 mb-2">Uptime: {(m.uptime_percent || 0).toFixed(2)}%</div>
+              {page.show_uptime && (
+                <>
+                  <div className="text-sm text-neutral-600 dark:text-neutral-300 mb-2">Uptime: {formatUptime(c.uptime_percent || 0)}</div>
+                  <div className="flex gap-px h-6 mb-2">
+                    {(c.uptime_days || []).map((day) => (
+                      <div
+                        key={day.date}
+                        className={`flex-1 rounded-sm ${barClass(day.uptime_percent)}`}
+                        title={`${day.date}: ${day.uptime_percent === null ? 'No data' : formatUptime(day.uptime_percent)}`}
+                      />
+                    ))}
+                  </div>
+                </>
               )}
-              {page.show_latency !== false && (
-                <div className="text-xs text-neutral-500 dark:text-neutral-400">Last latency: {m.last_latency_ms ? `${m.last_latency_ms}ms` : '—'}</div>
+              {page.show_latency && c.type === 'monitor' && (
+                <div className="text-xs text-neutral-500 dark:text-neutral-400">Last latency: {c.last_latency_ms ? formatLatency(c.last_latency_ms) : '—'}</div>
               )}
             </div>
           ))}
         </div>
+
+        <section className="bg-white dark:bg-neutral-900 rounded-xl border border-neutral-200 dark:border-neutral-800 p-4 space-y-3">
+          <div className="flex items-center justify-between flex-wrap gap-2">
+            <h2 className="text-base font-semibold text-neutral-900 dark:text-white">Subscribe to updates</h2>
+            <div className="flex gap-3 text-sm">
+              <a href={feedURL('feed.rss')} className="text-primary-600 hover:underline">RSS</a>
+              <a href={feedURL('feed.atom')} className="text-primary-600 hover:underline">Atom</a>
+              <a href={feedURL('maintenance.ics')} className="text-primary-600 hover:underline">Maintenance calendar</a>
+            </div>
+          </div>
+          <form onSubmit={submitSubscription} className="space-y-3">
+            <div className="flex gap-2">
+              <select
+                value={subscribe.type}
+                onChange={(e) => setSubscribe({ ...subscribe, type: e.target.value })}
+                className="px-3 py-2 rounded-lg border border-neutral-300 dark:border-neutral-700 bg-white dark:bg-neutral-800 text-neutral-900 dark:text-white"
+              >
+                <option value="email">Email</option>
+                <option value="webhook">Webhook</option>
+              </select>
+              <input
+                type={subscribe.type === 'email' ? 'email' : 'url'}
+                required
+                value={subscribe.type === 'email' ? subscribe.email : subscribe.url}
+                onChange={(e) => setSubscribe({ ...subscribe, [subscribe.type === 'email' ? 'email' : 'url']: e.target.value })}
+                placeholder={subscribe.type === 'email' ? 'you@example.com' : 'https://example.com/hooks/status'}
+                className="flex-1 px-3 py-2 rounded-lg border border-neutral-300 dark:border-neutral-700 bg-white dark:bg-neutral-800 text-neutral-900 dark:text-white"
+              />
+              <button type="submit" className="px-4 py-2 rounded-lg bg-primary-600 hover:bg-primary-700 text-white font-medium">
+                Subscribe
+              </button>
+            </div>
+            {components.length > 1 && (
+              <div className="flex flex-wrap gap-3 text-sm text-neutral-700 dark:text-neutral-300">
+                <span className="text-neutral-500 dark:text-neutral-400">Only for (leave empty for all):</span>
+                {components.map((c) => (
+                  <label key={c.key} className="flex items-center gap-1">
+                    <input type="checkbox" checked={subscribe.components.includes(c.key)} onChange={() => toggleComponent(c.key)} />
+                    {c.name}
+                  </label>
+                ))}
+              </div>
+            )}
+          </form>
+          {subscribeResult && (
+            <div className={`text-sm ${subscribeResult.ok ? 'text-success-700 dark:text-success-400' : 'text-danger-600'}`}>
+              {subscribeResult.webhook ? (
+                <>
+                  Webhook subscribed. Deliveries are signed with this secret in the X-RunnerX-Signature header; keep it, it is not shown again:
+                  <code className="block mt-1 break-all">{subscribeResult.webhook.secret}</code>
+                  To unsubscribe, POST {'{'}"id": {subscribeResult.webhook.id}, "token": "{subscribeResult.webhook.unsubscribe_token}"{'}'} to /api/status-subscriptions/unsubscribe.
+                </>
+              ) : (
+                subscribeResult.message
+              )}
+            </div>
+          )}
+        </section>
       </main>
     </div>
   );
 };
 
 export default StatusPublicPage;
-
-
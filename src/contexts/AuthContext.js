@@ -49,13 +49,35 @@ export const AuthProvider = ({ children }) => {
 
   const login = async (email, password) => {
     const data = await authService.login(email, password);
+    // With 2FA enabled the caller must finish with verifyLogin
+    if (data.mfa_required) {
+      return data;
+    }
+    setUser(data.user);
+    setIsLocked(false);
+    return data;
+  };
+
+  const verifyLogin = async (mfaToken, code) => {
+    const data = await authService.verifyLogin(mfaToken, code);
     setUser(data.user);
     setIsLocked(false);
     return data;
   };
 
+  const completeSSOLogin = async (token, refreshToken) => {
+    const currentUser = await authService.completeSSOLogin(token, refreshToken);
+    setUser(currentUser);
+    setIsLocked(false);
+    return currentUser;
+  };
+
   const register = async (email, password, name) => {
     const data = await authService.register(email, password, name);
+    // Accounts that must verify their email first are not signed in
+    if (data.verification_required) {
+      return data;
+    }
     setUser(data.user);
     setIsLocked(false);
     return data;
@@ -70,6 +92,8 @@ export const AuthProvider = ({ children }) => {
   const unlock = async (password) => {
     if (!user) return false;
     try {
+      // The password is enough to lift the idle lock; with 2FA enabled no new
+      // session is issued and the current one is kept
       await authService.login(user.email, password);
       setIsLocked(false);
       return true;
@@ -89,6 +113,8 @@ export const AuthProvider = ({ children }) => {
     login,
     register,
     logout,
+    verifyLogin,
+    completeSSOLogin,
     unlock,
     lock,
     isAuthenticated: !!user && !isLocked,
@@ -82,6 +82,26 @@ export const useMonitorHistory = (id, days = 7) => {
   });
 };
 
+export const useMonitorTimings = (id, days = 7) => {
+  return useQuery({
+    queryKey: ["monitor", id, "timings", days],
+    queryFn: () => monitorService.getMonitorTimings(id, days),
+    enabled: !!id,
+    refetchOnWindowFocus: false,
+    staleTime: 60000,
+  });
+};
+
+export const useLocations = (enabled = true) => {
+  return useQuery({
+    queryKey: ["locations"],
+    queryFn: monitorService.getLocations,
+    enabled,
+    refetchOnWindowFocus: false,
+    staleTime: 60000,
+  });
+};
+
 export const useCreateMonitor = () => {
   const queryClient = useQueryClient();
 
@@ -23,6 +23,9 @@ export const useWebSocket = () => {
 
     connect();
 
+    // Live updates for every monitor shown on the dashboard
+    const unsubscribeMonitorsTopic = wsService.subscribeTopic("monitor:*");
+
     // Subscribe to connection events
     const unsubscribeConnection = wsService.subscribe("connection:open", () => {
       console.log("WebSocket connected successfully");
@@ -37,6 +40,12 @@ export const useWebSocket = () => {
       queryClient.invalidateQueries({ queryKey: ["monitors"] });
     });
 
+    // Missed events could not be replayed, so reload the data they would have updated
+    const unsubscribeResync = wsService.subscribe("resync_required", () => {
+      queryClient.invalidateQueries({ queryKey: ["monitors"] });
+      queryClient.invalidateQueries({ queryKey: ["notifications"] });
+    });
+
     const unsubscribeDisconnection = wsService.subscribe(
       "connection:close",
       () => {
@@ -215,7 +224,9 @@ export const useWebSocket = () => {
       }
 
       // Unsubscribe from all events
+      unsubscribeMonitorsTopic();
       unsubscribeConnection();
+      unsubscribeResync();
       unsubscribeDisconnection();
       unsubscribeMonitorUpdate();
       unsubscribeStatusChange();
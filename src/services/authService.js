@@ -5,22 +5,102 @@ export const authService = {
     const response = await api.post('/auth/login', { email, password });
     if (response.data.token) {
       localStorage.setItem('token', response.data.token);
+      localStorage.setItem('refresh_token', response.data.refresh_token);
       localStorage.setItem('user', JSON.stringify(response.data.user));
     }
     return response.data;
   },
 
+  async verifyLogin(mfaToken, code) {
+    const payload = /^\d{6}$/.test(code.trim())
+      ? { mfa_token: mfaToken, code: code.trim() }
+      : { mfa_token: mfaToken, recovery_code: code.trim() };
+    const response = await api.post('/auth/login/verify', payload);
+    localStorage.setItem('token', response.data.token);
+    localStorage.setItem('refresh_token', response.data.refresh_token);
+    localStorage.setItem('user', JSON.stringify(response.data.user));
+    return response.data;
+  },
+
+  async getSSOStatus() {
+    const response = await api.get('/auth/oidc');
+    return response.data;
+  },
+
+  getSSOLoginUrl() {
+    return `${api.defaults.baseURL}/auth/oidc/login`;
+  },
+
+  // Stores the tokens handed over by the SSO callback and loads the user
+  async completeSSOLogin(token, refreshToken) {
+    localStorage.setItem('token', token);
+    localStorage.setItem('refresh_token', refreshToken);
+    const response = await api.get('/user/me');
+    localStorage.setItem('user', JSON.stringify(response.data));
+    return response.data;
+  },
+
   async register(email, password, name) {
     const response = await api.post('/auth/register', { email, password, name });
     if (response.data.token) {
       localStorage.setItem('token', response.data.token);
+      localStorage.setItem('refresh_token', response.data.refresh_token);
       localStorage.setItem('user', JSON.stringify(response.data.user));
     }
     return response.data;
   },
 
-  logout() {
+  async verifyEmail(token) {
+    const response = await api.post('/auth/verify-email', { token });
+    return response.data;
+  },
+
+  async resendVerification(email) {
+    const response = await api.post('/auth/verify-email/resend', { email });
+    return response.data;
+  },
+
+  async forgotPassword(email) {
+    const response = await api.post('/auth/forgot-password', { email });
+    return response.data;
+  },
+
+  async resetPassword(token, password) {
+    const response = await api.post('/auth/reset-password', { token, password });
+    return response.data;
+  },
+
+  async changePassword(currentPassword, newPassword) {
+    const response = await api.put('/user/password', {
+      current_password: currentPassword,
+      new_password: newPassword,
+    });
+    return response.data;
+  },
+
+  async exportAccount() {
+    const response = await api.get('/user/export');
+    return response.data;
+  },
+
+  async deleteAccount(password, code) {
+    await api.delete('/user/me', { data: { password, code } });
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+    localStorage.removeItem('user');
+  },
+
+  async logout() {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (refreshToken) {
+      try {
+        await api.post('/auth/logout', { refresh_token: refreshToken });
+      } catch (error) {
+        // The session is dropped locally even if the server can't be reached
+      }
+    }
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
     window.location.href = '/login';
   },
@@ -6,6 +6,11 @@ export const monitorService = {
     return response.data;
   },
 
+  async getMood() {
+    const response = await api.get('/mood');
+    return response.data;
+  },
+
   async getMonitor(id) {
     const response = await api.get(`/monitor/${id}`);
     return response.data;
@@ -41,6 +46,11 @@ export const monitorService = {
     return response.data;
   },
 
+  async getMonitorTimings(id, days = 7) {
+    const response = await api.get(`/monitor/${id}/timings?days=${days}`);
+    return response.data;
+  },
+
   async getMonitorForecast(id, limit = 24) {
     const response = await api.get(`/monitor/${id}/forecast?limit=${limit}`);
     return response.data;
@@ -61,6 +71,11 @@ export const monitorService = {
     return response.data;
   },
 
+  async getLocations() {
+    const response = await api.get('/locations');
+    return response.data;
+  },
+
   async getIncidents(monitorId) {
     const response = await api.get(`/incidents/${monitorId}`);
     return response.data;
@@ -10,6 +10,23 @@ class WebSocketService {
     this.pingInterval = null;
     this.connectionTimeout = null;
     this.messageQueue = [];
+    // Topic subscriptions with a count of their users, sent again on reconnect
+    this.topics = new Map();
+    // Position in the user's event stream, used to resume after a reconnect
+    this.epoch = null;
+    this.lastSeq = null;
+  }
+
+  buildUrl(wsUrl, token) {
+    const params = new URLSearchParams({ token });
+    if (this.topics.size > 0) {
+      params.set("topics", [...this.topics.keys()].join(","));
+    }
+    if (this.lastSeq !== null) {
+      params.set("last_seq", String(this.lastSeq));
+      params.set("epoch", this.epoch || "");
+    }
+    return `${wsUrl}?${params.toString()}`;
   }
 
   connect(token) {
@@ -36,7 +53,7 @@ class WebSocketService {
       }, 10000); // 10 second timeout
 
       try {
-        this.ws = new WebSocket(`${wsUrl}?token=${token}`);
+        this.ws = new WebSocket(this.buildUrl(wsUrl, token));
 
         this.ws.onopen = () => {
           console.log("WebSocket connected");
@@ -83,6 +100,7 @@ class WebSocketService {
             messages.forEach((messageStr) => {
               try {
                 const data = JSON.parse(messageStr);
+                this.trackSequence(data);
                 this.notifyListeners(data);
               } catch (parseError) {
                 console.error(
@@ -115,8 +133,8 @@ class WebSocketService {
           console.log("WebSocket disconnected", event.code, event.reason);
           this.handleConnectionClose();
 
-          // Only attempt reconnect if not a normal closure
-          if (event.code !== 1000 && event.code !== 1001) {
+          // Only attempt reconnect if not a normal closure or a revoked session
+          if (event.code !== 1000 && event.code !== 1001 && event.code !== 4001) {
             this.attemptReconnect(token);
           }
         };
@@ -128,6 +146,17 @@ class WebSocketService {
     });
   }
 
+  // Remember the latest event so a reconnect replays what was missed. The
+  // hello message that follows a (re)connect carries the current position.
+  trackSequence(data) {
+    if (data.type === "hello" && data.data) {
+      this.epoch = data.data.epoch;
+      this.lastSeq = data.data.seq;
+    } else if (typeof data.seq === "number" && data.seq > (this.lastSeq || 0)) {
+      this.lastSeq = data.seq;
+    }
+  }
+
   handleConnectionError(error) {
     this.isConnecting = false;
     this.isConnected = false;
@@ -176,7 +205,8 @@ class WebSocketService {
 
     setTimeout(() => {
       if (!this.isConnected && !this.isConnecting) {
-        this.connect(token).catch((error) => {
+        // The access token may have been refreshed since the last attempt
+        this.connect(localStorage.getItem("token") || token).catch((error) => {
           console.error("Reconnection failed:", error);
         });
       }
@@ -206,6 +236,33 @@ class WebSocketService {
     return false;
   }
 
+  // Ask the server for messages on a topic such as "monitor:12", "monitor:*",
+  // "incident:<id>", "logs:<incidentId>" or "commands". Once any topic is
+  // subscribed, only topic messages the page asked for are delivered.
+  // Returns a function that releases the subscription.
+  subscribeTopic(topic) {
+    const count = this.topics.get(topic) || 0;
+    this.topics.set(topic, count + 1);
+    if (count === 0 && this.isConnected) {
+      this.send({ type: "subscribe", topics: [topic] }, false);
+    }
+
+    let released = false;
+    return () => {
+      if (released) return;
+      released = true;
+      const remaining = (this.topics.get(topic) || 1) - 1;
+      if (remaining > 0) {
+        this.topics.set(topic, remaining);
+        return;
+      }
+      this.topics.delete(topic);
+      if (this.isConnected) {
+        this.send({ type: "unsubscribe", topics: [topic] }, false);
+      }
+    };
+  }
+
   subscribe(eventType, callback) {
     if (!this.listeners.has(eventType)) {
       this.listeners.set(eventType, new Set());
@@ -225,7 +282,9 @@ class WebSocketService {
   }
 
   notifyListeners(data) {
-    const { type, ...payload } = data;
+    const { type, ...rest } = data;
+    // Server messages wrap their payload in "data"
+    const payload = rest.data !== undefined ? rest.data : rest;
 
     // Notify specific event listeners
     const listeners = this.listeners.get(type);
@@ -280,8 +339,11 @@ class WebSocketService {
     // Clear message queue
     this.messageQueue = [];
 
-    // Clear all listeners
+    // Clear all listeners and subscriptions
     this.listeners.clear();
+    this.topics.clear();
+    this.epoch = null;
+    this.lastSeq = null;
   }
 
   // Get current connection status